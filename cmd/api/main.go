@@ -2,56 +2,96 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"multistep-registration/internal/breach"
 	"multistep-registration/internal/config"
 	"multistep-registration/internal/database"
+	"multistep-registration/internal/logging"
 	"multistep-registration/internal/server"
+	"multistep-registration/internal/tracing"
 )
 
-func gracefulShutdown(apiServer *http.Server, done chan bool) {
+func gracefulShutdown(apiServer *server.HTTPServer, done chan bool) {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	<-ctx.Done()
 
-	log.Println("shutting down gracefully, press Ctrl+C again to force")
+	slog.Info("shutting down gracefully, press Ctrl+C again to force")
 	stop()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := apiServer.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown with error: %v", err)
+		slog.Error("server forced to shutdown", "error", err)
 	}
 
-	log.Println("Server exiting")
+	slog.Info("server exiting")
 
 	done <- true
 }
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+
+	logger := logging.New(os.Stdout, cfg.Log.Level)
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		FilePath:     cfg.Tracing.FilePath,
+		ServiceName:  cfg.Tracing.ServiceName,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
 
 	db, err := database.NewDatabase(context.Background(), cfg)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		fatal("failed to connect to database", err)
 	}
 	defer db.Close()
 
 	migrator := database.NewMigrator(cfg.Database.MigrationsPath)
 	if version, dirty, err := migrator.CheckMigrationsStatus(db.SQL, cfg.Database.DBName); err == nil {
-		log.Printf("Current migration version: %d, dirty: %v", version, dirty)
+		slog.Info("current migration version", "version", version, "dirty", dirty)
 	}
 
 	if err := migrator.RunMigrations(db.SQL, cfg.Database.DBName); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("failed to run migrations", err)
 	}
 
-	server := server.NewServer(server.Props{Config: cfg, Database: db})
+	var breaches breach.Source
+	if path := cfg.BreachedPasswords.Path; path != "" {
+		if breaches, err = breach.Open(path, cfg.BreachedPasswords.IndexPath); err != nil {
+			fatal("failed to open breached password corpus", err)
+		}
+		defer breaches.Close()
+	}
+
+	server, err := server.NewServer(server.Props{Config: cfg, Database: db, Logger: logger, Breaches: breaches})
+	if err != nil {
+		fatal("failed to create server", err)
+	}
 
 	done := make(chan bool, 1)
 
@@ -59,9 +99,15 @@ func main() {
 
 	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
-		log.Panicf("http server error: %s\n", err)
+		fatal("http server error", err)
 	}
 
 	<-done
-	log.Println("Graceful shutdown complete.")
+	slog.Info("graceful shutdown complete")
+}
+
+// fatal logs the error and exits, deferred calls don't run
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,128 @@
+// Command pepper manages the password pepper keys.
+//
+//	pepper generate  prints a new random key
+//	pepper status    counts the users of every pepper key
+//	pepper rotate    flags users not peppered with the current key, their
+//	                 password is re-peppered on their next login
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"multistep-registration/internal/config"
+	"multistep-registration/internal/database"
+	"multistep-registration/internal/logging"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/repository"
+)
+
+const usage = "usage: pepper generate|status|rotate"
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "generate":
+		// Generating needs no configuration, it runs before the secrets exist
+		err = generate()
+	case "status":
+		err = withRepository(status)
+	case "rotate":
+		err = withRepository(rotate)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		slog.Error("pepper command failed", "command", os.Args[1], "error", err)
+		os.Exit(1)
+	}
+}
+
+func generate() error {
+	key, err := password.GeneratePepperKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+// withRepository loads the configuration and runs the command against the
+// users of the configured database
+func withRepository(run func(context.Context, *config.Config, repository.UserRepository) error) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log.Level))
+
+	ctx := context.Background()
+
+	db, err := database.NewDatabase(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return run(ctx, cfg, repository.NewUserRepository(db.Pool))
+}
+
+func status(ctx context.Context, cfg *config.Config, users repository.UserRepository) error {
+	pepper, err := loadPepper(cfg)
+	if err != nil {
+		return err
+	}
+
+	keys, err := users.CountUsersByPepperKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-20s %10s %16s\n", "KEY", "USERS", "REHASH REQUIRED")
+	for _, key := range keys {
+		id := key.KeyID
+		switch {
+		case id == "":
+			id = "(none)"
+		case pepper != nil && id == pepper.CurrentKeyID():
+			id += " (current)"
+		}
+		fmt.Printf("%-20s %10d %16d\n", id, key.Users, key.RehashRequired)
+	}
+	return nil
+}
+
+func rotate(ctx context.Context, cfg *config.Config, users repository.UserRepository) error {
+	pepper, err := loadPepper(cfg)
+	if err != nil {
+		return err
+	}
+	if pepper == nil {
+		return errors.New("no pepper key is configured")
+	}
+
+	flagged, err := users.FlagPasswordsForRehash(ctx, pepper.CurrentKeyID())
+	if err != nil {
+		return err
+	}
+
+	slog.Info("flagged passwords for re-pepper on next login", "current_key_id", pepper.CurrentKeyID(), "users", flagged)
+	return nil
+}
+
+func loadPepper(cfg *config.Config) (*password.Pepper, error) {
+	pepper, err := password.LoadPepper(cfg.Security.PepperCurrentKeyID, cfg.Security.PepperKeys, cfg.Security.PepperKeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password pepper config: %w", err)
+	}
+	return pepper, nil
+}
@@ -48,7 +48,7 @@ const MultiStepForm: React.FC = () => {
             },
         })
 
-    const { validateStep } = useStepValidation({
+    const { validateStep, stepError, clearStepError } = useStepValidation({
         currentStep,
         methods,
     })
@@ -162,6 +162,10 @@ const MultiStepForm: React.FC = () => {
                     />
                 )}
 
+                {stepError && currentStep !== 4 && (
+                    <Alert type="error" message={stepError} onClose={clearStepError} />
+                )}
+
                 <div className="min-h-[400px]">{renderStep()}</div>
 
                 {currentStep !== 4 && (
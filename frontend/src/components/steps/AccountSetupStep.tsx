@@ -163,7 +163,7 @@ const AccountSetupStep: React.FC = () => {
                 {password && (
                     <div className="p-3 bg-gray-50 rounded-lg">
                         <p className="mb-2 text-sm font-medium text-gray-700">
-                            Password Requirements:
+                            Password Tips:
                         </p>
                         <ul className="space-y-1 text-sm">
                             <li
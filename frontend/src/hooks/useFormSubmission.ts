@@ -1,4 +1,4 @@
-import { useState, useCallback } from 'react'
+import { useState, useCallback, useRef } from 'react'
 import { type UseFormReturn } from 'react-hook-form'
 import { apiService } from '../services/api'
 
@@ -17,6 +17,9 @@ export const useFormSubmission = ({ methods, onSuccess }: UseFormSubmissionProps
         isSubmitting: false,
         submitError: null,
     })
+    // Retries of the same payload reuse the key so the server replays the
+    // first response instead of reporting the user as already registered
+    const idempotency = useRef<{ key: string; payload: string } | null>(null)
 
     const clearError = useCallback(() => {
         setState((prev) => ({ ...prev, submitError: null }))
@@ -46,7 +49,14 @@ export const useFormSubmission = ({ methods, onSuccess }: UseFormSubmissionProps
             if (!formValues.phoneNumber) {
                 delete formValues.phoneNumber
             }
-            const response = await apiService.submitRegistration(formValues)
+            const payload = JSON.stringify(formValues)
+            if (idempotency.current?.payload !== payload) {
+                idempotency.current = { key: crypto.randomUUID(), payload }
+            }
+            const response = await apiService.submitRegistration(
+                formValues,
+                idempotency.current.key,
+            )
 
             if (response.email) {
                 setState({
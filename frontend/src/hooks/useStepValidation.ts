@@ -1,7 +1,9 @@
-import { useCallback } from 'react'
+import { useCallback, useState } from 'react'
 import { type UseFormReturn } from 'react-hook-form'
 import { useUsernameValidation } from './useUsernameValidation'
 import { useEmailValidation } from './useEmailValidation'
+import { apiService } from '../services/api'
+import type { ValidationErrorResponse } from '../services/api.types'
 
 interface StepValidationProps {
     currentStep: number
@@ -11,14 +13,44 @@ interface StepValidationProps {
 export const useStepValidation = ({ currentStep, methods }: StepValidationProps) => {
     const { checkUsername } = useUsernameValidation()
     const { checkEmail } = useEmailValidation()
+    // stepError holds server errors that belong to no form field, e.g. an
+    // unreadable body
+    const [stepError, setStepError] = useState<string | null>(null)
+
+    const clearStepError = useCallback(() => setStepError(null), [])
 
     const validateStep = useCallback(async (): Promise<boolean> => {
+        setStepError(null)
         const isValid = await methods.trigger()
 
         if (!isValid) {
             return false
         }
 
+        if (currentStep <= 3) {
+            try {
+                // Every step's fields are sent, the password of the last step
+                // is checked against the names and email of the first
+                await apiService.validateStep(currentStep, methods.getValues())
+            } catch (error) {
+                const { errors } = error as ValidationErrorResponse
+                // Network errors, 5xx and 429 say nothing about the fields,
+                // the client side validation above stands and the final
+                // submit validates everything again
+                if (Array.isArray(errors)) {
+                    const fields = Object.keys(methods.getValues())
+                    errors.forEach(({ field, message }) => {
+                        if (fields.includes(field)) {
+                            methods.setError(field, { type: 'server', message })
+                        } else {
+                            setStepError(message)
+                        }
+                    })
+                    return false
+                }
+            }
+        }
+
         if (currentStep === 1) {
             const email = methods.getValues('email')
             const isEmailAvailable = await checkEmail(email)
@@ -48,5 +80,5 @@ export const useStepValidation = ({ currentStep, methods }: StepValidationProps)
         return true
     }, [currentStep, methods, checkUsername, checkEmail])
 
-    return { validateStep }
+    return { validateStep, stepError, clearStepError }
 }
@@ -1,5 +1,5 @@
 import { z } from 'zod'
-import { passwordRegex, validatePhoneNumber } from '../../utils/validation'
+import { validatePhoneNumber } from '../../utils/validation'
 
 export const checkPasswordStrength = (password: string) => {
     const checks = {
@@ -82,11 +82,7 @@ export const accountSchema = z
         password: z
             .string()
             .min(8, 'Password must be at least 8 characters')
-            .max(50, 'Password cannot exceed 50 characters')
-            .regex(
-                passwordRegex,
-                'Password must include: uppercase letter, lowercase letter, number, and special character',
-            ),
+            .max(128, 'Password cannot exceed 128 characters'),
 
         confirmPassword: z.string(),
 
@@ -4,9 +4,11 @@ import type {
     AvailabilityResponse,
     ErrorResponse,
     RegistrationResponse,
+    StepValidationResponse,
+    ValidationErrorResponse,
 } from './api.types'
 
-const API_BASE_URL = 'http://localhost:8080/api'
+const API_BASE_URL = 'http://localhost:8080/api/v1'
 
 const api = axios.create({
     baseURL: API_BASE_URL,
@@ -50,9 +52,35 @@ export const apiService = {
         }
     },
 
-    submitRegistration: async (data: FormData): Promise<RegistrationResponse> => {
+    validateStep: async (
+        step: number,
+        data: Partial<FormData>,
+    ): Promise<StepValidationResponse> => {
         try {
-            const response = await api.post<RegistrationResponse>('/register', data)
+            const response = await api.post<StepValidationResponse>(
+                `/register/validate/${step}`,
+                data,
+            )
+            return response.data
+        } catch (error) {
+            if (axios.isAxiosError(error) && error.response?.data) {
+                throw error.response.data as ValidationErrorResponse
+            }
+            throw {
+                code: 'UNKNOWN_ERROR',
+                message: 'An unexpected error occurred',
+            } as ErrorResponse
+        }
+    },
+
+    submitRegistration: async (
+        data: FormData,
+        idempotencyKey?: string,
+    ): Promise<RegistrationResponse> => {
+        try {
+            const response = await api.post<RegistrationResponse>('/register', data, {
+                headers: idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : undefined,
+            })
             return response.data
         } catch (error) {
             if (axios.isAxiosError(error) && error.response?.data) {
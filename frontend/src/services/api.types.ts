@@ -7,6 +7,7 @@ export type ErrorResponse = {
     code: string
     message: string
     errors?: Record<string, string>
+    requestId?: string
 }
 
 export type RegistrationResponse = {
@@ -16,3 +17,19 @@ export type RegistrationResponse = {
     createdAt: string
     message: string
 }
+
+export type FieldError = {
+    field: string
+    message: string
+}
+
+export type ValidationErrorResponse = {
+    code: string
+    errors: FieldError[]
+    requestId?: string
+}
+
+export type StepValidationResponse = {
+    step: number
+    valid: boolean
+}
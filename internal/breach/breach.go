@@ -0,0 +1,117 @@
+// Package breach checks passwords against corpora of breached passwords
+// using the k-anonymity model of the Pwned Passwords range API: only the
+// first 5 hex characters of the password SHA-1 select a range of suffixes,
+// the password itself never leaves the checker
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PrefixLength is the number of hex characters of a range prefix
+	PrefixLength = 5
+	hashLength   = sha1.Size * 2
+	suffixLength = hashLength - PrefixLength
+	// prefixCount is the number of distinct range prefixes, 16^5
+	prefixCount = 1 << (4 * PrefixLength)
+)
+
+var ErrInvalidPrefix = errors.New("invalid range prefix")
+
+// Source returns the range of a prefix in the range API format, one
+// "SUFFIX:COUNT" line per hash starting with the prefix. Local corpora and a
+// remote range API are interchangeable behind it
+type Source interface {
+	Range(ctx context.Context, prefix string) ([]byte, error)
+	// Close releases the open files of the source
+	Close() error
+}
+
+// Checker reports whether passwords appear in the corpus of its source
+type Checker struct {
+	source Source
+	// minCount is the number of breaches from which a password is rejected
+	minCount int
+}
+
+// NewChecker creates a checker that rejects passwords seen at least minCount
+// times, a minCount below 1 rejects any password of the corpus
+func NewChecker(source Source, minCount int) *Checker {
+	return &Checker{source: source, minCount: max(minCount, 1)}
+}
+
+// Breached reports whether the password was seen in at least minCount breaches
+func (c *Checker) Breached(ctx context.Context, password string) (bool, error) {
+	count, err := c.Count(ctx, password)
+	if err != nil {
+		return false, err
+	}
+	return count >= c.minCount, nil
+}
+
+// Count returns how many times the password was seen in breaches
+func (c *Checker) Count(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	body, err := c.source.Range(ctx, hash[:PrefixLength])
+	if err != nil {
+		return 0, fmt.Errorf("failed to read breach range: %w", err)
+	}
+	return findSuffix(body, hash[PrefixLength:])
+}
+
+// findSuffix scans a range body for the suffix, suffixes compare case
+// insensitively and padding entries with a count of 0 are not matches
+func findSuffix(body []byte, suffix string) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		hash, count, ok := bytes.Cut(line, []byte(":"))
+		if !ok || !bytes.EqualFold(hash, []byte(suffix)) {
+			continue
+		}
+
+		n, err := strconv.Atoi(string(count))
+		if err != nil {
+			return 0, fmt.Errorf("invalid breach count %q", count)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
+
+// Open opens a local corpus, a directory of range files or a single sorted
+// hash file indexed at indexPath
+func Open(path, indexPath string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+	if info.IsDir() {
+		return NewDirSource(path)
+	}
+	return NewFileSource(path, indexPath)
+}
+
+// parsePrefix decodes a range prefix to its index in [0, prefixCount)
+func parsePrefix(prefix string) (int, error) {
+	if len(prefix) != PrefixLength {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPrefix, prefix)
+	}
+	n, err := strconv.ParseUint(prefix, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPrefix, prefix)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,195 @@
+package breach
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeCorpus writes "HASH:COUNT" lines sorted by hash, as the ordered by
+// hash download
+func writeCorpus(t *testing.T, path string, counts map[string]int) {
+	t.Helper()
+
+	lines := make([]string, 0, len(counts))
+	for password, count := range counts {
+		lines = append(lines, fmt.Sprintf("%s:%d\r\n", sha1Hex(password), count))
+	}
+	slices.Sort(lines)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openFileSource(t *testing.T, path, indexPath string) *FileSource {
+	t.Helper()
+
+	source, err := NewFileSource(path, indexPath)
+	if err != nil {
+		t.Fatalf("NewFileSource() error = %v", err)
+	}
+	t.Cleanup(func() { source.Close() })
+	return source
+}
+
+func TestFileSourceBuildsIndex(t *testing.T) {
+	dir := t.TempDir()
+	path, indexPath := filepath.Join(dir, "pwned.txt"), filepath.Join(dir, "pwned.idx")
+	writeCorpus(t, path, map[string]int{"password": 9545824, "123456": 37359195, "qwerty": 3946737})
+
+	openFileSource(t, path, indexPath)
+
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatalf("index was not built: %v", err)
+	}
+	if info.Size() != indexSize {
+		t.Errorf("index size = %d, want %d", info.Size(), indexSize)
+	}
+	corpus, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := openIndex(indexPath, corpus)
+	if err != nil {
+		t.Fatalf("openIndex() error = %v", err)
+	}
+	index.Close()
+}
+
+func TestFileSourceRebuildsStaleIndex(t *testing.T) {
+	dir := t.TempDir()
+	path, indexPath := filepath.Join(dir, "pwned.txt"), filepath.Join(dir, "pwned.idx")
+	writeCorpus(t, path, map[string]int{"password": 9545824})
+	openFileSource(t, path, indexPath).Close()
+
+	// An updated corpus, the mtime moves forward even on coarse clocks
+	writeCorpus(t, path, map[string]int{"password": 9545824, "qwerty": 3946737})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openIndex(indexPath, corpus); !errors.Is(err, errStaleIndex) {
+		t.Fatalf("openIndex() of an updated corpus error = %v, want %v", err, errStaleIndex)
+	}
+
+	checker := NewChecker(openFileSource(t, path, indexPath), 1)
+	if count, err := checker.Count(context.Background(), "qwerty"); err != nil || count != 3946737 {
+		t.Errorf("Count() after the update = %d, %v, want 3946737", count, err)
+	}
+	index, err := openIndex(indexPath, corpus)
+	if err != nil {
+		t.Fatalf("openIndex() of the rebuilt index error = %v", err)
+	}
+	index.Close()
+}
+
+func TestFileSourceRejectsUnsortedCorpus(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pwned.txt")
+	body := sha1Hex("qwerty") + ":1\n" + sha1Hex("password") + ":1\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileSource(path, filepath.Join(dir, "pwned.idx")); err == nil || !strings.Contains(err.Error(), "not sorted") {
+		t.Errorf("NewFileSource() error = %v, want an unsorted corpus error", err)
+	}
+}
+
+func TestFileSourceRange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pwned.txt")
+	writeCorpus(t, path, map[string]int{"password": 9545824, "123456": 37359195, "qwerty": 3946737})
+	source := openFileSource(t, path, filepath.Join(dir, "pwned.idx"))
+	ctx := context.Background()
+
+	hash := sha1Hex("password")
+	body, err := source.Range(ctx, hash[:PrefixLength])
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	if want := hash[PrefixLength:] + ":9545824\r\n"; string(body) != want {
+		t.Errorf("Range(%s) = %q, want %q", hash[:PrefixLength], body, want)
+	}
+
+	for _, prefix := range []string{"00000", "FFFFF"} {
+		if body, err := source.Range(ctx, prefix); err != nil || len(body) != 0 {
+			t.Errorf("Range(%s) = %q, %v, want an empty range", prefix, body, err)
+		}
+	}
+	if _, err := source.Range(ctx, "5BAAG"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("Range() of an invalid prefix error = %v, want %v", err, ErrInvalidPrefix)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pwned.txt")
+	writeCorpus(t, path, map[string]int{"password": 9545824, "123456": 37359195, "rarely-used": 2})
+	checker := NewChecker(openFileSource(t, path, filepath.Join(dir, "pwned.idx")), 10)
+
+	tests := []struct {
+		password  string
+		wantCount int
+		wantFound bool
+	}{
+		{"password", 9545824, true},
+		{"123456", 37359195, true},
+		{"rarely-used", 2, false},
+		{"correct horse battery staple", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			count, err := checker.Count(context.Background(), tt.password)
+			if err != nil || count != tt.wantCount {
+				t.Errorf("Count() = %d, %v, want %d", count, err, tt.wantCount)
+			}
+			if found, err := checker.Breached(context.Background(), tt.password); err != nil || found != tt.wantFound {
+				t.Errorf("Breached() = %v, %v, want %v", found, err, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestDirSourceRange(t *testing.T) {
+	dir := t.TempDir()
+	hash := sha1Hex("password")
+	if err := os.WriteFile(filepath.Join(dir, hash[:PrefixLength]+".txt"), []byte(hash[PrefixLength:]+":9545824\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := Open(dir, "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer source.Close()
+
+	checker := NewChecker(source, 1)
+	if count, err := checker.Count(context.Background(), "password"); err != nil || count != 9545824 {
+		t.Errorf("Count() = %d, %v, want 9545824", count, err)
+	}
+	// A prefix without a file is an empty range
+	if count, err := checker.Count(context.Background(), "qwerty"); err != nil || count != 0 {
+		t.Errorf("Count() of a missing range = %d, %v, want 0", count, err)
+	}
+}
@@ -0,0 +1,51 @@
+package breach
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirSource reads a directory of range files as written by the Pwned
+// Passwords downloader, one file per prefix named PREFIX or PREFIX.txt. The
+// file system is the index, a lookup opens a single file
+type DirSource struct {
+	dir string
+}
+
+func NewDirSource(dir string) (*DirSource, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breach corpus %s is not a directory", dir)
+	}
+	return &DirSource{dir: dir}, nil
+}
+
+// Range returns the content of the prefix file, a missing file is an empty range
+func (s *DirSource) Range(_ context.Context, prefix string) ([]byte, error) {
+	if _, err := parsePrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	prefix = strings.ToUpper(prefix)
+	for _, name := range []string{prefix + ".txt", prefix} {
+		body, err := os.ReadFile(filepath.Join(s.dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return body, err
+	}
+	return nil, nil
+}
+
+// Close does nothing, files are opened per lookup
+func (s *DirSource) Close() error {
+	return nil
+}
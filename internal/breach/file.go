@@ -0,0 +1,223 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// indexMagic identifies index files and their layout version
+	indexMagic = "BRCHIDX1"
+	// indexHeaderSize is the magic followed by the size and modification
+	// time of the indexed corpus, a changed corpus invalidates the index
+	indexHeaderSize = len(indexMagic) + 8 + 8
+	// indexSize holds one offset per prefix and the corpus size as the end
+	// of the last range
+	indexSize = int64(indexHeaderSize) + (prefixCount+1)*8
+
+	// maxRangeSize bounds a range read, real ranges are a few dozen KB
+	maxRangeSize = 16 << 20
+)
+
+var errStaleIndex = errors.New("breach corpus index is stale")
+
+// FileSource reads a single corpus file of "HASH:COUNT" lines sorted by
+// hash, as the Pwned Passwords ordered by hash download. An index file maps
+// every prefix to the byte range of its lines, so a lookup reads 16 bytes of
+// the index and the range without loading the corpus in memory
+type FileSource struct {
+	corpus *os.File
+	index  *os.File
+}
+
+// NewFileSource opens the corpus and its index at indexPath. A missing or
+// stale index is rebuilt first, which reads the whole corpus once
+func NewFileSource(path, indexPath string) (*FileSource, error) {
+	corpus, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+	info, err := corpus.Stat()
+	if err != nil {
+		corpus.Close()
+		return nil, fmt.Errorf("failed to open breach corpus: %w", err)
+	}
+
+	index, err := openIndex(indexPath, info)
+	if errors.Is(err, errStaleIndex) || errors.Is(err, fs.ErrNotExist) {
+		slog.Info("building breach corpus index", "corpus", path, "index", indexPath)
+		start := time.Now()
+		if err := buildIndex(corpus, info, indexPath); err != nil {
+			corpus.Close()
+			return nil, err
+		}
+		slog.Info("built breach corpus index", "duration", time.Since(start))
+
+		index, err = openIndex(indexPath, info)
+	}
+	if err != nil {
+		corpus.Close()
+		return nil, err
+	}
+
+	return &FileSource{corpus: corpus, index: index}, nil
+}
+
+// Range reads the lines of the prefix and strips the prefix from the hashes
+func (s *FileSource) Range(_ context.Context, prefix string) ([]byte, error) {
+	p, err := parsePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets [16]byte
+	if _, err := s.index.ReadAt(offsets[:], int64(indexHeaderSize)+int64(p)*8); err != nil {
+		return nil, fmt.Errorf("failed to read breach corpus index: %w", err)
+	}
+	start := binary.BigEndian.Uint64(offsets[:8])
+	end := binary.BigEndian.Uint64(offsets[8:])
+	if end < start || end-start > maxRangeSize {
+		return nil, fmt.Errorf("breach corpus index is corrupt at prefix %s", prefix)
+	}
+
+	lines := make([]byte, end-start)
+	if _, err := s.corpus.ReadAt(lines, int64(start)); err != nil {
+		return nil, fmt.Errorf("failed to read breach corpus: %w", err)
+	}
+
+	body := make([]byte, 0, len(lines))
+	for line := range bytes.Lines(lines) {
+		if len(line) > PrefixLength {
+			body = append(body, line[PrefixLength:]...)
+		}
+	}
+	return body, nil
+}
+
+// Close closes the corpus and its index
+func (s *FileSource) Close() error {
+	return errors.Join(s.corpus.Close(), s.index.Close())
+}
+
+// openIndex opens the index and checks that it was built from the corpus as it is now
+func openIndex(path string, corpus fs.FileInfo) (*os.File, error) {
+	index, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach corpus index: %w", err)
+	}
+
+	header := make([]byte, indexHeaderSize)
+	info, err := index.Stat()
+	if err == nil && info.Size() == indexSize {
+		_, err = io.ReadFull(index, header)
+	} else if err == nil {
+		err = errStaleIndex
+	}
+	if err == nil && !bytes.Equal(header, indexHeader(corpus)) {
+		err = errStaleIndex
+	}
+	if err != nil {
+		index.Close()
+		if errors.Is(err, errStaleIndex) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read breach corpus index: %w", err)
+	}
+
+	return index, nil
+}
+
+func indexHeader(corpus fs.FileInfo) []byte {
+	header := make([]byte, 0, indexHeaderSize)
+	header = append(header, indexMagic...)
+	header = binary.BigEndian.AppendUint64(header, uint64(corpus.Size()))
+	header = binary.BigEndian.AppendUint64(header, uint64(corpus.ModTime().UnixNano()))
+	return header
+}
+
+// buildIndex records the offset of the first line of every prefix. Prefixes
+// without lines get the offset of the next line, so their range is empty.
+// The index is written to a temporary file and renamed, a crash never leaves
+// a partial index behind
+func buildIndex(corpus *os.File, info fs.FileInfo, path string) error {
+	offsets := make([]uint64, prefixCount+1)
+	reader := bufio.NewReaderSize(io.NewSectionReader(corpus, 0, info.Size()), 1<<20)
+
+	var offset uint64
+	next, previous := 0, 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("breach corpus line %d is too long", lineNumber)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read breach corpus: %w", err)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			if len(line) < hashLength {
+				return fmt.Errorf("breach corpus line %d is not a SHA-1 hash", lineNumber)
+			}
+			p, perr := parsePrefix(string(line[:PrefixLength]))
+			if perr != nil {
+				return fmt.Errorf("breach corpus line %d is not a SHA-1 hash", lineNumber)
+			}
+			if p < previous {
+				return fmt.Errorf("breach corpus is not sorted by hash at line %d", lineNumber)
+			}
+			for ; next <= p; next++ {
+				offsets[next] = offset
+			}
+			previous = p
+		}
+		offset += uint64(len(line))
+
+		if err != nil {
+			break
+		}
+	}
+	for ; next <= prefixCount; next++ {
+		offsets[next] = offset
+	}
+
+	index := make([]byte, 0, indexSize)
+	index = append(index, indexHeader(info)...)
+	for _, offset := range offsets {
+		index = binary.BigEndian.AppendUint64(index, offset)
+	}
+	return writeIndex(path, index)
+}
+
+func writeIndex(path string, index []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".breach-index-*")
+	if err != nil {
+		return fmt.Errorf("failed to create breach corpus index: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(index); err != nil {
+		return fmt.Errorf("failed to write breach corpus index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write breach corpus index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write breach corpus index: %w", err)
+	}
+	return nil
+}
@@ -1,13 +1,22 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
+	// Env is development or production, development falls back to insecure
+	// defaults for the secrets
+	Env      string
 	Database struct {
 		Host           string
 		Port           string
@@ -16,24 +25,137 @@ type Config struct {
 		DBName         string
 		SSLMode        string
 		MigrationsPath string
+		// TagQueries prefixes queries with the request ID as a SQL comment, it
+		// is off by default as tagged queries skip the statement cache
+		TagQueries bool
 	}
 	Server struct {
 		Port         int
 		ReadTimeout  int
 		WriteTimeout int
+		// TrustedProxies are the proxies allowed to set the client IP through
+		// X-Forwarded-For, the peer address is used when empty
+		TrustedProxies []string
+	}
+	API struct {
+		// LegacyDeprecatedAt and LegacySunsetAt are announced on the
+		// unversioned /api alias, as YYYY-MM-DD dates
+		LegacyDeprecatedAt string
+		LegacySunsetAt     string
+	}
+	Log struct {
+		Level string
+	}
+	Metrics struct {
+		Enabled bool
+	}
+	Tracing struct {
+		// Exporter is none, stdout, file or otlp
+		Exporter     string
+		FilePath     string
+		ServiceName  string
+		OTLPEndpoint string
+		OTLPInsecure bool
+		SampleRatio  float64
 	}
 	Security struct {
-		PasswordCost int
+		// PasswordAlgorithm hashes new passwords, argon2id or bcrypt. Hashes of
+		// the other algorithm keep verifying and are upgraded on login
+		PasswordAlgorithm string
+		// PasswordCost is the bcrypt cost
+		PasswordCost      int
+		Argon2MemoryKiB   int
+		Argon2Iterations  int
+		Argon2Parallelism int
+		// PepperKeys are inline "id:base64" pepper keys, PepperKeysDir holds
+		// one key file per key ID. No keys disable the pepper
+		PepperKeys         []string
+		PepperKeysDir      string
+		PepperCurrentKeyID string
+		// HashConcurrency bounds concurrent hash operations, HashQueueDepth
+		// the ones waiting for a slot before requests are answered with 503
+		HashConcurrency int
+		HashQueueDepth  int
+		// PasswordMinScore is the lowest accepted strength score of new
+		// passwords, from 0 to 4. PasswordClassRules also requires every
+		// class of characters
+		PasswordMinScore   int
+		PasswordClassRules bool
+		TokenSecret        string
+	}
+	BreachedPasswords struct {
+		// Path is a directory of range files or a single file of hashes
+		// sorted by hash, empty disables the check
+		Path string
+		// IndexPath is where the index of a single file corpus is kept
+		IndexPath string
+		// MinCount is the number of breaches from which a password is rejected
+		MinCount int
+	}
+	Drafts struct {
+		TTLHours int
+	}
+	Idempotency struct {
+		TTLHours int
+	}
+	RateLimit struct {
+		Enabled bool
+		// Store is memory or resp, resp shares the limits between replicas
+		Store                 string
+		RESPAddr              string
+		RESPPassword          string
+		RESPTimeoutMs         int
+		RESPKeyPrefix         string
+		CheckRequests         int
+		CheckPeriodSeconds    int
+		RegisterRequests      int
+		RegisterPeriodSeconds int
+		// Login limits password and MFA code guessing
+		LoginRequests      int
+		LoginPeriodSeconds int
+		// Mail limits the endpoints sending emails to an address
+		MailRequests      int
+		MailPeriodSeconds int
+	}
+	Session struct {
+		TTLHours     int
+		CookieName   string
+		CookieDomain string
+		CookieSecure bool
+	}
+	MFA struct {
+		Issuer        string
+		EncryptionKey []byte
+	}
+	Mail struct {
+		Driver               string
+		OutboxPath           string
+		SMTPHost             string
+		SMTPPort             int
+		SMTPUsername         string
+		SMTPPassword         string
+		From                 string
+		VerificationURL      string
+		VerificationTTLHours int
+		PasswordResetURL     string
+		// PasswordResetTTLMinutes is kept short as the link grants account access
+		PasswordResetTTLMinutes int
 	}
 }
 
-func Load() *Config {
+// developmentTokenSecret is the TOKEN_SECRET of development environments,
+// it is public so it must never sign production tokens
+const developmentTokenSecret = "insecure-development-secret"
+
+func Load() (*Config, error) {
 	var cfg Config
 
+	cfg.Env = getEnv("APP_ENV", "production")
+
 	migrationsPath := getEnv("DB_MIGRATIONS_PATH", "internal/database/migrations/")
 	absMigrationsPath, err := filepath.Abs(migrationsPath)
 	if err != nil {
-		fmt.Printf("could not get absolute path for migrations: %v\n", err)
+		slog.Warn("could not get absolute path for migrations", "error", err)
 		absMigrationsPath = migrationsPath
 	}
 
@@ -45,16 +167,131 @@ func Load() *Config {
 	cfg.Database.DBName = getEnv("DB_NAME", "registration_db")
 	cfg.Database.SSLMode = getEnv("DB_SSLMODE", "disable")
 	cfg.Database.MigrationsPath = absMigrationsPath
+	cfg.Database.TagQueries = getEnvAsBool("DB_TAG_QUERIES", false)
 
 	// Server
 	cfg.Server.Port = getEnvAsInt("PORT", 8080)
 	cfg.Server.ReadTimeout = getEnvAsInt("READ_TIMEOUT", 10)
 	cfg.Server.WriteTimeout = getEnvAsInt("WRITE_TIMEOUT", 10)
+	cfg.Server.TrustedProxies = getEnvAsList("TRUSTED_PROXIES")
+
+	// API
+	cfg.API.LegacyDeprecatedAt = getEnv("API_LEGACY_DEPRECATED_AT", "2026-10-17")
+	cfg.API.LegacySunsetAt = getEnv("API_LEGACY_SUNSET_AT", "2027-04-30")
+
+	// Logging
+	cfg.Log.Level = getEnv("LOG_LEVEL", "info")
+
+	// Metrics
+	cfg.Metrics.Enabled = getEnvAsBool("METRICS_ENABLED", true)
+
+	// Tracing
+	cfg.Tracing.Exporter = getEnv("TRACING_EXPORTER", "none")
+	cfg.Tracing.FilePath = getEnv("TRACING_FILE_PATH", "traces.jsonl")
+	cfg.Tracing.ServiceName = getEnv("TRACING_SERVICE_NAME", "multistep-registration")
+	cfg.Tracing.OTLPEndpoint = getEnv("TRACING_OTLP_ENDPOINT", "")
+	cfg.Tracing.OTLPInsecure = getEnvAsBool("TRACING_OTLP_INSECURE", false)
+	cfg.Tracing.SampleRatio = getEnvAsFloat("TRACING_SAMPLE_RATIO", 1)
 
 	// Security
+	cfg.Security.PasswordAlgorithm = getEnv("PASSWORD_ALGORITHM", "argon2id")
 	cfg.Security.PasswordCost = getEnvAsInt("PASSWORD_COST", 12)
+	cfg.Security.Argon2MemoryKiB = getEnvAsInt("ARGON2_MEMORY_KIB", 19*1024)
+	cfg.Security.Argon2Iterations = getEnvAsInt("ARGON2_ITERATIONS", 2)
+	cfg.Security.Argon2Parallelism = getEnvAsInt("ARGON2_PARALLELISM", 1)
+	cfg.Security.PepperKeys = getEnvAsList("PASSWORD_PEPPER_KEYS")
+	cfg.Security.PepperKeysDir = getEnv("PASSWORD_PEPPER_KEYS_DIR", "")
+	cfg.Security.PepperCurrentKeyID = getEnv("PASSWORD_PEPPER_CURRENT_KEY_ID", "")
+	// Half of the cores by default, the rest of the API keeps some headroom
+	cfg.Security.HashConcurrency = getEnvAsInt("PASSWORD_HASH_CONCURRENCY", max(runtime.GOMAXPROCS(0)/2, 1))
+	cfg.Security.HashQueueDepth = getEnvAsInt("PASSWORD_HASH_QUEUE_DEPTH", 32)
+	cfg.Security.PasswordMinScore = min(max(getEnvAsInt("PASSWORD_MIN_SCORE", 3), 0), 4)
+	cfg.Security.PasswordClassRules = getEnvAsBool("PASSWORD_CLASS_RULES", false)
+	cfg.Security.TokenSecret = getEnv("TOKEN_SECRET", "")
+
+	// Breached passwords
+	cfg.BreachedPasswords.Path = getEnv("BREACHED_PASSWORDS_PATH", "")
+	cfg.BreachedPasswords.IndexPath = getEnv("BREACHED_PASSWORDS_INDEX_PATH", cfg.BreachedPasswords.Path+".idx")
+	cfg.BreachedPasswords.MinCount = getEnvAsInt("BREACHED_PASSWORDS_MIN_COUNT", 1)
+
+	// Drafts
+	cfg.Drafts.TTLHours = getEnvAsInt("DRAFT_TTL_HOURS", 72)
+
+	// Idempotency
+	cfg.Idempotency.TTLHours = getEnvAsInt("IDEMPOTENCY_KEY_TTL_HOURS", 24)
 
-	return &cfg
+	// Rate limiting
+	cfg.RateLimit.Enabled = getEnvAsBool("RATE_LIMIT_ENABLED", true)
+	cfg.RateLimit.Store = getEnv("RATE_LIMIT_STORE", "memory")
+	cfg.RateLimit.RESPAddr = getEnv("RATE_LIMIT_RESP_ADDR", "localhost:6379")
+	cfg.RateLimit.RESPPassword = getEnv("RATE_LIMIT_RESP_PASSWORD", "")
+	cfg.RateLimit.RESPTimeoutMs = getEnvAsInt("RATE_LIMIT_RESP_TIMEOUT_MS", 100)
+	cfg.RateLimit.RESPKeyPrefix = getEnv("RATE_LIMIT_RESP_KEY_PREFIX", "ratelimit:")
+	cfg.RateLimit.CheckRequests = getEnvAsInt("RATE_LIMIT_CHECK_REQUESTS", 30)
+	cfg.RateLimit.CheckPeriodSeconds = getEnvAsInt("RATE_LIMIT_CHECK_PERIOD_SECONDS", 60)
+	cfg.RateLimit.RegisterRequests = getEnvAsInt("RATE_LIMIT_REGISTER_REQUESTS", 10)
+	cfg.RateLimit.RegisterPeriodSeconds = getEnvAsInt("RATE_LIMIT_REGISTER_PERIOD_SECONDS", 3600)
+	cfg.RateLimit.LoginRequests = getEnvAsInt("RATE_LIMIT_LOGIN_REQUESTS", 10)
+	cfg.RateLimit.LoginPeriodSeconds = getEnvAsInt("RATE_LIMIT_LOGIN_PERIOD_SECONDS", 300)
+	cfg.RateLimit.MailRequests = getEnvAsInt("RATE_LIMIT_MAIL_REQUESTS", 5)
+	cfg.RateLimit.MailPeriodSeconds = getEnvAsInt("RATE_LIMIT_MAIL_PERIOD_SECONDS", 3600)
+
+	// Session
+	cfg.Session.TTLHours = getEnvAsInt("SESSION_TTL_HOURS", 24)
+	cfg.Session.CookieName = getEnv("SESSION_COOKIE_NAME", "session_id")
+	cfg.Session.CookieDomain = getEnv("SESSION_COOKIE_DOMAIN", "")
+	cfg.Session.CookieSecure = getEnvAsBool("SESSION_COOKIE_SECURE", true)
+
+	// MFA
+	cfg.MFA.Issuer = getEnv("MFA_ISSUER", "Multistep Registration")
+	cfg.MFA.EncryptionKey, err = getEnvAsKey("MFA_ENCRYPTION_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	// Mail
+	cfg.Mail.Driver = getEnv("MAIL_DRIVER", "outbox")
+	cfg.Mail.OutboxPath = getEnv("MAIL_OUTBOX_PATH", "")
+	cfg.Mail.SMTPHost = getEnv("SMTP_HOST", "localhost")
+	cfg.Mail.SMTPPort = getEnvAsInt("SMTP_PORT", 587)
+	cfg.Mail.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	cfg.Mail.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	cfg.Mail.From = getEnv("MAIL_FROM", "no-reply@localhost")
+	cfg.Mail.VerificationURL = getEnv("EMAIL_VERIFICATION_URL", "http://localhost:5173/verify-email")
+	cfg.Mail.VerificationTTLHours = getEnvAsInt("EMAIL_VERIFICATION_TTL_HOURS", 24)
+	cfg.Mail.PasswordResetURL = getEnv("PASSWORD_RESET_URL", "http://localhost:5173/reset-password")
+	cfg.Mail.PasswordResetTTLMinutes = getEnvAsInt("PASSWORD_RESET_TTL_MINUTES", 60)
+
+	if err := cfg.applySecretDefaults(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *Config) IsDevelopment() bool {
+	return cfg.Env == "development"
+}
+
+// applySecretDefaults requires the secrets outside development, where they
+// fall back to a public secret and a key derived from it
+func (cfg *Config) applySecretDefaults() error {
+	if cfg.Security.TokenSecret == "" {
+		if !cfg.IsDevelopment() {
+			return errors.New("TOKEN_SECRET must be set when APP_ENV is not development")
+		}
+		cfg.Security.TokenSecret = developmentTokenSecret
+	}
+
+	if cfg.MFA.EncryptionKey == nil {
+		if !cfg.IsDevelopment() {
+			return errors.New("MFA_ENCRYPTION_KEY must be set when APP_ENV is not development")
+		}
+		derived := sha256.Sum256([]byte("MFA_ENCRYPTION_KEY:" + cfg.Security.TokenSecret))
+		cfg.MFA.EncryptionKey = derived[:]
+	}
+
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -72,3 +309,50 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList splits a comma separated variable, empty items are skipped
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsKey decodes a base64 encoded 32 bytes key, it returns nil when the
+// variable is not set
+func getEnvAsKey(key string) ([]byte, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64: %w", key, err)
+	}
+	if len(decoded) != 32 {
+		return nil, fmt.Errorf("%s must decode to 32 bytes, got %d", key, len(decoded))
+	}
+	return decoded, nil
+}
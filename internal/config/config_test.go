@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+		wantKey []byte
+	}{
+		{
+			name:    "production without secrets",
+			env:     map[string]string{"APP_ENV": "production"},
+			wantErr: "TOKEN_SECRET must be set",
+		},
+		{
+			name:    "production without MFA key",
+			env:     map[string]string{"TOKEN_SECRET": "s3cret"},
+			wantErr: "MFA_ENCRYPTION_KEY must be set",
+		},
+		{
+			name: "production with secrets",
+			env: map[string]string{
+				"TOKEN_SECRET":       "s3cret",
+				"MFA_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString(key),
+			},
+			wantKey: key,
+		},
+		{
+			name:    "malformed MFA key",
+			env:     map[string]string{"APP_ENV": "development", "MFA_ENCRYPTION_KEY": "not base64!"},
+			wantErr: "MFA_ENCRYPTION_KEY is not valid base64",
+		},
+		{
+			name: "short MFA key",
+			env: map[string]string{
+				"APP_ENV":            "development",
+				"MFA_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString(key[:16]),
+			},
+			wantErr: "must decode to 32 bytes",
+		},
+		{
+			name: "development defaults",
+			env:  map[string]string{"APP_ENV": "development"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"APP_ENV", "TOKEN_SECRET", "MFA_ENCRYPTION_KEY"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Security.TokenSecret == "" || len(cfg.MFA.EncryptionKey) != 32 {
+				t.Errorf("Load() secrets = %q, %x, want both set", cfg.Security.TokenSecret, cfg.MFA.EncryptionKey)
+			}
+			if tt.wantKey != nil && !bytes.Equal(cfg.MFA.EncryptionKey, tt.wantKey) {
+				t.Errorf("MFA key = %x, want %x", cfg.MFA.EncryptionKey, tt.wantKey)
+			}
+		})
+	}
+}
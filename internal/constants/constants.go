@@ -1,7 +1,18 @@
 package constants
 
 const (
-	CodeValidationError = "VALIDATION_ERROR"
-	CodeDuplicateError  = "DUPLICATE_ERROR"
-	CodeInternalError   = "INTERNAL_ERROR"
+	CodeValidationError      = "VALIDATION_ERROR"
+	CodeDuplicateError       = "DUPLICATE_ERROR"
+	CodeInternalError        = "INTERNAL_ERROR"
+	CodeNotFound             = "NOT_FOUND"
+	CodeInvalidToken         = "INVALID_TOKEN"
+	CodeInvalidLogin         = "INVALID_CREDENTIALS"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeInvalidMFA           = "INVALID_MFA_CODE"
+	CodeMFAStateError        = "MFA_STATE_ERROR"
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestInProgress    = "REQUEST_IN_PROGRESS"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	CodeServiceBusy          = "SERVICE_BUSY"
 )
@@ -10,6 +10,12 @@ type Key string
 
 const (
 	RegistrationRequestKey Key = "registration_request"
+	DraftIDKey             Key = "draft_id"
+	UserKey                Key = "user"
+	SessionTokenKey        Key = "session_token"
+	PasswordResetKey       Key = "password_reset_request"
+	ErrorCodeKey           Key = "error_code"
+	APIVersionKey          Key = "api_version"
 )
 
 func SetRegistrationRequest(c *gin.Context, req *domain.RegistrationRequest) {
@@ -48,6 +54,112 @@ func MustGetRegistrationRequest(c *gin.Context) *domain.RegistrationRequest {
 	return req
 }
 
+func SetPasswordResetRequest(c *gin.Context, req *domain.PasswordResetRequest) {
+	c.Set(string(PasswordResetKey), req)
+}
+
+func GetPasswordResetRequest(c *gin.Context) (*domain.PasswordResetRequest, bool) {
+	val, exists := c.Get(string(PasswordResetKey))
+	if !exists {
+		return nil, false
+	}
+
+	req, ok := val.(*domain.PasswordResetRequest)
+	if !ok {
+		return nil, false
+	}
+
+	return req, true
+}
+
+// MustGetPasswordResetRequest gets password reset request from context or panics
+func MustGetPasswordResetRequest(c *gin.Context) *domain.PasswordResetRequest {
+	req, exists := GetPasswordResetRequest(c)
+	if !exists {
+		panic(ErrRequestNotFound)
+	}
+	return req
+}
+
+// SetDraftID marks the current registration as finalized from a draft
+func SetDraftID(c *gin.Context, draftID string) {
+	c.Set(string(DraftIDKey), draftID)
+}
+
+func GetDraftID(c *gin.Context) (string, bool) {
+	val, exists := c.Get(string(DraftIDKey))
+	if !exists {
+		return "", false
+	}
+
+	draftID, ok := val.(string)
+	if !ok || draftID == "" {
+		return "", false
+	}
+
+	return draftID, true
+}
+
+// SetUser stores the authenticated user and the session token it was resolved from
+func SetUser(c *gin.Context, user *domain.User, sessionToken string) {
+	c.Set(string(UserKey), user)
+	c.Set(string(SessionTokenKey), sessionToken)
+}
+
+func GetUser(c *gin.Context) (*domain.User, bool) {
+	val, exists := c.Get(string(UserKey))
+	if !exists {
+		return nil, false
+	}
+
+	user, ok := val.(*domain.User)
+	if !ok {
+		return nil, false
+	}
+
+	return user, true
+}
+
+// MustGetUser gets the authenticated user from context or panics
+// Use only behind AuthMiddleware
+func MustGetUser(c *gin.Context) *domain.User {
+	user, exists := GetUser(c)
+	if !exists {
+		panic(ErrRequestNotFound)
+	}
+	return user
+}
+
+func GetSessionToken(c *gin.Context) (string, bool) {
+	val, exists := c.Get(string(SessionTokenKey))
+	if !exists {
+		return "", false
+	}
+
+	token, ok := val.(string)
+	return token, ok
+}
+
+// SetErrorCode records the code of the error response, so middlewares can
+// tell why a request failed without parsing the body
+func SetErrorCode(c *gin.Context, code string) {
+	c.Set(string(ErrorCodeKey), code)
+}
+
+func GetErrorCode(c *gin.Context) string {
+	return c.GetString(string(ErrorCodeKey))
+}
+
+// SetAPIVersion records the API version whose contract the route serves
+func SetAPIVersion(c *gin.Context, version string) {
+	c.Set(string(APIVersionKey), version)
+}
+
+func GetAPIVersion(c *gin.Context) (string, bool) {
+	version := c.GetString(string(APIVersionKey))
+	return version, version != ""
+}
+
 var (
 	ErrRequestNotFound = NewContextError("request not found in context")
 )
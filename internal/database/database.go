@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"multistep-registration/internal/config"
@@ -45,6 +45,7 @@ func NewDatabase(ctx context.Context, cfg *config.Config) (*Database, error) {
 	poolConfig.MaxConnIdleTime = 30 * time.Minute
 	poolConfig.HealthCheckPeriod = time.Minute
 	poolConfig.ConnConfig.ConnectTimeout = 5 * time.Second
+	poolConfig.ConnConfig.Tracer = NewQueryTracer()
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
@@ -65,7 +66,7 @@ func NewDatabase(ctx context.Context, cfg *config.Config) (*Database, error) {
 		return nil, fmt.Errorf("failed to ping sql.DB: %w", err)
 	}
 
-	log.Println("Database connection established successfully")
+	slog.Info("database connection established")
 	return &Database{Pool: pool, SQL: sqlDB}, nil
 }
 
@@ -0,0 +1,15 @@
+-- Create registration drafts table
+CREATE TABLE registration_drafts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    token_hash BYTEA UNIQUE NOT NULL,
+
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    current_step INTEGER NOT NULL DEFAULT 0,
+
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Create indexes
+CREATE INDEX idx_registration_drafts_expires_at ON registration_drafts (expires_at);
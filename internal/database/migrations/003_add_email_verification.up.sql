@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP(0) WITH TIME ZONE;
+
+-- Create single-use user tokens table, purpose separates token kinds
+CREATE TABLE user_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash BYTEA UNIQUE NOT NULL,
+
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Create indexes
+CREATE INDEX idx_user_tokens_user_id_purpose ON user_tokens (user_id, purpose);
@@ -0,0 +1,17 @@
+-- mfa_secret holds the TOTP secret encrypted by the application
+ALTER TABLE users
+    ADD COLUMN mfa_secret BYTEA,
+    ADD COLUMN mfa_enabled_at TIMESTAMP(0) WITH TIME ZONE,
+    ADD COLUMN mfa_last_used_step BIGINT NOT NULL DEFAULT 0;
+
+-- Create MFA recovery codes table
+CREATE TABLE mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now(),
+
+    UNIQUE (user_id, code_hash)
+);
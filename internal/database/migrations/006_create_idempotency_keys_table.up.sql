@@ -0,0 +1,21 @@
+-- Create idempotency keys table, a NULL status_code marks a request in progress
+CREATE TABLE idempotency_keys (
+    idempotency_key VARCHAR(255) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    -- client is the session user a key belongs to, so users can't replay or
+    -- block each other's requests. Anonymous keys have an empty client
+    client VARCHAR(255) NOT NULL DEFAULT '',
+    request_hash BYTEA NOT NULL,
+
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (idempotency_key, route, client)
+);
+
+-- Create indexes
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS password_rehash_required,
+    DROP COLUMN IF EXISTS password_pepper_key_id;
@@ -0,0 +1,7 @@
+-- password_pepper_key_id names the server side key the password was HMACed
+-- with before hashing, NULL when it was hashed without a pepper.
+-- password_rehash_required is set by the pepper rotation command, the hash
+-- is replaced on the next successful login
+ALTER TABLE users
+    ADD COLUMN password_pepper_key_id TEXT,
+    ADD COLUMN password_rehash_required BOOLEAN NOT NULL DEFAULT false;
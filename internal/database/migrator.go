@@ -3,9 +3,10 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
@@ -55,7 +56,7 @@ func (m *Migrator) ValidateMigrationsPath() error {
 		return fmt.Errorf("no .sql migration files found in: %s", absPath)
 	}
 
-	log.Printf("Migrations path validated: %s", absPath)
+	slog.Info("migrations path validated", "path", absPath)
 	return nil
 }
 
@@ -88,16 +89,16 @@ func (m *Migrator) RunMigrations(db *sql.DB, dbName string) error {
 	migrateInstance.Log = &MigrationLogger{}
 
 	// Run migrations
-	log.Println("Running database migrations...")
+	slog.Info("running database migrations")
 	if err := migrateInstance.Up(); err != nil {
 		if err == migrate.ErrNoChange {
-			log.Println("No new migrations to run")
+			slog.Info("no new migrations to run")
 			return nil
 		}
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Println("Migrations completed successfully")
+	slog.Info("migrations completed successfully")
 	return nil
 }
 
@@ -129,7 +130,7 @@ func (m *Migrator) CheckMigrationsStatus(db *sql.DB, dbName string) (version uin
 type MigrationLogger struct{}
 
 func (ml *MigrationLogger) Printf(format string, v ...any) {
-	log.Printf("[MIGRATION] "+format, v...)
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)), "component", "migrate")
 }
 
 func (ml *MigrationLogger) Verbose() bool {
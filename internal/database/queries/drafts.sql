@@ -0,0 +1,28 @@
+-- name: CreateDraft :one
+INSERT INTO registration_drafts (
+    token_hash,
+    expires_at
+) VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetDraftByTokenHash :one
+SELECT * FROM registration_drafts
+WHERE token_hash = $1 AND expires_at > now()
+LIMIT 1;
+
+-- name: UpdateDraftStep :one
+-- Merges the saved sections into the stored ones, concurrent saves of other
+-- steps are kept
+UPDATE registration_drafts
+SET data = data || sqlc.arg(sections)::jsonb,
+    current_step = GREATEST(current_step, sqlc.arg(current_step)::integer),
+    expires_at = sqlc.arg(expires_at),
+    updated_at = now()
+WHERE token_hash = sqlc.arg(token_hash) AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteDraftByTokenHash :exec
+DELETE FROM registration_drafts WHERE token_hash = $1;
+
+-- name: DeleteExpiredDrafts :execrows
+DELETE FROM registration_drafts WHERE expires_at <= now();
@@ -0,0 +1,39 @@
+-- name: ClaimIdempotencyKey :one
+-- Inserts the key or takes over an expired one or one whose request never
+-- completed, returns no rows when the key is held
+INSERT INTO idempotency_keys (
+    idempotency_key,
+    route,
+    client,
+    request_hash,
+    expires_at
+) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (idempotency_key, route, client) DO UPDATE
+SET request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    content_type = NULL,
+    response_body = NULL,
+    expires_at = EXCLUDED.expires_at,
+    created_at = now()
+WHERE idempotency_keys.expires_at <= now()
+   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= sqlc.arg(stale_before))
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE idempotency_key = $1 AND route = $2 AND client = $3
+LIMIT 1;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status_code = $4,
+    content_type = $5,
+    response_body = $6
+WHERE idempotency_key = $1 AND route = $2 AND client = $3;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE idempotency_key = $1 AND route = $2 AND client = $3;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at <= now();
@@ -0,0 +1,26 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+    user_id,
+    token_hash,
+    user_agent,
+    ip_address,
+    expires_at
+) VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetSessionByTokenHash :one
+SELECT * FROM sessions
+WHERE token_hash = $1 AND expires_at > now()
+LIMIT 1;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_seen_at = now() WHERE id = $1;
+
+-- name: DeleteSessionByTokenHash :exec
+DELETE FROM sessions WHERE token_hash = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at <= now();
@@ -0,0 +1,21 @@
+-- name: CreateUserToken :one
+INSERT INTO user_tokens (
+    user_id,
+    purpose,
+    token_hash,
+    expires_at
+) VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ConsumeUserToken :one
+UPDATE user_tokens
+SET used_at = now()
+WHERE token_hash = $1
+  AND purpose = $2
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteUnusedUserTokens :exec
+DELETE FROM user_tokens
+WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
@@ -10,11 +10,15 @@ INSERT INTO users (
     country,
     username,
     password_hash,
+    password_pepper_key_id,
     accept_terms,
     newsletter
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 RETURNING *;
 
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1 LIMIT 1;
+
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1 LIMIT 1;
 
@@ -26,3 +30,70 @@ SELECT EXISTS(SELECT 1 FROM users WHERE email = $1);
 
 -- name: CheckUsernameExists :one
 SELECT EXISTS(SELECT 1 FROM users WHERE username = $1);
+
+-- name: MarkEmailVerified :exec
+UPDATE users
+SET email_verified_at = now(),
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1 AND email_verified_at IS NULL;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    password_pepper_key_id = $3,
+    password_rehash_required = false,
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1;
+
+-- name: RehashUserPassword :execrows
+UPDATE users
+SET password_hash = sqlc.arg(new_hash),
+    password_pepper_key_id = sqlc.arg(pepper_key_id),
+    password_rehash_required = false
+WHERE id = sqlc.arg(id) AND password_hash = sqlc.arg(old_hash);
+
+-- name: FlagPasswordsForRehash :execrows
+UPDATE users
+SET password_rehash_required = true
+WHERE password_pepper_key_id IS DISTINCT FROM sqlc.narg(current_key_id)
+  AND NOT password_rehash_required;
+
+-- name: CountUsersByPepperKey :many
+SELECT password_pepper_key_id,
+       count(*) AS users,
+       count(*) FILTER (WHERE password_rehash_required) AS rehash_required
+FROM users
+GROUP BY password_pepper_key_id
+ORDER BY password_pepper_key_id NULLS FIRST;
+
+-- name: SetUserMFASecret :exec
+UPDATE users
+SET mfa_secret = $2,
+    mfa_enabled_at = NULL,
+    mfa_last_used_step = 0,
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1;
+
+-- name: EnableUserMFA :exec
+UPDATE users
+SET mfa_enabled_at = now(),
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1 AND mfa_secret IS NOT NULL;
+
+-- name: DisableUserMFA :exec
+UPDATE users
+SET mfa_secret = NULL,
+    mfa_enabled_at = NULL,
+    mfa_last_used_step = 0,
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1;
+
+-- name: UpdateUserMFALastUsedStep :execrows
+UPDATE users
+SET mfa_last_used_step = $2
+WHERE id = $1 AND mfa_last_used_step < $2;
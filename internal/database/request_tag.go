@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	sqlc "multistep-registration/internal/database/sqlc"
+	"multistep-registration/internal/requestid"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RequestTaggedDB prefixes queries with a /* request_id=... */ comment, so
+// slow query logs and pg_stat_activity can be matched to the request.
+// Tagged queries run as unnamed prepared statements, the comment makes every
+// query text unique and would otherwise evict the statement cache. That costs
+// one extra round trip per query
+type RequestTaggedDB struct {
+	db DB
+}
+
+// DB is a connection pool the repositories query and start transactions on
+type DB interface {
+	sqlc.DBTX
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func NewRequestTaggedDB(db DB) *RequestTaggedDB {
+	return &RequestTaggedDB{db: db}
+}
+
+// Begin starts a transaction whose queries are tagged as well
+func (t *RequestTaggedDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return requestTaggedTx{Tx: tx}, nil
+}
+
+func (t *RequestTaggedDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	sql, args = tagQuery(ctx, sql, args)
+	return t.db.Exec(ctx, sql, args...)
+}
+
+func (t *RequestTaggedDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	sql, args = tagQuery(ctx, sql, args)
+	return t.db.Query(ctx, sql, args...)
+}
+
+func (t *RequestTaggedDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	sql, args = tagQuery(ctx, sql, args)
+	return t.db.QueryRow(ctx, sql, args...)
+}
+
+type requestTaggedTx struct {
+	pgx.Tx
+}
+
+func (t requestTaggedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return requestTaggedTx{Tx: tx}, nil
+}
+
+func (t requestTaggedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	sql, args = tagQuery(ctx, sql, args)
+	return t.Tx.Exec(ctx, sql, args...)
+}
+
+func (t requestTaggedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	sql, args = tagQuery(ctx, sql, args)
+	return t.Tx.Query(ctx, sql, args...)
+}
+
+func (t requestTaggedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	sql, args = tagQuery(ctx, sql, args)
+	return t.Tx.QueryRow(ctx, sql, args...)
+}
+
+func tagQuery(ctx context.Context, sql string, args []any) (string, []any) {
+	id := requestid.FromContext(ctx)
+	// Valid only lets through IDs that can't close the comment
+	if !requestid.Valid(id) {
+		return sql, args
+	}
+
+	tagged := "/* request_id=" + id + " */ " + sql
+	return tagged, append([]any{pgx.QueryExecModeDescribeExec}, args...)
+}
@@ -0,0 +1,121 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: drafts.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createDraft = `-- name: CreateDraft :one
+INSERT INTO registration_drafts (
+    token_hash,
+    expires_at
+) VALUES ($1, $2)
+RETURNING id, token_hash, data, current_step, expires_at, created_at, updated_at
+`
+
+type CreateDraftParams struct {
+	TokenHash []byte             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateDraft(ctx context.Context, arg CreateDraftParams) (RegistrationDrafts, error) {
+	row := q.db.QueryRow(ctx, createDraft, arg.TokenHash, arg.ExpiresAt)
+	var i RegistrationDrafts
+	err := row.Scan(
+		&i.ID,
+		&i.TokenHash,
+		&i.Data,
+		&i.CurrentStep,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteDraftByTokenHash = `-- name: DeleteDraftByTokenHash :exec
+DELETE FROM registration_drafts WHERE token_hash = $1
+`
+
+func (q *Queries) DeleteDraftByTokenHash(ctx context.Context, tokenHash []byte) error {
+	_, err := q.db.Exec(ctx, deleteDraftByTokenHash, tokenHash)
+	return err
+}
+
+const deleteExpiredDrafts = `-- name: DeleteExpiredDrafts :execrows
+DELETE FROM registration_drafts WHERE expires_at <= now()
+`
+
+func (q *Queries) DeleteExpiredDrafts(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredDrafts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getDraftByTokenHash = `-- name: GetDraftByTokenHash :one
+SELECT id, token_hash, data, current_step, expires_at, created_at, updated_at FROM registration_drafts
+WHERE token_hash = $1 AND expires_at > now()
+LIMIT 1
+`
+
+func (q *Queries) GetDraftByTokenHash(ctx context.Context, tokenHash []byte) (RegistrationDrafts, error) {
+	row := q.db.QueryRow(ctx, getDraftByTokenHash, tokenHash)
+	var i RegistrationDrafts
+	err := row.Scan(
+		&i.ID,
+		&i.TokenHash,
+		&i.Data,
+		&i.CurrentStep,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateDraftStep = `-- name: UpdateDraftStep :one
+UPDATE registration_drafts
+SET data = data || $1::jsonb,
+    current_step = GREATEST(current_step, $2::integer),
+    expires_at = $3,
+    updated_at = now()
+WHERE token_hash = $4 AND expires_at > now()
+RETURNING id, token_hash, data, current_step, expires_at, created_at, updated_at
+`
+
+type UpdateDraftStepParams struct {
+	Sections    []byte             `json:"sections"`
+	CurrentStep int32              `json:"current_step"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+	TokenHash   []byte             `json:"token_hash"`
+}
+
+// Merges the saved sections into the stored ones, concurrent saves of other
+// steps are kept
+func (q *Queries) UpdateDraftStep(ctx context.Context, arg UpdateDraftStepParams) (RegistrationDrafts, error) {
+	row := q.db.QueryRow(ctx, updateDraftStep,
+		arg.Sections,
+		arg.CurrentStep,
+		arg.ExpiresAt,
+		arg.TokenHash,
+	)
+	var i RegistrationDrafts
+	err := row.Scan(
+		&i.ID,
+		&i.TokenHash,
+		&i.Data,
+		&i.CurrentStep,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,153 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: idempotency_keys.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimIdempotencyKey = `-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+    idempotency_key,
+    route,
+    client,
+    request_hash,
+    expires_at
+) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (idempotency_key, route, client) DO UPDATE
+SET request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    content_type = NULL,
+    response_body = NULL,
+    expires_at = EXCLUDED.expires_at,
+    created_at = now()
+WHERE idempotency_keys.expires_at <= now()
+   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= $6)
+RETURNING idempotency_key, route, client, request_hash, status_code, content_type, response_body, expires_at, created_at
+`
+
+type ClaimIdempotencyKeyParams struct {
+	IdempotencyKey string             `json:"idempotency_key"`
+	Route          string             `json:"route"`
+	Client         string             `json:"client"`
+	RequestHash    []byte             `json:"request_hash"`
+	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
+	StaleBefore    pgtype.Timestamptz `json:"stale_before"`
+}
+
+// Inserts the key or takes over an expired one or one whose request never
+// completed, returns no rows when the key is held
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKeys, error) {
+	row := q.db.QueryRow(ctx, claimIdempotencyKey,
+		arg.IdempotencyKey,
+		arg.Route,
+		arg.Client,
+		arg.RequestHash,
+		arg.ExpiresAt,
+		arg.StaleBefore,
+	)
+	var i IdempotencyKeys
+	err := row.Scan(
+		&i.IdempotencyKey,
+		&i.Route,
+		&i.Client,
+		&i.RequestHash,
+		&i.StatusCode,
+		&i.ContentType,
+		&i.ResponseBody,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status_code = $4,
+    content_type = $5,
+    response_body = $6
+WHERE idempotency_key = $1 AND route = $2 AND client = $3
+`
+
+type CompleteIdempotencyKeyParams struct {
+	IdempotencyKey string      `json:"idempotency_key"`
+	Route          string      `json:"route"`
+	Client         string      `json:"client"`
+	StatusCode     pgtype.Int4 `json:"status_code"`
+	ContentType    pgtype.Text `json:"content_type"`
+	ResponseBody   []byte      `json:"response_body"`
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, completeIdempotencyKey,
+		arg.IdempotencyKey,
+		arg.Route,
+		arg.Client,
+		arg.StatusCode,
+		arg.ContentType,
+		arg.ResponseBody,
+	)
+	return err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at <= now()
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredIdempotencyKeys)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE idempotency_key = $1 AND route = $2 AND client = $3
+`
+
+type DeleteIdempotencyKeyParams struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	Route          string `json:"route"`
+	Client         string `json:"client"`
+}
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, deleteIdempotencyKey, arg.IdempotencyKey, arg.Route, arg.Client)
+	return err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT idempotency_key, route, client, request_hash, status_code, content_type, response_body, expires_at, created_at FROM idempotency_keys
+WHERE idempotency_key = $1 AND route = $2 AND client = $3
+LIMIT 1
+`
+
+type GetIdempotencyKeyParams struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	Route          string `json:"route"`
+	Client         string `json:"client"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKeys, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, arg.IdempotencyKey, arg.Route, arg.Client)
+	var i IdempotencyKeys
+	err := row.Scan(
+		&i.IdempotencyKey,
+		&i.Route,
+		&i.Client,
+		&i.RequestHash,
+		&i.StatusCode,
+		&i.ContentType,
+		&i.ResponseBody,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -9,21 +9,78 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type IdempotencyKeys struct {
+	IdempotencyKey string             `json:"idempotency_key"`
+	Route          string             `json:"route"`
+	Client         string             `json:"client"`
+	RequestHash    []byte             `json:"request_hash"`
+	StatusCode     pgtype.Int4        `json:"status_code"`
+	ContentType    pgtype.Text        `json:"content_type"`
+	ResponseBody   []byte             `json:"response_body"`
+	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type MfaRecoveryCodes struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	CodeHash  []byte             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type RegistrationDrafts struct {
+	ID          uuid.UUID          `json:"id"`
+	TokenHash   []byte             `json:"token_hash"`
+	Data        []byte             `json:"data"`
+	CurrentStep int32              `json:"current_step"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Sessions struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     uuid.UUID          `json:"user_id"`
+	TokenHash  []byte             `json:"token_hash"`
+	UserAgent  string             `json:"user_agent"`
+	IpAddress  string             `json:"ip_address"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	LastSeenAt pgtype.Timestamptz `json:"last_seen_at"`
+}
+
+type UserTokens struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	Purpose   string             `json:"purpose"`
+	TokenHash []byte             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Users struct {
-	ID            uuid.UUID          `json:"id"`
-	FirstName     string             `json:"first_name"`
-	LastName      string             `json:"last_name"`
-	Email         string             `json:"email"`
-	PhoneNumber   pgtype.Text        `json:"phone_number"`
-	StreetAddress string             `json:"street_address"`
-	City          string             `json:"city"`
-	State         string             `json:"state"`
-	Country       string             `json:"country"`
-	Username      string             `json:"username"`
-	PasswordHash  []byte             `json:"password_hash"`
-	AcceptTerms   bool               `json:"accept_terms"`
-	Newsletter    bool               `json:"newsletter"`
-	CreatedAt     pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
-	Version       int32              `json:"version"`
+	ID                     uuid.UUID          `json:"id"`
+	FirstName              string             `json:"first_name"`
+	LastName               string             `json:"last_name"`
+	Email                  string             `json:"email"`
+	PhoneNumber            pgtype.Text        `json:"phone_number"`
+	StreetAddress          string             `json:"street_address"`
+	City                   string             `json:"city"`
+	State                  string             `json:"state"`
+	Country                string             `json:"country"`
+	Username               string             `json:"username"`
+	PasswordHash           []byte             `json:"password_hash"`
+	AcceptTerms            bool               `json:"accept_terms"`
+	Newsletter             bool               `json:"newsletter"`
+	CreatedAt              pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt              pgtype.Timestamptz `json:"updated_at"`
+	Version                int32              `json:"version"`
+	EmailVerifiedAt        pgtype.Timestamptz `json:"email_verified_at"`
+	MfaSecret              []byte             `json:"mfa_secret"`
+	MfaEnabledAt           pgtype.Timestamptz `json:"mfa_enabled_at"`
+	MfaLastUsedStep        int64              `json:"mfa_last_used_step"`
+	PasswordPepperKeyID    pgtype.Text        `json:"password_pepper_key_id"`
+	PasswordRehashRequired bool               `json:"password_rehash_required"`
 }
@@ -6,14 +6,53 @@ package database
 
 import (
 	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
 	CheckEmailExists(ctx context.Context, email string) (bool, error)
 	CheckUsernameExists(ctx context.Context, username string) (bool, error)
+	// Inserts the key or takes over an expired one or one whose request never
+	// completed, returns no rows when the key is held
+	ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKeys, error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error
+	ConsumeRecoveryCode(ctx context.Context, arg ConsumeRecoveryCodeParams) (int64, error)
+	ConsumeUserToken(ctx context.Context, arg ConsumeUserTokenParams) (UserTokens, error)
+	CountUsersByPepperKey(ctx context.Context) ([]CountUsersByPepperKeyRow, error)
+	CreateDraft(ctx context.Context, arg CreateDraftParams) (RegistrationDrafts, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Sessions, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (Users, error)
+	CreateUserToken(ctx context.Context, arg CreateUserTokenParams) (UserTokens, error)
+	DeleteDraftByTokenHash(ctx context.Context, tokenHash []byte) error
+	DeleteExpiredDrafts(ctx context.Context) (int64, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	DeleteIdempotencyKey(ctx context.Context, arg DeleteIdempotencyKeyParams) error
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+	DeleteSessionByTokenHash(ctx context.Context, tokenHash []byte) error
+	DeleteUnusedUserTokens(ctx context.Context, arg DeleteUnusedUserTokensParams) error
+	DeleteUserSessions(ctx context.Context, userID uuid.UUID) error
+	DisableUserMFA(ctx context.Context, id uuid.UUID) error
+	EnableUserMFA(ctx context.Context, id uuid.UUID) error
+	FlagPasswordsForRehash(ctx context.Context, currentKeyID pgtype.Text) (int64, error)
+	GetDraftByTokenHash(ctx context.Context, tokenHash []byte) (RegistrationDrafts, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKeys, error)
+	GetSessionByTokenHash(ctx context.Context, tokenHash []byte) (Sessions, error)
 	GetUserByEmail(ctx context.Context, email string) (Users, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (Users, error)
 	GetUserByUsername(ctx context.Context, username string) (Users, error)
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error)
+	SetUserMFASecret(ctx context.Context, arg SetUserMFASecretParams) error
+	TouchSession(ctx context.Context, id uuid.UUID) error
+	// Merges the saved sections into the stored ones, concurrent saves of other
+	// steps are kept
+	UpdateDraftStep(ctx context.Context, arg UpdateDraftStepParams) (RegistrationDrafts, error)
+	UpdateUserMFALastUsedStep(ctx context.Context, arg UpdateUserMFALastUsedStepParams) (int64, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
 }
 
 var _ Querier = (*Queries)(nil)
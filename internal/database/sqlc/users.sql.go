@@ -8,6 +8,7 @@ package database
 import (
 	"context"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -33,6 +34,41 @@ func (q *Queries) CheckUsernameExists(ctx context.Context, username string) (boo
 	return exists, err
 }
 
+const countUsersByPepperKey = `-- name: CountUsersByPepperKey :many
+SELECT password_pepper_key_id,
+       count(*) AS users,
+       count(*) FILTER (WHERE password_rehash_required) AS rehash_required
+FROM users
+GROUP BY password_pepper_key_id
+ORDER BY password_pepper_key_id NULLS FIRST
+`
+
+type CountUsersByPepperKeyRow struct {
+	PasswordPepperKeyID pgtype.Text `json:"password_pepper_key_id"`
+	Users               int64       `json:"users"`
+	RehashRequired      int64       `json:"rehash_required"`
+}
+
+func (q *Queries) CountUsersByPepperKey(ctx context.Context) ([]CountUsersByPepperKeyRow, error) {
+	rows, err := q.db.Query(ctx, countUsersByPepperKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountUsersByPepperKeyRow{}
+	for rows.Next() {
+		var i CountUsersByPepperKeyRow
+		if err := rows.Scan(&i.PasswordPepperKeyID, &i.Users, &i.RehashRequired); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     first_name,
@@ -45,25 +81,27 @@ INSERT INTO users (
     country,
     username,
     password_hash,
+    password_pepper_key_id,
     accept_terms,
     newsletter
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-RETURNING id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step, password_pepper_key_id, password_rehash_required
 `
 
 type CreateUserParams struct {
-	FirstName     string      `json:"first_name"`
-	LastName      string      `json:"last_name"`
-	Email         string      `json:"email"`
-	PhoneNumber   pgtype.Text `json:"phone_number"`
-	StreetAddress string      `json:"street_address"`
-	City          string      `json:"city"`
-	State         string      `json:"state"`
-	Country       string      `json:"country"`
-	Username      string      `json:"username"`
-	PasswordHash  []byte      `json:"password_hash"`
-	AcceptTerms   bool        `json:"accept_terms"`
-	Newsletter    bool        `json:"newsletter"`
+	FirstName           string      `json:"first_name"`
+	LastName            string      `json:"last_name"`
+	Email               string      `json:"email"`
+	PhoneNumber         pgtype.Text `json:"phone_number"`
+	StreetAddress       string      `json:"street_address"`
+	City                string      `json:"city"`
+	State               string      `json:"state"`
+	Country             string      `json:"country"`
+	Username            string      `json:"username"`
+	PasswordHash        []byte      `json:"password_hash"`
+	PasswordPepperKeyID pgtype.Text `json:"password_pepper_key_id"`
+	AcceptTerms         bool        `json:"accept_terms"`
+	Newsletter          bool        `json:"newsletter"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (Users, error) {
@@ -78,6 +116,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (Users,
 		arg.Country,
 		arg.Username,
 		arg.PasswordHash,
+		arg.PasswordPepperKeyID,
 		arg.AcceptTerms,
 		arg.Newsletter,
 	)
@@ -99,12 +138,61 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (Users,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+		&i.PasswordPepperKeyID,
+		&i.PasswordRehashRequired,
 	)
 	return i, err
 }
 
+const disableUserMFA = `-- name: DisableUserMFA :exec
+UPDATE users
+SET mfa_secret = NULL,
+    mfa_enabled_at = NULL,
+    mfa_last_used_step = 0,
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserMFA(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, disableUserMFA, id)
+	return err
+}
+
+const enableUserMFA = `-- name: EnableUserMFA :exec
+UPDATE users
+SET mfa_enabled_at = now(),
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1 AND mfa_secret IS NOT NULL
+`
+
+func (q *Queries) EnableUserMFA(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, enableUserMFA, id)
+	return err
+}
+
+const flagPasswordsForRehash = `-- name: FlagPasswordsForRehash :execrows
+UPDATE users
+SET password_rehash_required = true
+WHERE password_pepper_key_id IS DISTINCT FROM $1
+  AND NOT password_rehash_required
+`
+
+func (q *Queries) FlagPasswordsForRehash(ctx context.Context, currentKeyID pgtype.Text) (int64, error) {
+	result, err := q.db.Exec(ctx, flagPasswordsForRehash, currentKeyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version FROM users WHERE email = $1 LIMIT 1
+SELECT id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step, password_pepper_key_id, password_rehash_required FROM users WHERE email = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (Users, error) {
@@ -127,12 +215,52 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (Users, erro
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+		&i.PasswordPepperKeyID,
+		&i.PasswordRehashRequired,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step, password_pepper_key_id, password_rehash_required FROM users WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (Users, error) {
+	row := q.db.QueryRow(ctx, getUserByID, id)
+	var i Users
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Email,
+		&i.PhoneNumber,
+		&i.StreetAddress,
+		&i.City,
+		&i.State,
+		&i.Country,
+		&i.Username,
+		&i.PasswordHash,
+		&i.AcceptTerms,
+		&i.Newsletter,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+		&i.PasswordPepperKeyID,
+		&i.PasswordRehashRequired,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version FROM users WHERE username = $1 LIMIT 1
+SELECT id, first_name, last_name, email, phone_number, street_address, city, state, country, username, password_hash, accept_terms, newsletter, created_at, updated_at, version, email_verified_at, mfa_secret, mfa_enabled_at, mfa_last_used_step, password_pepper_key_id, password_rehash_required FROM users WHERE username = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (Users, error) {
@@ -155,6 +283,113 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (Users
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.EmailVerifiedAt,
+		&i.MfaSecret,
+		&i.MfaEnabledAt,
+		&i.MfaLastUsedStep,
+		&i.PasswordPepperKeyID,
+		&i.PasswordRehashRequired,
 	)
 	return i, err
 }
+
+const markEmailVerified = `-- name: MarkEmailVerified :exec
+UPDATE users
+SET email_verified_at = now(),
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1 AND email_verified_at IS NULL
+`
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, markEmailVerified, id)
+	return err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :execrows
+UPDATE users
+SET password_hash = $1,
+    password_pepper_key_id = $2,
+    password_rehash_required = false
+WHERE id = $3 AND password_hash = $4
+`
+
+type RehashUserPasswordParams struct {
+	NewHash     []byte      `json:"new_hash"`
+	PepperKeyID pgtype.Text `json:"pepper_key_id"`
+	ID          uuid.UUID   `json:"id"`
+	OldHash     []byte      `json:"old_hash"`
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rehashUserPassword,
+		arg.NewHash,
+		arg.PepperKeyID,
+		arg.ID,
+		arg.OldHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setUserMFASecret = `-- name: SetUserMFASecret :exec
+UPDATE users
+SET mfa_secret = $2,
+    mfa_enabled_at = NULL,
+    mfa_last_used_step = 0,
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1
+`
+
+type SetUserMFASecretParams struct {
+	ID        uuid.UUID `json:"id"`
+	MfaSecret []byte    `json:"mfa_secret"`
+}
+
+func (q *Queries) SetUserMFASecret(ctx context.Context, arg SetUserMFASecretParams) error {
+	_, err := q.db.Exec(ctx, setUserMFASecret, arg.ID, arg.MfaSecret)
+	return err
+}
+
+const updateUserMFALastUsedStep = `-- name: UpdateUserMFALastUsedStep :execrows
+UPDATE users
+SET mfa_last_used_step = $2
+WHERE id = $1 AND mfa_last_used_step < $2
+`
+
+type UpdateUserMFALastUsedStepParams struct {
+	ID              uuid.UUID `json:"id"`
+	MfaLastUsedStep int64     `json:"mfa_last_used_step"`
+}
+
+func (q *Queries) UpdateUserMFALastUsedStep(ctx context.Context, arg UpdateUserMFALastUsedStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserMFALastUsedStep, arg.ID, arg.MfaLastUsedStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2,
+    password_pepper_key_id = $3,
+    password_rehash_required = false,
+    updated_at = now(),
+    version = version + 1
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID                  uuid.UUID   `json:"id"`
+	PasswordHash        []byte      `json:"password_hash"`
+	PasswordPepperKeyID pgtype.Text `json:"password_pepper_key_id"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.PasswordHash, arg.PasswordPepperKeyID)
+	return err
+}
@@ -20,15 +20,59 @@ type User struct {
 
 	Username     string `json:"username" db:"username"`
 	PasswordHash []byte `json:"-" db:"password_hash"`
+	// PasswordPepperKeyID is empty when the password was hashed without a pepper
+	PasswordPepperKeyID    string `json:"-" db:"password_pepper_key_id"`
+	PasswordRehashRequired bool   `json:"-" db:"password_rehash_required"`
 
 	AcceptTerms bool `json:"acceptTerms" db:"accept_terms"`
 	Newsletter  bool `json:"newsletter" db:"newsletter"`
 
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" db:"email_verified_at"`
+
+	MFASecret       []byte     `json:"-" db:"mfa_secret"`
+	MFAEnabledAt    *time.Time `json:"-" db:"mfa_enabled_at"`
+	MFALastUsedStep int64      `json:"-" db:"mfa_last_used_step"`
+
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
 	Version   int       `json:"-" db:"version"`
 }
 
+// PepperKeyUsage counts the users whose password is peppered with a key, an
+// empty KeyID counts passwords hashed without pepper
+type PepperKeyUsage struct {
+	KeyID          string
+	Users          int64
+	RehashRequired int64
+}
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMFAChallenge      = "mfa_challenge"
+)
+
+type UserToken struct {
+	ID        uuid.UUID  `json:"-" db:"id"`
+	UserID    uuid.UUID  `json:"-" db:"user_id"`
+	Purpose   string     `json:"-" db:"purpose"`
+	TokenHash []byte     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"-" db:"expires_at"`
+	UsedAt    *time.Time `json:"-" db:"used_at"`
+	CreatedAt time.Time  `json:"-" db:"created_at"`
+}
+
+type Session struct {
+	ID         uuid.UUID `json:"-" db:"id"`
+	UserID     uuid.UUID `json:"-" db:"user_id"`
+	TokenHash  []byte    `json:"-" db:"token_hash"`
+	UserAgent  string    `json:"-" db:"user_agent"`
+	IPAddress  string    `json:"-" db:"ip_address"`
+	ExpiresAt  time.Time `json:"-" db:"expires_at"`
+	CreatedAt  time.Time `json:"-" db:"created_at"`
+	LastSeenAt time.Time `json:"-" db:"last_seen_at"`
+}
+
 type RegistrationRequest struct {
 	FirstName   string  `json:"firstName" binding:"required,min=1,max=50"`
 	LastName    string  `json:"lastName" binding:"required,min=1,max=50"`
@@ -41,7 +85,7 @@ type RegistrationRequest struct {
 	Country       string `json:"country" binding:"required,min=1,max=100"`
 
 	Username        string `json:"username" binding:"required,min=6,max=30,alphanum"`
-	Password        string `json:"password" binding:"required,min=8,max=50"`
+	Password        string `json:"password" binding:"required,min=8,max=128"`
 	ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
 
 	AcceptTerms bool `json:"acceptTerms" binding:"required,eq=true"`
@@ -56,6 +100,86 @@ type RegistrationResponse struct {
 	Message   string    `json:"message"`
 }
 
+type StepValidationResponse struct {
+	Step  int  `json:"step"`
+	Valid bool `json:"valid"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type PasswordResetRequest struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8,max=128"`
+	ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type LoginRequest struct {
+	// Login accepts either the username or the email
+	Login    string `json:"login" binding:"required,max=100"`
+	Password string `json:"password" binding:"required,max=128"`
+}
+
+// LoginMetadata describes the client a session is created for
+type LoginMetadata struct {
+	UserAgent string
+	IPAddress string
+}
+
+type UserResponse struct {
+	ID            string    `json:"id"`
+	FirstName     string    `json:"firstName"`
+	LastName      string    `json:"lastName"`
+	Email         string    `json:"email"`
+	Username      string    `json:"username"`
+	EmailVerified bool      `json:"emailVerified"`
+	MFAEnabled    bool      `json:"mfaEnabled"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// AuthSession is the result of a login step. The session token is only sent
+// as a cookie. When MFARequired is set no session exists yet and MFAToken
+// must be exchanged together with a second factor
+type AuthSession struct {
+	Token       string        `json:"-"`
+	ExpiresAt   time.Time     `json:"expiresAt,omitzero"`
+	User        *UserResponse `json:"user,omitempty"`
+	MFARequired bool          `json:"mfaRequired,omitempty"`
+	MFAToken    string        `json:"mfaToken,omitempty"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	// Code is either a TOTP code or one of the recovery codes
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type AvailabilityRequest struct {
 	Value string `json:"value" binding:"required"`
 }
@@ -65,8 +189,94 @@ type AvailabilityResponse struct {
 	Message   string `json:"message"`
 }
 
+type HealthResponse struct {
+	Status    string         `json:"status"`
+	Timestamp time.Time      `json:"timestamp"`
+	Services  map[string]any `json:"services"`
+	Version   string         `json:"version,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Database  string `json:"database"`
+}
+
 type ErrorResponse struct {
-	Code    string            `json:"code"`
-	Message string            `json:"message"`
-	Errors  map[string]string `json:"errors,omitempty"`
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+}
+
+const (
+	StepPersonalInfo   = 1
+	StepAddressDetails = 2
+	StepAccountSetup   = 3
+)
+
+type PersonalInfoStep struct {
+	FirstName   string  `json:"firstName"`
+	LastName    string  `json:"lastName"`
+	Email       string  `json:"email"`
+	PhoneNumber *string `json:"phoneNumber,omitempty"`
+}
+
+type AddressDetailsStep struct {
+	StreetAddress string `json:"streetAddress"`
+	City          string `json:"city"`
+	State         string `json:"state"`
+	Country       string `json:"country"`
+}
+
+// AccountSetupStep intentionally has no password fields, passwords are never
+// persisted in drafts and must be sent again when the draft is finalized
+type AccountSetupStep struct {
+	Username    string `json:"username"`
+	AcceptTerms bool   `json:"acceptTerms"`
+	Newsletter  bool   `json:"newsletter"`
+}
+
+type DraftData struct {
+	PersonalInfo   *PersonalInfoStep   `json:"personalInfo,omitempty"`
+	AddressDetails *AddressDetailsStep `json:"addressDetails,omitempty"`
+	AccountSetup   *AccountSetupStep   `json:"accountSetup,omitempty"`
+}
+
+type Draft struct {
+	ID          uuid.UUID `json:"-" db:"id"`
+	TokenHash   []byte    `json:"-" db:"token_hash"`
+	Data        DraftData `json:"data" db:"data"`
+	CurrentStep int       `json:"currentStep" db:"current_step"`
+	ExpiresAt   time.Time `json:"expiresAt" db:"expires_at"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type DraftResponse struct {
+	ID          string    `json:"id"`
+	CurrentStep int       `json:"currentStep"`
+	Data        DraftData `json:"data"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+type FinalizeDraftRequest struct {
+	DraftID         string `json:"draftId"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+// IdempotencyRecord stores the response of a request sent with an
+// Idempotency-Key, a zero StatusCode means the request is still in progress.
+// Keys are scoped to the route and the client that sent them
+type IdempotencyRecord struct {
+	Key          string    `db:"idempotency_key"`
+	Route        string    `db:"route"`
+	Client       string    `db:"client"`
+	RequestHash  []byte    `db:"request_hash"`
+	StatusCode   int       `db:"status_code"`
+	ContentType  string    `db:"content_type"`
+	ResponseBody []byte    `db:"response_body"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
 }
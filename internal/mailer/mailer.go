@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"context"
+	"time"
+)
+
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+// Mailer delivers transactional emails such as verification links
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,85 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outbox keeps sent messages instead of delivering them, so the email flows
+// can be exercised offline. When a path is set messages are also appended to
+// that file as JSON lines
+type Outbox struct {
+	mu       sync.Mutex
+	path     string
+	messages []Message
+}
+
+func NewMemoryOutbox() *Outbox {
+	return &Outbox{}
+}
+
+func NewFileOutbox(path string) *Outbox {
+	return &Outbox{path: path}
+}
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg.SentAt = time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.path != "" {
+		if err := o.appendToFile(msg); err != nil {
+			return err
+		}
+	}
+
+	o.messages = append(o.messages, msg)
+
+	return nil
+}
+
+// Messages returns a copy of all messages sent through the outbox
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	messages := make([]Message, len(o.messages))
+	copy(messages, o.messages)
+	return messages
+}
+
+// LastMessageTo returns the most recent message sent to the given address
+func (o *Outbox) LastMessageTo(to string) (Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == to {
+			return o.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+func (o *Outbox) appendToFile(msg Message) error {
+	file, err := os.OpenFile(o.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox file: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(msg); err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"multistep-registration/internal/password"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HashingPoolCollector exports the password hashing pool statistics, they
+// are read on every scrape like the database pool ones
+type HashingPoolCollector struct {
+	stat func() password.PoolStat
+
+	running      *prometheus.Desc
+	queued       *prometheus.Desc
+	maxRunning   *prometheus.Desc
+	maxQueued    *prometheus.Desc
+	rejected     *prometheus.Desc
+	completed    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+func NewHashingPoolCollector(pool *password.Pool) *HashingPoolCollector {
+	return &HashingPoolCollector{
+		stat:         pool.Stat,
+		running:      hashingDesc("running", "Hash operations currently running."),
+		queued:       hashingDesc("queued", "Hash operations waiting for a slot."),
+		maxRunning:   hashingDesc("max_running", "Maximum concurrent hash operations."),
+		maxQueued:    hashingDesc("max_queued", "Maximum hash operations waiting for a slot."),
+		rejected:     hashingDesc("rejected_total", "Hash operations rejected because the queue was full."),
+		completed:    hashingDesc("completed_total", "Hash operations run to completion."),
+		waitDuration: hashingDesc("wait_seconds_total", "Time hash operations spent queued."),
+	}
+}
+
+func hashingDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "password_hash_pool", name), help, nil, nil)
+}
+
+func (c *HashingPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.running
+	ch <- c.queued
+	ch <- c.maxRunning
+	ch <- c.maxQueued
+	ch <- c.rejected
+	ch <- c.completed
+	ch <- c.waitDuration
+}
+
+func (c *HashingPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+
+	ch <- prometheus.MustNewConstMetric(c.running, prometheus.GaugeValue, float64(stat.Running))
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(stat.Queued))
+	ch <- prometheus.MustNewConstMetric(c.maxRunning, prometheus.GaugeValue, float64(stat.MaxRunning))
+	ch <- prometheus.MustNewConstMetric(c.maxQueued, prometheus.GaugeValue, float64(stat.MaxQueued))
+	ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(stat.Rejected))
+	ch <- prometheus.MustNewConstMetric(c.completed, prometheus.CounterValue, float64(stat.Completed))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stat.WaitDuration.Seconds())
+}
@@ -0,0 +1,113 @@
+// Package metrics defines the Prometheus metrics of the API. Every method is
+// safe to call on a nil *Metrics, so metrics stay optional for callers
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "registration"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration      *prometheus.HistogramVec
+	registrations        *prometheus.CounterVec
+	validationFailures   *prometheus.CounterVec
+	passwordHashDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Registration attempts by result and error code.",
+		}, []string{"result", "code"}),
+		validationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "validation_failures_total",
+			Help:      "Validation errors by field and validator.",
+		}, []string{"field", "validator"}),
+		passwordHashDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "password_hash_duration_seconds",
+			Help:      "Duration of password hashing and verification by algorithm.",
+			// Password hashing is slow by design, the default buckets stop too early
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"algorithm", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.registrations,
+		m.validationFailures,
+		m.passwordHashDuration,
+	)
+
+	return m
+}
+
+// Register adds a collector such as the database pool collector
+func (m *Metrics) Register(collector prometheus.Collector) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.Register(collector)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RegistrationSucceeded() {
+	if m == nil {
+		return
+	}
+	m.registrations.WithLabelValues("succeeded", "").Inc()
+}
+
+func (m *Metrics) RegistrationFailed(code string) {
+	if m == nil {
+		return
+	}
+	m.registrations.WithLabelValues("failed", code).Inc()
+}
+
+func (m *Metrics) ValidationFailed(field, validator string) {
+	if m == nil {
+		return
+	}
+	m.validationFailures.WithLabelValues(field, validator).Inc()
+}
+
+// ObservePasswordHash records a password hash operation, either hash or verify
+func (m *Metrics) ObservePasswordHash(algorithm, operation string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.passwordHashDuration.WithLabelValues(algorithm, operation).Observe(duration.Seconds())
+}
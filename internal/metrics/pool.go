@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports the pgxpool statistics, they are read on every
+// scrape so the values are always current
+type PoolCollector struct {
+	stat func() *pgxpool.Stat
+
+	totalConns        *prometheus.Desc
+	idleConns         *prometheus.Desc
+	acquiredConns     *prometheus.Desc
+	constructingConns *prometheus.Desc
+	maxConns          *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	return &PoolCollector{
+		stat:              pool.Stat,
+		totalConns:        poolDesc("total_connections", "Connections currently in the pool."),
+		idleConns:         poolDesc("idle_connections", "Idle connections in the pool."),
+		acquiredConns:     poolDesc("acquired_connections", "Connections currently in use."),
+		constructingConns: poolDesc("constructing_connections", "Connections being established."),
+		maxConns:          poolDesc("max_connections", "Maximum size of the pool."),
+		emptyAcquires:     poolDesc("empty_acquire_total", "Acquires that had to wait for a connection."),
+	}
+}
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.acquiredConns
+	ch <- c.constructingConns
+	ch <- c.maxConns
+	ch <- c.emptyAcquires
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+}
@@ -0,0 +1,146 @@
+// Package openapi builds OpenAPI 3.1 documents. Schemas are generated from
+// the Go request and response types, so the document follows the code
+package openapi
+
+import (
+	"multistep-registration/internal/validation"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+// Schema is a JSON Schema 2020-12 schema, the dialect of OpenAPI 3.1
+type Schema = validation.Schema
+
+type Document struct {
+	OpenAPI           string              `json:"openapi"`
+	Info              Info                `json:"info"`
+	JSONSchemaDialect string              `json:"jsonSchemaDialect"`
+	Paths             map[string]PathItem `json:"paths"`
+	Components        Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to their operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func NewDocument(info Info) *Document {
+	return &Document{
+		OpenAPI:           Version,
+		Info:              info,
+		JSONSchemaDialect: validation.JSONSchemaDialect,
+		Paths:             make(map[string]PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+	}
+}
+
+// AddOperation adds the operation for a gin route, e.g. GET /api/v1/drafts/:id
+func (d *Document) AddOperation(method, route string, op *Operation) {
+	path := Path(route)
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// HasOperation reports whether the document describes the gin route
+func (d *Document) HasOperation(method, route string) bool {
+	_, ok := d.Paths[Path(route)][strings.ToLower(method)]
+	return ok
+}
+
+// Path converts the params of a gin route to OpenAPI templates, :id becomes {id}
+func Path(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// PathParameters lists the params of a gin route as required path parameters
+func PathParameters(route string) []Parameter {
+	var params []Parameter
+	for _, segment := range strings.Split(route, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, Parameter{
+				Name:     segment[1:],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	return params
+}
+
+// StatusKey is the key of a status code in Operation.Responses
+func StatusKey(status int) string {
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,163 @@
+package openapi
+
+import (
+	"multistep-registration/internal/validation"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// Ref returns a reference to the component schema of the struct type of v,
+// generating the component on first use
+func (d *Document) Ref(v any) *Schema {
+	t := indirect(reflect.TypeOf(v))
+	return d.NamedRef(t.Name(), v)
+}
+
+// NamedRef is Ref with an explicit component name, for types whose Go name
+// is ambiguous outside their package
+func (d *Document) NamedRef(name string, v any) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := d.Components.Schemas[name]; ok {
+		return ref
+	}
+
+	// Reserve the name first, self referencing types would recurse otherwise
+	d.Components.Schemas[name] = nil
+	d.Components.Schemas[name] = d.objectSchema(indirect(reflect.TypeOf(v)))
+	return ref
+}
+
+// Define registers a prepared component schema and returns a reference to it
+func (d *Document) Define(name string, schema *Schema) *Schema {
+	d.Components.Schemas[name] = schema
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// objectSchema describes a struct from its json and binding tags. Request
+// types mark their required fields with binding tags, the other types always
+// send the fields that are neither pointers nor omitted when empty
+func (d *Document) objectSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema, t.NumField()),
+	}
+	hasBinding := hasBindingTags(t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, required := d.fieldSchema(field)
+		if !hasBinding {
+			required = field.Type.Kind() != reflect.Pointer &&
+				!strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero")
+		}
+
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+func (d *Document) fieldSchema(field reflect.StructField) (*Schema, bool) {
+	t := indirect(field.Type)
+	if isScalar(t) {
+		property, required, _ := validation.FieldSchema(field)
+		applyFormat(property, t)
+		return property, required
+	}
+
+	_, required, _ := validation.FieldSchema(field)
+	return d.typeSchema(t), required
+}
+
+func (d *Document) typeSchema(t reflect.Type) *Schema {
+	t = indirect(t)
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return d.NamedRef(t.Name(), reflect.New(t).Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.typeSchema(t.Elem())}
+	case reflect.Interface:
+		// Any JSON value
+		return &Schema{}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// applyFormat fixes the scalar types FieldSchema does not know about
+func applyFormat(schema *Schema, t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		schema.Type = "number"
+	case reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema.Type = "integer"
+	}
+}
+
+func hasBindingTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("binding"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,64 @@
+package password
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const AlgorithmBcrypt = "bcrypt"
+
+// bcryptMaxLength is the number of bytes bcrypt reads, longer passwords
+// would be truncated silently by other implementations
+const bcryptMaxLength = 72
+
+// bcryptHasher keeps the modular crypt format of bcrypt, $2a$<cost>$..., it
+// predates PHC strings but also carries the algorithm and cost
+type bcryptHasher struct {
+	cost int
+}
+
+func NewBcrypt(cost int) Hasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Algorithm() string {
+	return AlgorithmBcrypt
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	if len(password) > bcryptMaxLength {
+		return "", ErrTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(encoded, password string) (bool, error) {
+	if len(password) > bcryptMaxLength {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, errors.Join(ErrMalformedHash, err)
+	}
+}
+
+func (h *bcryptHasher) MaxLength() int {
+	return bcryptMaxLength
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
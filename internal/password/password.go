@@ -0,0 +1,179 @@
+// Package password hashes passwords into self describing PHC strings, the
+// algorithm and its parameters are stored with the hash so they can change
+// while existing hashes keep verifying
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrTooLong          = errors.New("password is too long for the hashing algorithm")
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Hasher is one password hashing algorithm with its configured parameters
+type Hasher interface {
+	// Algorithm is the PHC identifier of the algorithm, e.g. argon2id
+	Algorithm() string
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches an encoded hash of this algorithm
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether an encoded hash of this algorithm was made
+	// with other parameters than the configured ones
+	NeedsRehash(encoded string) bool
+	// MaxLength is the longest password in bytes Hash accepts, 0 when there
+	// is no limit
+	MaxLength() int
+}
+
+// Algorithm returns the PHC identifier of an encoded hash. The modular crypt
+// prefixes of bcrypt, $2a$, $2b$ and $2y$, are reported as bcrypt
+func Algorithm(encoded string) string {
+	if !strings.HasPrefix(encoded, "$") {
+		return ""
+	}
+	id, _, _ := strings.Cut(encoded[1:], "$")
+	switch id {
+	case "2a", "2b", "2y":
+		return AlgorithmBcrypt
+	}
+	return id
+}
+
+// Hash is an encoded password hash and the pepper key it was made with
+type Hash struct {
+	Encoded string
+	// PepperKeyID is empty when the password was hashed without a pepper
+	PepperKeyID string
+}
+
+// Policy hashes new passwords with the preferred hasher and verifies hashes
+// of every known hasher, so changing the configuration keeps old hashes valid
+type Policy struct {
+	preferred Hasher
+	hashers   map[string]Hasher
+	// pepper is nil when passwords are hashed without a pepper
+	pepper *Pepper
+}
+
+// NewPolicy creates a policy preferring the first hasher, others only verify
+func NewPolicy(preferred Hasher, others ...Hasher) *Policy {
+	p := &Policy{
+		preferred: preferred,
+		hashers:   map[string]Hasher{preferred.Algorithm(): preferred},
+	}
+	for _, hasher := range others {
+		if _, ok := p.hashers[hasher.Algorithm()]; !ok {
+			p.hashers[hasher.Algorithm()] = hasher
+		}
+	}
+	return p
+}
+
+// WithPepper applies the pepper to new hashes, hashes of its other keys and
+// hashes made without a pepper keep verifying and are reported for rehash
+func (p *Policy) WithPepper(pepper *Pepper) *Policy {
+	p.pepper = pepper
+	return p
+}
+
+// Preferred is the algorithm new hashes are made with
+func (p *Policy) Preferred() string {
+	return p.preferred.Algorithm()
+}
+
+// MaxLength is the longest password in bytes new hashes accept, 0 when there
+// is no limit. The pepper shortens every password to its HMAC first
+func (p *Policy) MaxLength() int {
+	if p.pepper != nil {
+		return 0
+	}
+	return p.preferred.MaxLength()
+}
+
+func (p *Policy) Hash(password string) (Hash, error) {
+	var hash Hash
+	if p.pepper != nil {
+		hash.PepperKeyID = p.pepper.CurrentKeyID()
+	}
+
+	password, err := p.peppered(hash.PepperKeyID, password)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if hash.Encoded, err = p.preferred.Hash(password); err != nil {
+		return Hash{}, err
+	}
+	return hash, nil
+}
+
+// Verify checks the password against the hash. When it matches, rehash
+// reports whether the hash should be replaced by a fresh one because its
+// algorithm, parameters or pepper key are outdated
+func (p *Policy) Verify(hash Hash, password string) (match bool, rehash bool, err error) {
+	algorithm := Algorithm(hash.Encoded)
+	hasher, ok := p.hashers[algorithm]
+	if !ok {
+		return false, false, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+
+	password, err = p.peppered(hash.PepperKeyID, password)
+	if err != nil {
+		return false, false, err
+	}
+
+	match, err = hasher.Verify(hash.Encoded, password)
+	if err != nil || !match {
+		return false, false, err
+	}
+
+	rehash = hasher != p.preferred || hasher.NeedsRehash(hash.Encoded) || hash.PepperKeyID != p.currentPepperKeyID()
+	return true, rehash, nil
+}
+
+func (p *Policy) peppered(keyID, password string) (string, error) {
+	if keyID == "" {
+		return password, nil
+	}
+	if p.pepper == nil {
+		return "", fmt.Errorf("%w: %q, no pepper is configured", ErrUnknownPepperKey, keyID)
+	}
+	return p.pepper.apply(keyID, password)
+}
+
+func (p *Policy) currentPepperKeyID() string {
+	if p.pepper == nil {
+		return ""
+	}
+	return p.pepper.CurrentKeyID()
+}
+
+// NewPolicyFor prefers the named algorithm, bcrypt or argon2id, and keeps
+// verifying hashes of the other one
+func NewPolicyFor(algorithm string, bcryptCost int, argon2Params Argon2Params) (*Policy, error) {
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if argon2Params.Iterations == 0 || argon2Params.Parallelism == 0 || argon2Params.MemoryKiB < 8*uint32(argon2Params.Parallelism) {
+		return nil, errors.New("argon2 iterations and parallelism must be positive and memory at least 8 KiB per lane")
+	}
+
+	bcryptHasher := NewBcrypt(bcryptCost)
+	argon2Hasher := NewArgon2id(argon2Params)
+
+	switch algorithm {
+	case AlgorithmBcrypt:
+		return NewPolicy(bcryptHasher, argon2Hasher), nil
+	case AlgorithmArgon2id:
+		return NewPolicy(argon2Hasher, bcryptHasher), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+}
@@ -0,0 +1,49 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPolicyMaxLength(t *testing.T) {
+	pepper, err := LoadPepper("", []string{"k1:" + strings.Repeat("A", 43) + "="}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Argon2Params{MemoryKiB: 64, Iterations: 1, Parallelism: 1}
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		want   int
+	}{
+		{"bcrypt", NewPolicy(NewBcrypt(bcrypt.MinCost)), 72},
+		{"argon2id", NewPolicy(NewArgon2id(params), NewBcrypt(bcrypt.MinCost)), 0},
+		// The pepper hashes the HMAC of the password, whatever its length
+		{"peppered bcrypt", NewPolicy(NewBcrypt(bcrypt.MinCost)).WithPepper(pepper), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MaxLength(); got != tt.want {
+				t.Fatalf("MaxLength() = %d, want %d", got, tt.want)
+			}
+
+			longest := 200
+			if tt.want > 0 {
+				longest = tt.want
+			}
+			if _, err := tt.policy.Hash(strings.Repeat("a", longest)); err != nil {
+				t.Errorf("Hash() of %d bytes error = %v", longest, err)
+			}
+			if tt.want > 0 {
+				if _, err := tt.policy.Hash(strings.Repeat("a", tt.want+1)); !errors.Is(err, ErrTooLong) {
+					t.Errorf("Hash() of %d bytes error = %v, want %v", tt.want+1, err, ErrTooLong)
+				}
+			}
+		})
+	}
+}
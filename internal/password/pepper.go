@@ -0,0 +1,133 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnknownPepperKey = errors.New("unknown pepper key")
+
+// pepperMinKeyLength is the HMAC-SHA256 output size, shorter keys weaken the MAC
+const pepperMinKeyLength = 32
+
+// Pepper HMACs passwords with a server side key before they are hashed, so
+// the hashes alone are useless without the key. Every key has an ID stored
+// with the hash, old keys stay loaded until no hash uses them anymore
+type Pepper struct {
+	currentKeyID string
+	keys         map[string][]byte
+}
+
+// NewPepper creates a pepper applying currentKeyID to new hashes. The other
+// keys only verify existing hashes
+func NewPepper(currentKeyID string, keys map[string][]byte) (*Pepper, error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("%w: current key %q is not loaded", ErrUnknownPepperKey, currentKeyID)
+	}
+	for id, key := range keys {
+		if id == "" {
+			return nil, errors.New("pepper key id must not be empty")
+		}
+		if len(key) < pepperMinKeyLength {
+			return nil, fmt.Errorf("pepper key %q must be at least %d bytes", id, pepperMinKeyLength)
+		}
+	}
+
+	return &Pepper{currentKeyID: currentKeyID, keys: keys}, nil
+}
+
+func (p *Pepper) CurrentKeyID() string {
+	return p.currentKeyID
+}
+
+// apply returns the base64 HMAC of the password. It is 44 bytes long, which
+// also keeps long passwords within the 72 bytes bcrypt reads
+func (p *Pepper) apply(keyID, password string) (string, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownPepperKey, keyID)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// LoadPepper decodes the base64 pepper keys given inline as "id:key" and the
+// key files of dir, named by key ID. Dot files are skipped so mounted secret
+// directories can be read as is. When currentKeyID is empty the only loaded
+// key is current. It returns nil when no key is configured
+func LoadPepper(currentKeyID string, inline []string, dir string) (*Pepper, error) {
+	encoded := make(map[string]string, len(inline))
+	for i, entry := range inline {
+		// The entry is not quoted in the error, it may be a bare key
+		id, key, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("inline pepper key %d must be formatted as id:key", i+1)
+		}
+		encoded[id] = key
+	}
+
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pepper keys: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read pepper key: %w", err)
+			}
+			if _, ok := encoded[entry.Name()]; ok {
+				return nil, fmt.Errorf("pepper key %q is configured twice", entry.Name())
+			}
+			encoded[entry.Name()] = string(content)
+		}
+	}
+
+	if len(encoded) == 0 {
+		if currentKeyID != "" {
+			return nil, fmt.Errorf("%w: current key %q is not loaded", ErrUnknownPepperKey, currentKeyID)
+		}
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte, len(encoded))
+	for id, value := range encoded {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("pepper key %q is not valid base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	if currentKeyID == "" {
+		if len(keys) > 1 {
+			return nil, errors.New("the current pepper key must be set when several keys are loaded")
+		}
+		for id := range keys {
+			currentKeyID = id
+		}
+	}
+
+	return NewPepper(currentKeyID, keys)
+}
+
+// GeneratePepperKey returns a new random base64 encoded pepper key
+func GeneratePepperKey() (string, error) {
+	key := make([]byte, pepperMinKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate pepper key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
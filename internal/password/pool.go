@@ -0,0 +1,96 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBusy is returned when the hashing queue is full, the caller should ask
+// the client to retry later rather than wait
+var ErrBusy = errors.New("password hashing is at capacity")
+
+// Pool bounds the number of concurrent hash operations. Operations beyond
+// the limit wait in a queue of bounded depth, when the queue is full they are
+// rejected right away so a burst of logins or signups cannot pin every core
+// and starve the rest of the API
+type Pool struct {
+	// admitted holds a token per running or queued operation
+	admitted chan struct{}
+	// running holds a token per running operation
+	running chan struct{}
+
+	queued       atomic.Int64
+	rejected     atomic.Uint64
+	completed    atomic.Uint64
+	waitDuration atomic.Int64
+}
+
+// PoolStat is a snapshot of the pool, the counters are cumulative
+type PoolStat struct {
+	MaxRunning   int
+	MaxQueued    int
+	Running      int
+	Queued       int
+	Rejected     uint64
+	Completed    uint64
+	WaitDuration time.Duration
+}
+
+// NewPool creates a pool running up to concurrency operations at once with
+// up to queueDepth more waiting for a slot
+func NewPool(concurrency, queueDepth int) (*Pool, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("hashing concurrency must be at least 1, got %d", concurrency)
+	}
+	if queueDepth < 0 {
+		return nil, fmt.Errorf("hashing queue depth must not be negative, got %d", queueDepth)
+	}
+
+	return &Pool{
+		admitted: make(chan struct{}, concurrency+queueDepth),
+		running:  make(chan struct{}, concurrency),
+	}, nil
+}
+
+// Do runs fn once a slot is free. It returns ErrBusy without running fn when
+// the queue is full and the context error when the context ends while queued
+func (p *Pool) Do(ctx context.Context, fn func()) error {
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		p.rejected.Add(1)
+		return ErrBusy
+	}
+	defer func() { <-p.admitted }()
+
+	p.queued.Add(1)
+	start := time.Now()
+	select {
+	case p.running <- struct{}{}:
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		return ctx.Err()
+	}
+	p.queued.Add(-1)
+	p.waitDuration.Add(int64(time.Since(start)))
+	defer func() { <-p.running }()
+
+	fn()
+	p.completed.Add(1)
+	return nil
+}
+
+func (p *Pool) Stat() PoolStat {
+	return PoolStat{
+		MaxRunning:   cap(p.running),
+		MaxQueued:    cap(p.admitted) - cap(p.running),
+		Running:      len(p.running),
+		Queued:       int(p.queued.Load()),
+		Rejected:     p.rejected.Load(),
+		Completed:    p.completed.Load(),
+		WaitDuration: time.Duration(p.waitDuration.Load()),
+	}
+}
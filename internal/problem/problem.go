@@ -0,0 +1,139 @@
+// Package problem renders error responses as RFC 9457 problem details. The
+// legacy ErrorResponse shapes stay available to clients that ask for
+// application/json explicitly
+package problem
+
+import (
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/context"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/requestid"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ContentType       = "application/problem+json"
+	legacyContentType = "application/json"
+
+	// typeBase prefixes the type URIs, they are relative references resolved
+	// against the API's own URL
+	typeBase = "/problems/"
+)
+
+// titles are the short summaries of every error code, they must not change
+// between occurrences of the same type
+var titles = map[string]string{
+	constants.CodeValidationError:      "Validation failed",
+	constants.CodeDuplicateError:       "Resource already exists",
+	constants.CodeInternalError:        "Internal server error",
+	constants.CodeNotFound:             "Resource not found",
+	constants.CodeInvalidToken:         "Invalid or expired token",
+	constants.CodeInvalidLogin:         "Invalid credentials",
+	constants.CodeUnauthorized:         "Authentication required",
+	constants.CodeInvalidMFA:           "Invalid MFA code",
+	constants.CodeMFAStateError:        "MFA state conflict",
+	constants.CodeIdempotencyKeyReused: "Idempotency key reused",
+	constants.CodeRequestInProgress:    "Request in progress",
+	constants.CodeRateLimited:          "Too many requests",
+	constants.CodeMethodNotAllowed:     "Method not allowed",
+	constants.CodeServiceBusy:          "Service busy",
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Details is an RFC 9457 problem, code, requestId and errors are extension members
+type Details struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// New builds the problem for one of the constants.Code* error codes
+func New(status int, code, detail string) *Details {
+	title, ok := titles[code]
+	if !ok {
+		return &Details{
+			Type:   "about:blank",
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: detail,
+			Code:   code,
+		}
+	}
+
+	return &Details{
+		Type:   TypeURI(code),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// TypeURI returns the type of an error code, e.g. /problems/validation-error
+func TypeURI(code string) string {
+	return typeBase + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+func (d *Details) WithErrors(errors []FieldError) *Details {
+	d.Errors = errors
+	return d
+}
+
+// Respond writes the problem in the format negotiated from the Accept header
+func Respond(c *gin.Context, d *Details) {
+	d.Instance = c.Request.URL.Path
+	d.RequestID = requestid.FromContext(c.Request.Context())
+	context.SetErrorCode(c, d.Code)
+
+	c.Writer.Header().Add("Vary", "Accept")
+
+	if c.NegotiateFormat(ContentType, legacyContentType) == legacyContentType {
+		respondLegacy(c, d)
+		return
+	}
+
+	c.Render(d.Status, problemRender{details: d})
+}
+
+// Abort responds with the problem and stops the handler chain
+func Abort(c *gin.Context, d *Details) {
+	Respond(c, d)
+	c.Abort()
+}
+
+// LegacyValidationResponse is the shape validation errors had before
+// problem details, other errors used domain.ErrorResponse
+type LegacyValidationResponse struct {
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors"`
+	RequestID string       `json:"requestId"`
+}
+
+func respondLegacy(c *gin.Context, d *Details) {
+	if len(d.Errors) > 0 {
+		c.JSON(d.Status, LegacyValidationResponse{
+			Code:      d.Code,
+			Errors:    d.Errors,
+			RequestID: d.RequestID,
+		})
+		return
+	}
+
+	c.JSON(d.Status, domain.ErrorResponse{
+		Code:      d.Code,
+		Message:   d.Detail,
+		RequestID: d.RequestID,
+	})
+}
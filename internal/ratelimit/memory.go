@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements token buckets in process memory. A bucket holds up
+// to Limit.Requests tokens and refills at Requests per Period, so short
+// bursts are allowed while the average rate stays within the limit
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket is refilled completely and can be dropped
+	full time.Time
+}
+
+// sweepInterval bounds how often idle buckets are removed
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / rate
+		b.full = now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+		return Result{
+			Allowed:    false,
+			RetryAfter: time.Duration(wait * float64(time.Second)),
+		}, nil
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+
+	return Result{
+		Allowed:   true,
+		Remaining: int(b.tokens),
+	}, nil
+}
+
+// sweep drops buckets that have refilled completely, they behave exactly
+// like a missing bucket so memory only grows with active clients
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
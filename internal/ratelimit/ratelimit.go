@@ -0,0 +1,40 @@
+// Package ratelimit limits how often a client can call an endpoint, the
+// counters live in a pluggable Store
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limit allows Requests per Period for a single key
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Validate rejects limits the stores can't compute a rate for
+func (l Limit) Validate() error {
+	if l.Requests < 1 {
+		return fmt.Errorf("requests must be at least 1, got %d", l.Requests)
+	}
+	if l.Period < time.Second {
+		return fmt.Errorf("period must be at least 1s, got %s", l.Period)
+	}
+	return nil
+}
+
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long the caller has to wait before the next request
+	// is allowed, it is zero when the request was allowed
+	RetryAfter time.Duration
+}
+
+// Store counts requests per key, implementations shared between replicas
+// make the limit global instead of per process
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,232 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// respClient is a minimal client for the Redis serialization protocol, it
+// only covers what the limiter needs so it works with any RESP server
+type respClient struct {
+	addr     string
+	password string
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	idle []*respConn
+}
+
+type respConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// respError is an error reply sent by the server
+type respError string
+
+func (e respError) Error() string {
+	return string(e)
+}
+
+const maxIdleConns = 8
+
+func newRESPClient(addr, password string, timeout time.Duration) *respClient {
+	return &respClient{
+		addr:     addr,
+		password: password,
+		timeout:  timeout,
+	}
+}
+
+// pipeline sends all commands at once and returns their replies in order
+func (c *respClient) pipeline(ctx context.Context, commands ...[]string) ([]any, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := conn.do(ctx, c.timeout, commands...)
+	if err != nil {
+		var replyErr respError
+		if !errors.As(err, &replyErr) {
+			// The connection state is unknown after an I/O error
+			conn.conn.Close()
+			return nil, err
+		}
+	}
+
+	c.put(conn)
+	return replies, err
+}
+
+func (c *respClient) get(ctx context.Context) (*respConn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: c.timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.addr, err)
+	}
+
+	conn := &respConn{
+		conn:   netConn,
+		reader: bufio.NewReader(netConn),
+		writer: bufio.NewWriter(netConn),
+	}
+
+	if c.password != "" {
+		if _, err := conn.do(ctx, c.timeout, []string{"AUTH", c.password}); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *respClient) put(conn *respConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.idle) >= maxIdleConns {
+		conn.conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// do returns the first error reply along with all replies, so the
+// connection can be reused as every reply has been read
+func (c *respConn) do(ctx context.Context, timeout time.Duration, commands ...[]string) ([]any, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	for _, args := range commands {
+		if err := writeCommand(c.writer, args); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	replies := make([]any, len(commands))
+	for i := range commands {
+		reply, err := readReply(c.reader)
+		if err != nil {
+			var replyErr respError
+			if !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		replies[i] = reply
+	}
+
+	return replies, firstErr
+}
+
+func writeCommand(w *bufio.Writer, args []string) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readReply reads a single reply. Simple strings and bulk strings are
+// returned as string, integers as int64, nil bulk strings as nil and arrays
+// as []any. An error reply is returned as respError
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch prefix, payload := line[0], line[1:]; prefix {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, respError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length %q", payload)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", payload)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, count)
+		for i := range items {
+			// Error replies nested in an array are kept as values so the
+			// whole array is consumed
+			item, err := readReply(r)
+			var replyErr respError
+			if errors.As(err, &replyErr) {
+				item = replyErr
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply type %q", prefix)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed reply line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
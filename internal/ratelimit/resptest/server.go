@@ -0,0 +1,268 @@
+// Package resptest provides an in-process RESP server, so code talking to
+// Redis compatible stores can be exercised without running one
+package resptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server implements the subset of commands the rate limiter uses: PING,
+// AUTH, GET, SET, INCR, PEXPIRE and DEL
+type Server struct {
+	listener net.Listener
+	password string
+
+	mu     sync.Mutex
+	values map[string]entry
+	conns  map[net.Conn]struct{}
+	wg     sync.WaitGroup
+}
+
+type entry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewServer starts a server on a random local port, AUTH is required when
+// password is not empty
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		password: password,
+		values:   make(map[string]entry),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and drops open connections, which lets tests
+// simulate an outage
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	authenticated := s.password == ""
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(writer, "-ERR %s\r\n", err)
+				writer.Flush()
+			}
+			return
+		}
+
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "AUTH":
+			if len(args) == 2 && args[1] == s.password {
+				authenticated = true
+				writer.WriteString("+OK\r\n")
+			} else {
+				writer.WriteString("-WRONGPASS invalid password\r\n")
+			}
+		case !authenticated:
+			writer.WriteString("-NOAUTH Authentication required.\r\n")
+		default:
+			writer.WriteString(s.exec(name, args[1:]))
+		}
+
+		// Pipelined commands are answered together
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) exec(name string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		e, ok := s.get(args[0])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(e.value), e.value)
+	case "SET":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		s.values[args[0]] = entry{value: args[1]}
+		return "+OK\r\n"
+	case "INCR":
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
+		e, _ := s.get(args[0])
+		current := int64(0)
+		if e.value != "" {
+			var err error
+			if current, err = strconv.ParseInt(e.value, 10, 64); err != nil {
+				return "-ERR value is not an integer or out of range\r\n"
+			}
+		}
+		current++
+		e.value = strconv.FormatInt(current, 10)
+		s.values[args[0]] = e
+		return fmt.Sprintf(":%d\r\n", current)
+	case "PEXPIRE":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		e, ok := s.get(args[0])
+		if !ok {
+			return ":0\r\n"
+		}
+		e.expiresAt = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		s.values[args[0]] = e
+		return ":1\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args {
+			if _, ok := s.get(key); ok {
+				delete(s.values, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", name)
+	}
+}
+
+// get returns the entry and drops it when it has expired
+func (s *Server) get(key string) (entry, bool) {
+	e, ok := s.values[key]
+	if !ok {
+		return entry{}, false
+	}
+	if !e.expiresAt.IsZero() && !time.Now().Before(e.expiresAt) {
+		delete(s.values, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+func wrongArgs(name string) string {
+	return fmt.Sprintf("-ERR wrong number of arguments for '%s' command\r\n", strings.ToLower(name))
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid array length %q", line[1:])
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line[1:])
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
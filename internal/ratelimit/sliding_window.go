@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RESPConfig configures the connection to a RESP server such as Redis,
+// Valkey or KeyDB
+type RESPConfig struct {
+	Addr     string
+	Password string
+	// Timeout bounds connecting and every round trip, it should be short as
+	// requests wait for the limiter
+	Timeout time.Duration
+	// KeyPrefix namespaces the counters when the server is shared
+	KeyPrefix string
+}
+
+// RESPStore keeps sliding window counters on a RESP server, so the limit is
+// shared by every replica. It counts requests in fixed windows and weights
+// the previous window by how much of it still overlaps the sliding window,
+// which needs two counters per key instead of one entry per request
+type RESPStore struct {
+	client    *respClient
+	keyPrefix string
+	now       func() time.Time
+
+	// warnMu guards lastWarning, failures are logged at most once per
+	// warnInterval so an outage doesn't flood the logs
+	warnMu      sync.Mutex
+	lastWarning time.Time
+}
+
+const warnInterval = time.Minute
+
+func NewRESPStore(cfg RESPConfig) *RESPStore {
+	return &RESPStore{
+		client:    newRESPClient(cfg.Addr, cfg.Password, cfg.Timeout),
+		keyPrefix: cfg.KeyPrefix,
+		now:       time.Now,
+	}
+}
+
+// Allow fails open, when the server can't be reached the request is allowed
+// and a warning is logged
+func (s *RESPStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	result, err := s.allow(ctx, key, limit)
+	if err != nil {
+		s.warn(err)
+		return Result{Allowed: true, Remaining: limit.Requests}, nil
+	}
+	return result, nil
+}
+
+func (s *RESPStore) allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+	period := limit.Period.Milliseconds()
+	window := now.UnixMilli() / period
+	elapsed := now.UnixMilli() - window*period
+
+	currentKey := s.windowKey(key, period, window)
+	previousKey := s.windowKey(key, period, window-1)
+
+	replies, err := s.client.pipeline(ctx,
+		[]string{"INCR", currentKey},
+		// The counter is read as previous window during the next period
+		[]string{"PEXPIRE", currentKey, strconv.FormatInt(2*period, 10)},
+		[]string{"GET", previousKey},
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	current, ok := replies[0].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected INCR reply %v", replies[0])
+	}
+
+	var previous int64
+	if reply, ok := replies[2].(string); ok {
+		if previous, err = strconv.ParseInt(reply, 10, 64); err != nil {
+			return Result{}, fmt.Errorf("unexpected counter value %q", reply)
+		}
+	}
+
+	requests := float64(limit.Requests)
+	overlap := 1 - float64(elapsed)/float64(period)
+	count := float64(previous)*overlap + float64(current)
+
+	if count > requests {
+		return Result{
+			Allowed:    false,
+			RetryAfter: retryAfter(float64(previous), float64(current), requests, elapsed, period),
+		}, nil
+	}
+
+	return Result{
+		Allowed:   true,
+		Remaining: int(requests - count),
+	}, nil
+}
+
+// retryAfter estimates when one more request fits into the window assuming
+// no other requests are made until then. Rejected requests are counted too,
+// so a client that keeps hammering stays blocked
+func retryAfter(previous, current, requests float64, elapsed, period int64) time.Duration {
+	var wait float64
+	if current < requests {
+		// The previous window's weight has to decay within this window
+		wait = float64(period)*(1-(requests-1-current)/previous) - float64(elapsed)
+	} else {
+		// Wait for the next window, where this one is the previous one
+		wait = float64(period-elapsed) + float64(period)*(1-(requests-1)/current)
+	}
+	return time.Duration(math.Max(wait, 0)) * time.Millisecond
+}
+
+func (s *RESPStore) windowKey(key string, period, window int64) string {
+	return fmt.Sprintf("%s%s:%d:%d", s.keyPrefix, key, period, window)
+}
+
+func (s *RESPStore) warn(err error) {
+	s.warnMu.Lock()
+	defer s.warnMu.Unlock()
+
+	if now := s.now(); now.Sub(s.lastWarning) >= warnInterval {
+		s.lastWarning = now
+		slog.Warn("rate limiter store unavailable, allowing requests", "error", err)
+	}
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/ratelimit/resptest"
+)
+
+func newTestRESPServer(t *testing.T, password string) *resptest.Server {
+	t.Helper()
+	server, err := resptest.NewServer(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func newTestRESPStore(addr, password string, now *time.Time) *RESPStore {
+	store := NewRESPStore(RESPConfig{Addr: addr, Password: password, Timeout: time.Second, KeyPrefix: "test:"})
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestRESPStoreSlidesAcrossWindowBoundary(t *testing.T) {
+	server := newTestRESPServer(t, "")
+	// The start of a 10s window
+	start := time.UnixMilli(1_700_000_000_000)
+	now := start
+	store := newTestRESPStore(server.Addr(), "", &now)
+	limit := Limit{Requests: 4, Period: 10 * time.Second}
+	ctx := context.Background()
+
+	now = start.Add(9 * time.Second)
+	for i := range limit.Requests {
+		result, err := store.allow(ctx, "client", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != limit.Requests-1-i {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, result, limit.Requests-1-i)
+		}
+	}
+
+	// A fixed window would reset here, the previous window still counts fully
+	now = start.Add(10 * time.Second)
+	result, err := store.allow(ctx, "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.RetryAfter != 5*time.Second {
+		t.Fatalf("request after the boundary = %+v, want rejected with a 5s Retry-After", result)
+	}
+
+	// Half of the previous window has slid out
+	now = start.Add(15 * time.Second)
+	result, err = store.allow(ctx, "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("request after the Retry-After = %+v, want allowed with 0 remaining", result)
+	}
+
+	// Other keys have their own counters
+	result, err = store.allow(ctx, "other", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != limit.Requests-1 {
+		t.Fatalf("request of another key = %+v, want a fresh window", result)
+	}
+}
+
+func TestRESPStoreAuthenticates(t *testing.T) {
+	server := newTestRESPServer(t, "secret")
+	now := time.Now()
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{name: "valid password", password: "secret"},
+		{name: "wrong password", password: "guess", wantErr: "WRONGPASS"},
+		{name: "no password", password: "", wantErr: "NOAUTH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestRESPStore(server.Addr(), tt.password, &now)
+
+			result, err := store.allow(context.Background(), tt.name, limit)
+			if tt.wantErr == "" {
+				if err != nil || !result.Allowed || result.Remaining != 1 {
+					t.Errorf("allow() = %+v, %v, want allowed with 1 remaining", result, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("allow() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRESPStoreTimesOut(t *testing.T) {
+	// Accepts connections and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	store := NewRESPStore(RESPConfig{Addr: listener.Addr().String(), Timeout: 50 * time.Millisecond})
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	started := time.Now()
+	_, err = store.allow(context.Background(), "client", limit)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("allow() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("allow() took %s, want it bounded by the 50ms timeout", elapsed)
+	}
+
+	result, err := store.Allow(context.Background(), "client", limit)
+	if err != nil || !result.Allowed {
+		t.Errorf("Allow() = %+v, %v, want the request allowed", result, err)
+	}
+}
+
+func TestRESPStoreFailsOpenWhenServerCloses(t *testing.T) {
+	server := newTestRESPServer(t, "")
+	now := time.Now()
+	store := newTestRESPStore(server.Addr(), "", &now)
+	limit := Limit{Requests: 1, Period: time.Minute}
+	ctx := context.Background()
+
+	if result, err := store.Allow(ctx, "client", limit); err != nil || !result.Allowed {
+		t.Fatalf("first Allow() = %+v, %v, want allowed", result, err)
+	}
+	if result, err := store.Allow(ctx, "client", limit); err != nil || result.Allowed {
+		t.Fatalf("second Allow() = %+v, %v, want rejected", result, err)
+	}
+
+	// The pooled connection is dropped and new ones are refused
+	server.Close()
+
+	for range 2 {
+		result, err := store.Allow(ctx, "client", limit)
+		if err != nil || !result.Allowed || result.Remaining != limit.Requests {
+			t.Errorf("Allow() during the outage = %+v, %v, want allowed with a full limit", result, err)
+		}
+	}
+}
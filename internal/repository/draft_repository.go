@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	sqlc "multistep-registration/internal/database/sqlc"
+	"multistep-registration/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type DraftRepository interface {
+	CreateDraft(ctx context.Context, draft *domain.Draft) error
+	GetDraftByTokenHash(ctx context.Context, tokenHash []byte) (*domain.Draft, error)
+	UpdateDraftStep(ctx context.Context, draft *domain.Draft) (bool, error)
+	DeleteDraftByTokenHash(ctx context.Context, tokenHash []byte) error
+	DeleteExpiredDrafts(ctx context.Context) (int64, error)
+}
+
+type draftRepository struct {
+	db *sqlc.Queries
+}
+
+func NewDraftRepository(conn sqlc.DBTX) DraftRepository {
+	return &draftRepository{
+		db: sqlc.New(conn),
+	}
+}
+
+func (r *draftRepository) CreateDraft(ctx context.Context, draft *domain.Draft) error {
+	params := sqlc.CreateDraftParams{
+		TokenHash: draft.TokenHash,
+		ExpiresAt: pgtype.Timestamptz{Time: draft.ExpiresAt, Valid: true},
+	}
+
+	dbDraft, err := r.db.CreateDraft(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to create draft: %w", err)
+	}
+
+	created, err := r.toDomainDraft(dbDraft)
+	if err != nil {
+		return err
+	}
+	*draft = *created
+
+	return nil
+}
+
+func (r *draftRepository) GetDraftByTokenHash(ctx context.Context, tokenHash []byte) (*domain.Draft, error) {
+	dbDraft, err := r.db.GetDraftByTokenHash(ctx, tokenHash)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+
+	return r.toDomainDraft(dbDraft)
+}
+
+// UpdateDraftStep stores the sections set in the draft data, the other stored
+// sections are left as they are. draft is updated to the stored draft. It
+// reports false when the draft does not exist or has already expired
+func (r *draftRepository) UpdateDraftStep(ctx context.Context, draft *domain.Draft) (bool, error) {
+	// Sections left nil are omitted and keep their stored value
+	sections, err := json.Marshal(draft.Data)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode draft data: %w", err)
+	}
+
+	params := sqlc.UpdateDraftStepParams{
+		TokenHash:   draft.TokenHash,
+		Sections:    sections,
+		ExpiresAt:   pgtype.Timestamptz{Time: draft.ExpiresAt, Valid: true},
+		CurrentStep: int32(draft.CurrentStep),
+	}
+
+	dbDraft, err := r.db.UpdateDraftStep(ctx, params)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to update draft: %w", err)
+	}
+
+	updated, err := r.toDomainDraft(dbDraft)
+	if err != nil {
+		return false, err
+	}
+	*draft = *updated
+
+	return true, nil
+}
+
+func (r *draftRepository) DeleteDraftByTokenHash(ctx context.Context, tokenHash []byte) error {
+	if err := r.db.DeleteDraftByTokenHash(ctx, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+	return nil
+}
+
+func (r *draftRepository) DeleteExpiredDrafts(ctx context.Context) (int64, error) {
+	deleted, err := r.db.DeleteExpiredDrafts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired drafts: %w", err)
+	}
+	return deleted, nil
+}
+
+func (r *draftRepository) toDomainDraft(dbDraft sqlc.RegistrationDrafts) (*domain.Draft, error) {
+	var data domain.DraftData
+	if err := json.Unmarshal(dbDraft.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode draft data: %w", err)
+	}
+
+	return &domain.Draft{
+		ID:          dbDraft.ID,
+		TokenHash:   dbDraft.TokenHash,
+		Data:        data,
+		CurrentStep: int(dbDraft.CurrentStep),
+		ExpiresAt:   dbDraft.ExpiresAt.Time,
+		CreatedAt:   dbDraft.CreatedAt.Time,
+		UpdatedAt:   dbDraft.UpdatedAt.Time,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	sqlc "multistep-registration/internal/database/sqlc"
+	"multistep-registration/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type IdempotencyRepository interface {
+	ClaimIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord, staleBefore time.Time) (bool, error)
+	GetIdempotencyKey(ctx context.Context, key, route, client string) (*domain.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) error
+	DeleteIdempotencyKey(ctx context.Context, key, route, client string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *sqlc.Queries
+}
+
+func NewIdempotencyRepository(conn sqlc.DBTX) IdempotencyRepository {
+	return &idempotencyRepository{
+		db: sqlc.New(conn),
+	}
+}
+
+// ClaimIdempotencyKey stores the key as in progress and reports false when it
+// is already held by another request. Expired keys and keys whose request
+// started before staleBefore without completing are taken over
+func (r *idempotencyRepository) ClaimIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	params := sqlc.ClaimIdempotencyKeyParams{
+		IdempotencyKey: record.Key,
+		Route:          record.Route,
+		Client:         record.Client,
+		RequestHash:    record.RequestHash,
+		ExpiresAt:      pgtype.Timestamptz{Time: record.ExpiresAt, Valid: true},
+		StaleBefore:    pgtype.Timestamptz{Time: staleBefore, Valid: true},
+	}
+
+	dbRecord, err := r.db.ClaimIdempotencyKey(ctx, params)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	*record = *r.toDomainRecord(dbRecord)
+
+	return true, nil
+}
+
+func (r *idempotencyRepository) GetIdempotencyKey(ctx context.Context, key, route, client string) (*domain.IdempotencyRecord, error) {
+	dbRecord, err := r.db.GetIdempotencyKey(ctx, sqlc.GetIdempotencyKeyParams{
+		IdempotencyKey: key,
+		Route:          route,
+		Client:         client,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return r.toDomainRecord(dbRecord), nil
+}
+
+func (r *idempotencyRepository) CompleteIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) error {
+	params := sqlc.CompleteIdempotencyKeyParams{
+		IdempotencyKey: record.Key,
+		Route:          record.Route,
+		Client:         record.Client,
+		StatusCode:     pgtype.Int4{Int32: int32(record.StatusCode), Valid: true},
+		ContentType:    pgtype.Text{String: record.ContentType, Valid: true},
+		ResponseBody:   record.ResponseBody,
+	}
+
+	if err := r.db.CompleteIdempotencyKey(ctx, params); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) DeleteIdempotencyKey(ctx context.Context, key, route, client string) error {
+	err := r.db.DeleteIdempotencyKey(ctx, sqlc.DeleteIdempotencyKeyParams{
+		IdempotencyKey: key,
+		Route:          route,
+		Client:         client,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	deleted, err := r.db.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return deleted, nil
+}
+
+func (r *idempotencyRepository) toDomainRecord(dbRecord sqlc.IdempotencyKeys) *domain.IdempotencyRecord {
+	return &domain.IdempotencyRecord{
+		Key:          dbRecord.IdempotencyKey,
+		Route:        dbRecord.Route,
+		Client:       dbRecord.Client,
+		RequestHash:  dbRecord.RequestHash,
+		StatusCode:   int(dbRecord.StatusCode.Int32),
+		ContentType:  dbRecord.ContentType.String,
+		ResponseBody: dbRecord.ResponseBody,
+		ExpiresAt:    dbRecord.ExpiresAt.Time,
+		CreatedAt:    dbRecord.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	sqlc "multistep-registration/internal/database/sqlc"
+
+	"github.com/google/uuid"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) error
+	ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error)
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+}
+
+type recoveryCodeRepository struct {
+	db *sqlc.Queries
+}
+
+func NewRecoveryCodeRepository(conn sqlc.DBTX) RecoveryCodeRepository {
+	return &recoveryCodeRepository{
+		db: sqlc.New(conn),
+	}
+}
+
+// ReplaceRecoveryCodes drops the previous codes of the user and stores the new ones
+func (r *recoveryCodeRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) error {
+	if err := r.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		err := r.db.CreateRecoveryCode(ctx, sqlc.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ConsumeRecoveryCode marks an unused code as used and reports whether it existed
+func (r *recoveryCodeRepository) ConsumeRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error) {
+	consumed, err := r.db.ConsumeRecoveryCode(ctx, sqlc.ConsumeRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: codeHash,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+	return consumed > 0, nil
+}
+
+func (r *recoveryCodeRepository) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
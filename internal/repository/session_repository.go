@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	sqlc "multistep-registration/internal/database/sqlc"
+	"multistep-registration/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *domain.Session) error
+	GetSessionByTokenHash(ctx context.Context, tokenHash []byte) (*domain.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID) error
+	DeleteSessionByTokenHash(ctx context.Context, tokenHash []byte) error
+	DeleteUserSessions(ctx context.Context, userID uuid.UUID) error
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+}
+
+type sessionRepository struct {
+	db *sqlc.Queries
+}
+
+func NewSessionRepository(conn sqlc.DBTX) SessionRepository {
+	return &sessionRepository{
+		db: sqlc.New(conn),
+	}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session *domain.Session) error {
+	params := sqlc.CreateSessionParams{
+		UserID:    session.UserID,
+		TokenHash: session.TokenHash,
+		UserAgent: session.UserAgent,
+		IpAddress: session.IPAddress,
+		ExpiresAt: pgtype.Timestamptz{Time: session.ExpiresAt, Valid: true},
+	}
+
+	dbSession, err := r.db.CreateSession(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	*session = *r.toDomainSession(dbSession)
+
+	return nil
+}
+
+func (r *sessionRepository) GetSessionByTokenHash(ctx context.Context, tokenHash []byte) (*domain.Session, error) {
+	dbSession, err := r.db.GetSessionByTokenHash(ctx, tokenHash)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return r.toDomainSession(dbSession), nil
+}
+
+func (r *sessionRepository) TouchSession(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.TouchSession(ctx, id); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteSessionByTokenHash(ctx context.Context, tokenHash []byte) error {
+	if err := r.db.DeleteSessionByTokenHash(ctx, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.DeleteUserSessions(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	deleted, err := r.db.DeleteExpiredSessions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return deleted, nil
+}
+
+func (r *sessionRepository) toDomainSession(dbSession sqlc.Sessions) *domain.Session {
+	return &domain.Session{
+		ID:         dbSession.ID,
+		UserID:     dbSession.UserID,
+		TokenHash:  dbSession.TokenHash,
+		UserAgent:  dbSession.UserAgent,
+		IPAddress:  dbSession.IpAddress,
+		ExpiresAt:  dbSession.ExpiresAt.Time,
+		CreatedAt:  dbSession.CreatedAt.Time,
+		LastSeenAt: dbSession.LastSeenAt.Time,
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TxBeginner starts transactions, e.g. a pgxpool.Pool
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// TxRepositories are the repositories bound to one transaction
+type TxRepositories struct {
+	Users    UserRepository
+	Tokens   UserTokenRepository
+	Sessions SessionRepository
+}
+
+// Transactor runs writes that must succeed or fail together
+type Transactor interface {
+	// InTx commits when fn returns nil and rolls back otherwise
+	InTx(ctx context.Context, fn func(repos TxRepositories) error) error
+}
+
+type transactor struct {
+	db TxBeginner
+}
+
+func NewTransactor(db TxBeginner) Transactor {
+	return &transactor{db: db}
+}
+
+func (t *transactor) InTx(ctx context.Context, fn func(repos TxRepositories) error) error {
+	return pgx.BeginFunc(ctx, t.db, func(tx pgx.Tx) error {
+		return fn(TxRepositories{
+			Users:    NewUserRepository(tx),
+			Tokens:   NewUserTokenRepository(tx),
+			Sessions: NewSessionRepository(tx),
+		})
+	})
+}
@@ -2,20 +2,51 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	sqlc "multistep-registration/internal/database/sqlc"
 	"multistep-registration/internal/domain"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Unique constraints of the users table, named by Postgres defaults
+const (
+	usersEmailKey    = "users_email_key"
+	usersUsernameKey = "users_username_key"
+
+	uniqueViolationCode = "23505"
+)
+
+var (
+	ErrDuplicateEmail    = errors.New("email already exists")
+	ErrDuplicateUsername = errors.New("username already exists")
+)
+
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *domain.User) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*domain.User, error)
 	CheckEmailExists(ctx context.Context, email string) (bool, error)
 	CheckUsernameExists(ctx context.Context, username string) (bool, error)
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID) error
+	UpdateUserPassword(ctx context.Context, userID uuid.UUID, passwordHash []byte, pepperKeyID string) error
+	// RehashUserPassword replaces the hash only while it is still oldHash, it
+	// reports false when the password changed in the meantime
+	RehashUserPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash []byte, pepperKeyID string) (bool, error)
+	// FlagPasswordsForRehash flags every password not peppered with the
+	// current key, an empty key ID flags peppered passwords
+	FlagPasswordsForRehash(ctx context.Context, currentKeyID string) (int64, error)
+	CountUsersByPepperKey(ctx context.Context) ([]domain.PepperKeyUsage, error)
+	SetUserMFASecret(ctx context.Context, userID uuid.UUID, secret []byte) error
+	EnableUserMFA(ctx context.Context, userID uuid.UUID) error
+	DisableUserMFA(ctx context.Context, userID uuid.UUID) error
+	UpdateUserMFALastUsedStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
 }
 
 type userRepository struct {
@@ -34,22 +65,26 @@ func (r *userRepository) CreateUser(ctx context.Context, user *domain.User) erro
 		phoneNumber = *user.PhoneNumber
 	}
 	params := sqlc.CreateUserParams{
-		FirstName:     user.FirstName,
-		LastName:      user.LastName,
-		Email:         user.Email,
-		PhoneNumber:   pgtype.Text{String: phoneNumber},
-		StreetAddress: user.StreetAddress,
-		City:          user.City,
-		State:         user.State,
-		Country:       user.Country,
-		Username:      user.Username,
-		PasswordHash:  user.PasswordHash,
-		AcceptTerms:   user.AcceptTerms,
-		Newsletter:    user.Newsletter,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		Email:               user.Email,
+		PhoneNumber:         pgtype.Text{String: phoneNumber},
+		StreetAddress:       user.StreetAddress,
+		City:                user.City,
+		State:               user.State,
+		Country:             user.Country,
+		Username:            user.Username,
+		PasswordHash:        user.PasswordHash,
+		PasswordPepperKeyID: pepperKeyID(user.PasswordPepperKeyID),
+		AcceptTerms:         user.AcceptTerms,
+		Newsletter:          user.Newsletter,
 	}
 
 	dbUser, err := r.db.CreateUser(ctx, params)
 	if err != nil {
+		if dupErr := mapUniqueViolation(err); dupErr != nil {
+			return dupErr
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -61,6 +96,18 @@ func (r *userRepository) CreateUser(ctx context.Context, user *domain.User) erro
 	return nil
 }
 
+func (r *userRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	dbUser, err := r.db.GetUserByID(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	return r.toDomainUser(dbUser), nil
+}
+
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	dbUser, err := r.db.GetUserByEmail(ctx, email)
 	if err != nil {
@@ -101,7 +148,135 @@ func (r *userRepository) CheckUsernameExists(ctx context.Context, username strin
 	return exists, nil
 }
 
+func (r *userRepository) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.MarkEmailVerified(ctx, userID); err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateUserPassword(ctx context.Context, userID uuid.UUID, passwordHash []byte, keyID string) error {
+	err := r.db.UpdateUserPassword(ctx, sqlc.UpdateUserPasswordParams{
+		ID:                  userID,
+		PasswordHash:        passwordHash,
+		PasswordPepperKeyID: pepperKeyID(keyID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update user password: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) RehashUserPassword(ctx context.Context, userID uuid.UUID, oldHash, newHash []byte, keyID string) (bool, error) {
+	rows, err := r.db.RehashUserPassword(ctx, sqlc.RehashUserPasswordParams{
+		ID:          userID,
+		OldHash:     oldHash,
+		NewHash:     newHash,
+		PepperKeyID: pepperKeyID(keyID),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to rehash user password: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *userRepository) FlagPasswordsForRehash(ctx context.Context, currentKeyID string) (int64, error) {
+	rows, err := r.db.FlagPasswordsForRehash(ctx, pepperKeyID(currentKeyID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag passwords for rehash: %w", err)
+	}
+	return rows, nil
+}
+
+func (r *userRepository) CountUsersByPepperKey(ctx context.Context) ([]domain.PepperKeyUsage, error) {
+	rows, err := r.db.CountUsersByPepperKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users by pepper key: %w", err)
+	}
+
+	usage := make([]domain.PepperKeyUsage, 0, len(rows))
+	for _, row := range rows {
+		usage = append(usage, domain.PepperKeyUsage{
+			KeyID:          row.PasswordPepperKeyID.String,
+			Users:          row.Users,
+			RehashRequired: row.RehashRequired,
+		})
+	}
+	return usage, nil
+}
+
+func (r *userRepository) SetUserMFASecret(ctx context.Context, userID uuid.UUID, secret []byte) error {
+	err := r.db.SetUserMFASecret(ctx, sqlc.SetUserMFASecretParams{
+		ID:        userID,
+		MfaSecret: secret,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set user mfa secret: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) EnableUserMFA(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.EnableUserMFA(ctx, userID); err != nil {
+		return fmt.Errorf("failed to enable user mfa: %w", err)
+	}
+	return nil
+}
+
+func (r *userRepository) DisableUserMFA(ctx context.Context, userID uuid.UUID) error {
+	if err := r.db.DisableUserMFA(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable user mfa: %w", err)
+	}
+	return nil
+}
+
+// UpdateUserMFALastUsedStep records the TOTP step of an accepted code and
+// reports false when that step or a later one was already used
+func (r *userRepository) UpdateUserMFALastUsedStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	updated, err := r.db.UpdateUserMFALastUsedStep(ctx, sqlc.UpdateUserMFALastUsedStepParams{
+		ID:              userID,
+		MfaLastUsedStep: step,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to update user mfa step: %w", err)
+	}
+	return updated > 0, nil
+}
+
+// pepperKeyID stores an empty key ID, a password hashed without pepper, as NULL
+func pepperKeyID(keyID string) pgtype.Text {
+	return pgtype.Text{String: keyID, Valid: keyID != ""}
+}
+
+// mapUniqueViolation translates a unique violation on users into a duplicate
+// error, it returns nil for any other error
+func mapUniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolationCode {
+		return nil
+	}
+
+	switch pgErr.ConstraintName {
+	case usersEmailKey:
+		return ErrDuplicateEmail
+	case usersUsernameKey:
+		return ErrDuplicateUsername
+	default:
+		return nil
+	}
+}
+
 func (r *userRepository) toDomainUser(dbUser sqlc.Users) *domain.User {
+	var emailVerifiedAt *time.Time
+	if dbUser.EmailVerifiedAt.Valid {
+		emailVerifiedAt = &dbUser.EmailVerifiedAt.Time
+	}
+
+	var mfaEnabledAt *time.Time
+	if dbUser.MfaEnabledAt.Valid {
+		mfaEnabledAt = &dbUser.MfaEnabledAt.Time
+	}
+
 	return &domain.User{
 		ID:            dbUser.ID,
 		FirstName:     dbUser.FirstName,
@@ -116,8 +291,18 @@ func (r *userRepository) toDomainUser(dbUser sqlc.Users) *domain.User {
 		PasswordHash:  dbUser.PasswordHash,
 		AcceptTerms:   dbUser.AcceptTerms,
 		Newsletter:    dbUser.Newsletter,
-		CreatedAt:     dbUser.CreatedAt.Time,
-		UpdatedAt:     dbUser.UpdatedAt.Time,
-		Version:       int(dbUser.Version),
+
+		PasswordPepperKeyID:    dbUser.PasswordPepperKeyID.String,
+		PasswordRehashRequired: dbUser.PasswordRehashRequired,
+
+		EmailVerifiedAt: emailVerifiedAt,
+
+		MFASecret:       dbUser.MfaSecret,
+		MFAEnabledAt:    mfaEnabledAt,
+		MFALastUsedStep: dbUser.MfaLastUsedStep,
+
+		CreatedAt: dbUser.CreatedAt.Time,
+		UpdatedAt: dbUser.UpdatedAt.Time,
+		Version:   int(dbUser.Version),
 	}
 }
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"multistep-registration/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// failingDB answers every query with err, as Postgres rejecting the statement
+type failingDB struct {
+	err error
+}
+
+func (db failingDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, db.err
+}
+
+func (db failingDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, db.err
+}
+
+func (db failingDB) QueryRow(context.Context, string, ...any) pgx.Row {
+	return errRow{db.err}
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+func TestCreateUserMapsUniqueViolations(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    error
+		wantDup bool
+	}{
+		{
+			name:    "email",
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"},
+			want:    ErrDuplicateEmail,
+			wantDup: true,
+		},
+		{
+			name:    "username",
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: "users_username_key"},
+			want:    ErrDuplicateUsername,
+			wantDup: true,
+		},
+		{
+			name: "other constraint",
+			err:  &pgconn.PgError{Code: "23505", ConstraintName: "users_pkey"},
+		},
+		{
+			name: "other error",
+			err:  &pgconn.PgError{Code: "23502", ConstraintName: "users_email_key"},
+		},
+		{
+			name: "connection error",
+			err:  errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewUserRepository(failingDB{err: tt.err})
+
+			err := repo.CreateUser(context.Background(), &domain.User{Email: "ada@example.com", Username: "adalovelace"})
+			if tt.wantDup {
+				if err != tt.want {
+					t.Errorf("CreateUser() error = %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if errors.Is(err, ErrDuplicateEmail) || errors.Is(err, ErrDuplicateUsername) {
+				t.Errorf("CreateUser() error = %v, want no duplicate error", err)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("CreateUser() error = %v, want it to wrap %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	sqlc "multistep-registration/internal/database/sqlc"
+	"multistep-registration/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type UserTokenRepository interface {
+	CreateUserToken(ctx context.Context, token *domain.UserToken) error
+	ConsumeUserToken(ctx context.Context, tokenHash []byte, purpose string) (*domain.UserToken, error)
+	DeleteUnusedUserTokens(ctx context.Context, userID uuid.UUID, purpose string) error
+}
+
+type userTokenRepository struct {
+	db *sqlc.Queries
+}
+
+func NewUserTokenRepository(conn sqlc.DBTX) UserTokenRepository {
+	return &userTokenRepository{
+		db: sqlc.New(conn),
+	}
+}
+
+func (r *userTokenRepository) CreateUserToken(ctx context.Context, token *domain.UserToken) error {
+	params := sqlc.CreateUserTokenParams{
+		UserID:    token.UserID,
+		Purpose:   token.Purpose,
+		TokenHash: token.TokenHash,
+		ExpiresAt: pgtype.Timestamptz{Time: token.ExpiresAt, Valid: true},
+	}
+
+	dbToken, err := r.db.CreateUserToken(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to create user token: %w", err)
+	}
+
+	token.ID = dbToken.ID
+	token.CreatedAt = dbToken.CreatedAt.Time
+
+	return nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+// It returns nil when no such token exists, so a token can be consumed once
+func (r *userTokenRepository) ConsumeUserToken(ctx context.Context, tokenHash []byte, purpose string) (*domain.UserToken, error) {
+	dbToken, err := r.db.ConsumeUserToken(ctx, sqlc.ConsumeUserTokenParams{
+		TokenHash: tokenHash,
+		Purpose:   purpose,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to consume user token: %w", err)
+	}
+
+	return r.toDomainUserToken(dbToken), nil
+}
+
+func (r *userTokenRepository) DeleteUnusedUserTokens(ctx context.Context, userID uuid.UUID, purpose string) error {
+	err := r.db.DeleteUnusedUserTokens(ctx, sqlc.DeleteUnusedUserTokensParams{
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete unused user tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *userTokenRepository) toDomainUserToken(dbToken sqlc.UserTokens) *domain.UserToken {
+	var usedAt *time.Time
+	if dbToken.UsedAt.Valid {
+		usedAt = &dbToken.UsedAt.Time
+	}
+
+	return &domain.UserToken{
+		ID:        dbToken.ID,
+		UserID:    dbToken.UserID,
+		Purpose:   dbToken.Purpose,
+		TokenHash: dbToken.TokenHash,
+		ExpiresAt: dbToken.ExpiresAt.Time,
+		UsedAt:    usedAt,
+		CreatedAt: dbToken.CreatedAt.Time,
+	}
+}
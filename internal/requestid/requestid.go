@@ -0,0 +1,49 @@
+// Package requestid carries the ID correlating a request across responses,
+// logs and database queries
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	Header = "X-Request-ID"
+
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New returns a random request ID
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid reports whether a client supplied ID can be reused. Only a safe
+// charset is accepted as the ID ends up in headers, logs and SQL comments
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID or an empty string outside of a request
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/context"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Login authenticates by username or email and starts a session
+func (s *Server) Login(c *gin.Context) {
+	var req domain.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "Login and password are required",
+		})
+		return
+	}
+
+	session, err := s.authService.Login(c.Request.Context(), &req, domain.LoginMetadata{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			respondError(c, http.StatusUnauthorized, domain.ErrorResponse{
+				Code:    constants.CodeInvalidLogin,
+				Message: err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, password.ErrBusy) {
+			respondHashingBusy(c)
+			return
+		}
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: "Failed to login",
+		})
+		return
+	}
+
+	// No session exists until the second factor, an empty cookie would
+	// replace a session the browser already has
+	if !session.MFARequired {
+		s.setSessionCookie(c, session.Token, session.ExpiresAt)
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// Logout ends the current session
+func (s *Server) Logout(c *gin.Context) {
+	token, _ := context.GetSessionToken(c)
+
+	if err := s.authService.Logout(c.Request.Context(), token); err != nil {
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: "Failed to logout",
+		})
+		return
+	}
+
+	s.setSessionCookie(c, "", time.Unix(0, 0))
+	c.Status(http.StatusNoContent)
+}
+
+// Me returns the authenticated user
+func (s *Server) Me(c *gin.Context) {
+	user, ok := context.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, domain.ErrorResponse{
+			Code:    constants.CodeUnauthorized,
+			Message: service.ErrUnauthorized.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, service.ToUserResponse(user))
+}
+
+func (s *Server) setSessionCookie(c *gin.Context, token string, expiresAt time.Time) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.sessionCookie.Name,
+		Value:    token,
+		Path:     "/",
+		Domain:   s.sessionCookie.Domain,
+		Expires:  expiresAt,
+		Secure:   s.sessionCookie.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
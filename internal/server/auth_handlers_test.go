@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/service"
+)
+
+type fakeLogin struct {
+	service.AuthService
+	session *domain.AuthSession
+}
+
+func (f fakeLogin) Login(context.Context, *domain.LoginRequest, domain.LoginMetadata) (*domain.AuthSession, error) {
+	return f.session, nil
+}
+
+func TestLoginSessionCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		session    *domain.AuthSession
+		wantCookie bool
+	}{
+		{
+			name:       "password only",
+			session:    &domain.AuthSession{Token: "session-token", ExpiresAt: time.Now().Add(time.Hour)},
+			wantCookie: true,
+		},
+		{
+			name:    "MFA required",
+			session: &domain.AuthSession{MFARequired: true, MFAToken: "mfa-token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDocumentedServer(t)
+			s.setServices(Services{Auth: fakeLogin{session: tt.session}})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(`{"login":"adalovelace","password":"analytical engine"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: "session_id", Value: "existing-session"})
+			rec := httptest.NewRecorder()
+			s.RegisterRoutes().ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("POST /login = %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			cookies := rec.Result().Cookies()
+			if !tt.wantCookie {
+				if len(cookies) > 0 {
+					t.Errorf("Set-Cookie = %v, want none until the second factor", rec.Header().Values("Set-Cookie"))
+				}
+				return
+			}
+			if len(cookies) != 1 || cookies[0].Name != "session_id" || cookies[0].Value != "session-token" {
+				t.Errorf("cookies = %v, want the session cookie", cookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateDraft starts a new registration draft
+func (s *Server) CreateDraft(c *gin.Context) {
+	resp, err := s.draftService.CreateDraft(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: "Failed to create draft",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// GetDraft returns the saved steps of a draft
+func (s *Server) GetDraft(c *gin.Context) {
+	resp, err := s.draftService.GetDraft(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		handleDraftError(c, err, "Failed to get draft")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// SaveDraftStep stores the data of a single form step in the draft
+func (s *Server) SaveDraftStep(c *gin.Context) {
+	step, err := strconv.Atoi(c.Param("step"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "Step must be a number",
+		})
+		return
+	}
+
+	data, err := bindDraftStep(c, step)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDraftStep) {
+			handleDraftError(c, err, "")
+			return
+		}
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "Invalid step data",
+		})
+		return
+	}
+
+	resp, err := s.draftService.SaveDraftStep(c.Request.Context(), c.Param("id"), step, data)
+	if err != nil {
+		handleDraftError(c, err, "Failed to save draft step")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func bindDraftStep(c *gin.Context, step int) (domain.DraftData, error) {
+	var data domain.DraftData
+
+	switch step {
+	case domain.StepPersonalInfo:
+		data.PersonalInfo = &domain.PersonalInfoStep{}
+		return data, c.ShouldBindJSON(data.PersonalInfo)
+	case domain.StepAddressDetails:
+		data.AddressDetails = &domain.AddressDetailsStep{}
+		return data, c.ShouldBindJSON(data.AddressDetails)
+	case domain.StepAccountSetup:
+		data.AccountSetup = &domain.AccountSetupStep{}
+		return data, c.ShouldBindJSON(data.AccountSetup)
+	default:
+		return data, service.ErrInvalidDraftStep
+	}
+}
+
+func handleDraftError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrDraftNotFound):
+		respondError(c, http.StatusNotFound, domain.ErrorResponse{
+			Code:    constants.CodeNotFound,
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidDraftStep):
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: err.Error(),
+		})
+	default:
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: message,
+		})
+	}
+}
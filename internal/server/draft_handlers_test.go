@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/service"
+)
+
+// fakeDrafts knows a single draft, which finalizes into a valid registration
+type fakeDrafts struct {
+	service.DraftService
+	id      string
+	deleted []string
+}
+
+func (f *fakeDrafts) GetDraft(_ context.Context, id string) (*domain.DraftResponse, error) {
+	if id != f.id {
+		return nil, service.ErrDraftNotFound
+	}
+	return &domain.DraftResponse{ID: id}, nil
+}
+
+func (f *fakeDrafts) SaveDraftStep(_ context.Context, id string, step int, data domain.DraftData) (*domain.DraftResponse, error) {
+	if id != f.id {
+		return nil, service.ErrDraftNotFound
+	}
+	return &domain.DraftResponse{ID: id, CurrentStep: step, Data: data}, nil
+}
+
+func (f *fakeDrafts) BuildRegistrationRequest(_ context.Context, req *domain.FinalizeDraftRequest) (*domain.RegistrationRequest, error) {
+	if req.DraftID != f.id {
+		return nil, service.ErrDraftNotFound
+	}
+	var regReq domain.RegistrationRequest
+	if err := json.Unmarshal([]byte(registrationBody("ada@example.com", "adalovelace")), &regReq); err != nil {
+		return nil, err
+	}
+	regReq.Password, regReq.ConfirmPassword = req.Password, req.ConfirmPassword
+	return &regReq, nil
+}
+
+func (f *fakeDrafts) DeleteDraft(_ context.Context, id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+// fakeRegistrations accepts every registration
+type fakeRegistrations struct {
+	service.UserService
+	registered []*domain.RegistrationRequest
+}
+
+func (f *fakeRegistrations) Register(_ context.Context, req *domain.RegistrationRequest) (*domain.RegistrationResponse, error) {
+	f.registered = append(f.registered, req)
+	return &domain.RegistrationResponse{Email: req.Email, Username: req.Username}, nil
+}
+
+func serveJSON(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSaveDraftStep(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"saved", "/api/v1/drafts/draft-1/steps/1", `{"firstName":"Ada","lastName":"Lovelace","email":"ada@example.com"}`, http.StatusOK},
+		{"step is not a number", "/api/v1/drafts/draft-1/steps/one", `{}`, http.StatusBadRequest},
+		{"unknown step", "/api/v1/drafts/draft-1/steps/4", `{}`, http.StatusBadRequest},
+		{"invalid step data", "/api/v1/drafts/draft-1/steps/2", `{"city":42}`, http.StatusBadRequest},
+		{"unknown draft", "/api/v1/drafts/draft-2/steps/1", `{"firstName":"Ada"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDocumentedServer(t)
+			s.setServices(Services{Draft: &fakeDrafts{id: "draft-1"}})
+
+			if rec := serveJSON(s, http.MethodPut, tt.path, tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("PUT %s = %d, want %d: %s", tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRegisterFinalizesDraft(t *testing.T) {
+	const password = "analytical engine punch cards 1843"
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantDeleted []string
+	}{
+		{
+			name:        "draft",
+			body:        `{"draftId":"draft-1","password":"` + password + `","confirmPassword":"` + password + `"}`,
+			wantStatus:  http.StatusCreated,
+			wantDeleted: []string{"draft-1"},
+		},
+		{
+			name:       "unknown draft",
+			body:       `{"draftId":"draft-2","password":"` + password + `","confirmPassword":"` + password + `"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "without a draft",
+			body:       registrationBody("ada@example.com", "adalovelace"),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "draft failing validation",
+			body:       `{"draftId":"draft-1","password":"` + password + `","confirmPassword":"another password"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drafts := &fakeDrafts{id: "draft-1"}
+			users := &fakeRegistrations{}
+			s := newDocumentedServer(t)
+			s.setServices(Services{
+				User:              users,
+				Draft:             drafts,
+				Idempotency:       noopIdempotency{},
+				EmailVerification: noopVerification{},
+			})
+
+			rec := serveJSON(s, http.MethodPost, "/api/v1/register", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST /register = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if strings.Join(drafts.deleted, ",") != strings.Join(tt.wantDeleted, ",") {
+				t.Errorf("deleted drafts = %v, want %v", drafts.deleted, tt.wantDeleted)
+			}
+			if tt.wantStatus == http.StatusCreated && (len(users.registered) != 1 || users.registered[0].Username != "adalovelace") {
+				t.Errorf("registered %+v, want the draft's user", users.registered)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"errors"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmail confirms the email address the token was issued for
+func (s *Server) VerifyEmail(c *gin.Context) {
+	var req domain.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "Token is required",
+		})
+		return
+	}
+
+	if err := s.emailVerificationService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeInvalidToken,
+				Message: err.Error(),
+			})
+			return
+		}
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: "Failed to verify email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.MessageResponse{
+		Message: "Email verified successfully",
+	})
+}
+
+// ResendVerification sends a new verification link in the background. The
+// response is the same whether or not the address is registered
+func (s *Server) ResendVerification(c *gin.Context) {
+	var req domain.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "A valid email is required",
+		})
+		return
+	}
+
+	s.emailVerificationService.SendVerification(c.Request.Context(), req.Email)
+
+	c.JSON(http.StatusAccepted, domain.MessageResponse{
+		Message: "If the address is registered and not yet verified, a verification email has been sent",
+	})
+}
@@ -5,8 +5,12 @@ import (
 	"multistep-registration/internal/constants"
 	"multistep-registration/internal/context"
 	"multistep-registration/internal/domain"
+	"multistep-registration/internal/logging"
+	"multistep-registration/internal/password"
 	"multistep-registration/internal/service"
+	"multistep-registration/internal/validation"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +20,7 @@ func (s *Server) Register(c *gin.Context) {
 	req, exists := context.GetRegistrationRequest(c)
 
 	if !exists {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
 			Code:    constants.CodeValidationError,
 			Message: "Invalid request data",
 		})
@@ -27,12 +31,20 @@ func (s *Server) Register(c *gin.Context) {
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrUsernameAlreadyTaken) || errors.Is(err, service.ErrEmailAlreadyRegistered):
-			c.JSON(http.StatusConflict, domain.ErrorResponse{
+			respondError(c, http.StatusConflict, domain.ErrorResponse{
 				Code:    constants.CodeDuplicateError,
 				Message: err.Error(),
 			})
+		case errors.Is(err, password.ErrTooLong):
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeValidationError,
+				Message: "Password is too long",
+			})
+		case errors.Is(err, password.ErrBusy):
+			respondHashingBusy(c)
 		default:
-			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			_ = c.Error(err)
+			respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
 				Code:    constants.CodeInternalError,
 				Message: "Failed to process registration",
 			})
@@ -40,14 +52,33 @@ func (s *Server) Register(c *gin.Context) {
 		return
 	}
 
+	// Sent in the background, SMTP latency doesn't hold up the signup
+	s.emailVerificationService.SendVerification(c.Request.Context(), resp.Email)
+
+	if draftID, ok := context.GetDraftID(c); ok {
+		if err := s.draftService.DeleteDraft(c.Request.Context(), draftID); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("failed to delete finalized draft", "error", err)
+		}
+	}
+
 	c.JSON(http.StatusCreated, resp)
 }
 
+// ValidateStep responds once the step validation chain has accepted the request
+func (s *Server) ValidateStep(c *gin.Context) {
+	step, _ := strconv.Atoi(c.Param("step"))
+
+	c.JSON(http.StatusOK, domain.StepValidationResponse{
+		Step:  step,
+		Valid: true,
+	})
+}
+
 // CheckUsername handles username availability check
 func (s *Server) CheckUsername(c *gin.Context) {
 	username := c.Query("username")
 	if username == "" {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
 			Code:    constants.CodeValidationError,
 			Message: "Username is required",
 		})
@@ -56,7 +87,8 @@ func (s *Server) CheckUsername(c *gin.Context) {
 
 	available, err := s.userService.CheckUsernameAvailability(c.Request.Context(), username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
 			Code:    constants.CodeInternalError,
 			Message: "Failed to check username availability",
 		})
@@ -73,7 +105,7 @@ func (s *Server) CheckUsername(c *gin.Context) {
 func (s *Server) CheckEmail(c *gin.Context) {
 	email := c.Query("email")
 	if email == "" {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
 			Code:    constants.CodeValidationError,
 			Message: "Email is required",
 		})
@@ -82,7 +114,8 @@ func (s *Server) CheckEmail(c *gin.Context) {
 
 	available, err := s.userService.CheckEmailAvailability(c.Request.Context(), email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
 			Code:    constants.CodeInternalError,
 			Message: "Failed to check email availability",
 		})
@@ -94,3 +127,13 @@ func (s *Server) CheckEmail(c *gin.Context) {
 		Message:   getAvailabilityMessage("email", email, available),
 	})
 }
+
+// RegistrationSchema publishes the registration validation rules as JSON
+// Schema, identified by the versioned path of the route
+func (s *Server) RegistrationSchema(c *gin.Context) {
+	schema := *validation.RegistrationSchema(s.passwordPolicy)
+	schema.ID = routeKey(c)
+
+	c.Header("Content-Type", "application/schema+json; charset=utf-8")
+	c.JSON(http.StatusOK, &schema)
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"multistep-registration/internal/config"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/problem"
+	"multistep-registration/internal/repository"
+	"multistep-registration/internal/service"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// racingUsersDB is a users table with the unique constraints of the
+// migrations. Inserts wait until every racer has checked availability, so
+// all checks pass and only the constraints can reject the duplicate
+type racingUsersDB struct {
+	mu        sync.Mutex
+	emails    map[string]bool
+	usernames map[string]bool
+	checked   sync.WaitGroup
+}
+
+func newRacingUsersDB(racers int) *racingUsersDB {
+	db := &racingUsersDB{emails: map[string]bool{}, usernames: map[string]bool{}}
+	db.checked.Add(racers)
+	return db
+}
+
+func (db *racingUsersDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *racingUsersDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, pgx.ErrNoRows
+}
+
+func (db *racingUsersDB) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.Contains(sql, "name: CheckEmailExists "):
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		return existsRow(db.emails[args[0].(string)])
+	case strings.Contains(sql, "name: CheckUsernameExists "):
+		db.mu.Lock()
+		exists := db.usernames[args[0].(string)]
+		db.mu.Unlock()
+		// The username is checked last, the racer is ready to insert
+		db.checked.Done()
+		return existsRow(exists)
+	case strings.Contains(sql, "name: CreateUser "):
+		db.checked.Wait()
+		db.mu.Lock()
+		defer db.mu.Unlock()
+
+		email, username := args[2].(string), args[8].(string)
+		if db.emails[email] {
+			return rowErr{&pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}}
+		}
+		if db.usernames[username] {
+			return rowErr{&pgconn.PgError{Code: "23505", ConstraintName: "users_username_key"}}
+		}
+		db.emails[email], db.usernames[username] = true, true
+		return rowErr{nil}
+	}
+	return rowErr{pgx.ErrNoRows}
+}
+
+type existsRow bool
+
+func (r existsRow) Scan(dest ...any) error {
+	*dest[0].(*bool) = bool(r)
+	return nil
+}
+
+// rowErr scans nothing, the inserted user keeps its zero values
+type rowErr struct {
+	err error
+}
+
+func (r rowErr) Scan(...any) error {
+	return r.err
+}
+
+type noopIdempotency struct{}
+
+func (noopIdempotency) Begin(context.Context, string, string, string, []byte) (*domain.IdempotencyRecord, error) {
+	return nil, nil
+}
+
+func (noopIdempotency) Complete(context.Context, string, string, string, int, string, []byte) error {
+	return nil
+}
+
+func (noopIdempotency) Release(context.Context, string, string, string) error {
+	return nil
+}
+
+type noopVerification struct {
+	service.EmailVerificationService
+}
+
+func (noopVerification) SendVerification(context.Context, string) {}
+
+func registrationBody(email, username string) string {
+	body, _ := json.Marshal(domain.RegistrationRequest{
+		FirstName:       "Ada",
+		LastName:        "Lovelace",
+		Email:           email,
+		StreetAddress:   "12 St James's Square",
+		City:            "London",
+		State:           "London",
+		Country:         "United Kingdom",
+		Username:        username,
+		Password:        "analytical engine punch cards 1843",
+		ConfirmPassword: "analytical engine punch cards 1843",
+		AcceptTerms:     true,
+	})
+	return string(body)
+}
+
+func TestConcurrentRegistrationConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		emails     [2]string
+		usernames  [2]string
+		wantErr    error
+		wantDetail string
+	}{
+		{
+			name:       "same email",
+			emails:     [2]string{"ada@example.com", "ada@example.com"},
+			usernames:  [2]string{"adalovelace", "countessada"},
+			wantErr:    service.ErrEmailAlreadyRegistered,
+			wantDetail: service.ErrEmailAlreadyRegistered.Error(),
+		},
+		{
+			name:       "same username",
+			emails:     [2]string{"ada@example.com", "countess@example.com"},
+			usernames:  [2]string{"adalovelace", "adalovelace"},
+			wantErr:    service.ErrUsernameAlreadyTaken,
+			wantDetail: service.ErrUsernameAlreadyTaken.Error(),
+		},
+	}
+
+	passwords, err := password.NewPolicyFor(password.AlgorithmBcrypt, 4, password.DefaultArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/service", func(t *testing.T) {
+			users := service.NewUserService(repository.NewUserRepository(newRacingUsersDB(2)), passwords, nil, nil)
+
+			errs := make([]error, 2)
+			var wg sync.WaitGroup
+			for i := range 2 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = users.Register(context.Background(), &domain.RegistrationRequest{
+						Email:    tt.emails[i],
+						Username: tt.usernames[i],
+						Password: "analytical engine punch cards 1843",
+					})
+				}()
+			}
+			wg.Wait()
+
+			if (errs[0] == nil) == (errs[1] == nil) {
+				t.Fatalf("Register() errors = %v, want exactly one to fail", errs)
+			}
+			for _, err := range errs {
+				if err != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("Register() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+
+		t.Run(tt.name+"/http", func(t *testing.T) {
+			db := newRacingUsersDB(2)
+			users := service.NewUserService(repository.NewUserRepository(db), passwords, nil, nil)
+
+			var cfg config.Config
+			cfg.API.LegacyDeprecatedAt = "2026-10-17"
+			cfg.API.LegacySunsetAt = "2027-04-30"
+			s, err := New(Props{Config: &cfg, Logger: slog.New(slog.DiscardHandler)}, Services{
+				User:              users,
+				Idempotency:       noopIdempotency{},
+				EmailVerification: noopVerification{},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := s.RegisterRoutes()
+
+			statuses := make([]int, 2)
+			bodies := make([]string, 2)
+			var wg sync.WaitGroup
+			for i := range 2 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest(http.MethodPost, "/api/v1/register", strings.NewReader(registrationBody(tt.emails[i], tt.usernames[i])))
+					req.Header.Set("Content-Type", "application/json")
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, req)
+					statuses[i], bodies[i] = rec.Code, rec.Body.String()
+				}()
+			}
+			wg.Wait()
+
+			slices.Sort(statuses)
+			if statuses[0] != http.StatusCreated || statuses[1] != http.StatusConflict {
+				t.Fatalf("statuses = %v, want one 201 and one 409: %v", statuses, bodies)
+			}
+
+			for _, body := range bodies {
+				var details problem.Details
+				if err := json.Unmarshal([]byte(body), &details); err != nil || details.Status != http.StatusConflict {
+					continue
+				}
+				if details.Code != "DUPLICATE_ERROR" || details.Detail != tt.wantDetail {
+					t.Errorf("conflict = %+v, want DUPLICATE_ERROR %q", details, tt.wantDetail)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistrationSchemaID(t *testing.T) {
+	s := newDocumentedServer(t)
+
+	// The legacy mount serves the same schema, identified by its v1 route
+	for _, path := range []string{"/api/v1/schema/registration", "/api/schema/registration"} {
+		rec := serveJSON(s, http.MethodGet, path, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", path, rec.Code, rec.Body)
+		}
+		var schema struct {
+			ID string `json:"$id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &schema); err != nil {
+			t.Fatal(err)
+		}
+		if schema.ID != "/api/v1/schema/registration" {
+			t.Errorf("GET %s $id = %q, want /api/v1/schema/registration", path, schema.ID)
+		}
+	}
+}
+
+func TestErrorFormatNegotiation(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		path            string
+		body            string
+		wantContentType string
+		wantMembers     []string
+	}{
+		{
+			name:            "problem details by default",
+			path:            "/api/v1/register/validate/2",
+			body:            `{"streetAddress":"12 St James's Square"}`,
+			wantContentType: problem.ContentType,
+			wantMembers:     []string{"code", "errors", "instance", "requestId", "status", "title", "type", "detail"},
+		},
+		{
+			name:            "problem details asked for",
+			accept:          "application/problem+json",
+			path:            "/api/v1/unknown",
+			wantContentType: problem.ContentType,
+			wantMembers:     []string{"code", "instance", "requestId", "status", "title", "type", "detail"},
+		},
+		{
+			name:            "legacy validation errors",
+			accept:          "application/json",
+			path:            "/api/v1/register/validate/2",
+			body:            `{"streetAddress":"12 St James's Square"}`,
+			wantContentType: "application/json",
+			wantMembers:     []string{"code", "errors", "requestId"},
+		},
+		{
+			name:            "legacy error",
+			accept:          "application/json",
+			path:            "/api/v1/unknown",
+			wantContentType: "application/json",
+			wantMembers:     []string{"code", "message", "requestId"},
+		},
+	}
+
+	s := newDocumentedServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodGet
+			if tt.body != "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			s.RegisterRoutes().ServeHTTP(rec, req)
+
+			if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %q", contentType, tt.wantContentType)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body %s: %v", rec.Body, err)
+			}
+			members := slices.Sorted(maps.Keys(body))
+			if want := slices.Sorted(slices.Values(tt.wantMembers)); !slices.Equal(members, want) {
+				t.Errorf("body members = %v, want %v", members, want)
+			}
+		})
+	}
+}
@@ -6,19 +6,13 @@ import (
 	"time"
 
 	"multistep-registration/internal/database"
+	"multistep-registration/internal/domain"
 
 	"github.com/gin-gonic/gin"
 )
 
-type HealthResponse struct {
-	Status    string         `json:"status"`
-	Timestamp time.Time      `json:"timestamp"`
-	Services  map[string]any `json:"services"`
-	Version   string         `json:"version,omitempty"`
-}
-
 func (s *Server) healthHandler(c *gin.Context) {
-	response := HealthResponse{
+	response := domain.HealthResponse{
 		Status:    "healthy",
 		Timestamp: time.Now(),
 		Services:  make(map[string]any),
@@ -63,9 +57,9 @@ func (s *Server) healthHandler(c *gin.Context) {
 }
 
 func (s *Server) readinessHandler(c *gin.Context) {
-	response := gin.H{
-		"status":    "ready",
-		"timestamp": time.Now().Format(time.RFC3339),
+	response := domain.ReadinessResponse{
+		Status:    "ready",
+		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
 	if s.db != nil {
@@ -73,14 +67,14 @@ func (s *Server) readinessHandler(c *gin.Context) {
 		defer cancel()
 
 		if err := s.db.Pool.Ping(ctx); err != nil {
-			response["status"] = "not_ready"
-			response["database"] = "unavailable"
+			response.Status = "not_ready"
+			response.Database = "unavailable"
 			c.JSON(http.StatusServiceUnavailable, response)
 			return
 		}
-		response["database"] = "ready"
+		response.Database = "ready"
 	} else {
-		response["database"] = "not_configured"
+		response.Database = "not_configured"
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/context"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginMFA completes a login that requires a second factor
+func (s *Server) LoginMFA(c *gin.Context) {
+	var req domain.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "MFA token and code are required",
+		})
+		return
+	}
+
+	session, err := s.authService.CompleteMFALogin(c.Request.Context(), &req, domain.LoginMetadata{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		handleMFAError(c, err, "Failed to login")
+		return
+	}
+
+	s.setSessionCookie(c, session.Token, session.ExpiresAt)
+	c.JSON(http.StatusOK, session)
+}
+
+// EnrollMFA generates a TOTP secret for the authenticated user
+func (s *Server) EnrollMFA(c *gin.Context) {
+	user := context.MustGetUser(c)
+
+	resp, err := s.mfaService.Enroll(c.Request.Context(), user)
+	if err != nil {
+		handleMFAError(c, err, "Failed to start mfa enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmMFA enables MFA and returns the recovery codes
+func (s *Server) ConfirmMFA(c *gin.Context) {
+	user := context.MustGetUser(c)
+
+	var req domain.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "Code is required",
+		})
+		return
+	}
+
+	resp, err := s.mfaService.Confirm(c.Request.Context(), user, req.Code)
+	if err != nil {
+		handleMFAError(c, err, "Failed to confirm mfa")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DisableMFA turns MFA off after checking a TOTP or recovery code
+func (s *Server) DisableMFA(c *gin.Context) {
+	user := context.MustGetUser(c)
+
+	var req domain.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "Code is required",
+		})
+		return
+	}
+
+	if err := s.mfaService.Disable(c.Request.Context(), user, req.Code); err != nil {
+		handleMFAError(c, err, "Failed to disable mfa")
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.MessageResponse{
+		Message: "MFA has been disabled",
+	})
+}
+
+func handleMFAError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFAChallenge), errors.Is(err, service.ErrInvalidMFACode):
+		respondError(c, http.StatusUnauthorized, domain.ErrorResponse{
+			Code:    constants.CodeInvalidMFA,
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrMFAAlreadyEnabled),
+		errors.Is(err, service.ErrMFANotEnrolled),
+		errors.Is(err, service.ErrMFANotEnabled):
+		respondError(c, http.StatusConflict, domain.ErrorResponse{
+			Code:    constants.CodeMFAStateError,
+			Message: err.Error(),
+		})
+	default:
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: message,
+		})
+	}
+}
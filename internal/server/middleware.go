@@ -1,21 +1,91 @@
 package server
 
 import (
-	"log"
+	"bytes"
+	stdcontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/context"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/logging"
+	"multistep-registration/internal/metrics"
+	"multistep-registration/internal/ratelimit"
+	"multistep-registration/internal/requestid"
+	"multistep-registration/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func LoggingMiddleware() gin.HandlerFunc {
+// RequestIDMiddleware reuses the client's X-Request-ID when it is safe to
+// and generates one otherwise. The ID is echoed in the response and stored
+// in the request context for logs, error bodies and SQL comments
+func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Printf("Request: %s %s", c.Request.Method, c.Request.URL.Path)
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		c.Header(requestid.Header, id)
+		c.Request = c.Request.WithContext(requestid.WithContext(c.Request.Context(), id))
 
 		c.Next()
+	}
+}
+
+// LoggingMiddleware puts a request scoped logger into the request context
+// and writes one access log record per request. Errors attached with c.Error
+// are included, so handlers don't need to log the errors they respond with
+func LoggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestLogger := logger.With("request_id", requestid.FromContext(c.Request.Context()))
+		c.Request = c.Request.WithContext(logging.WithContext(c.Request.Context(), requestLogger))
 
-		log.Printf("Response: %s %s - %d",
-			c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if user, ok := context.GetUser(c); ok {
+			attrs = append(attrs, slog.String("user_id", user.ID.String()))
+		}
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", strings.Join(c.Errors.Errors(), "; ")))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		requestLogger.LogAttrs(c.Request.Context(), level, "request completed", attrs...)
 	}
 }
 
@@ -23,12 +93,14 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				stack := debug.Stack()
-				log.Printf("PANIC RECOVERED: %v\n%s", err, stack)
+				logging.FromContext(c.Request.Context()).Error("panic recovered",
+					"panic", fmt.Sprint(err),
+					"stack", string(debug.Stack()),
+				)
 
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"code":    "INTERNAL_ERROR",
-					"message": "An unexpected error occurred",
+				respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+					Code:    constants.CodeInternalError,
+					Message: "An unexpected error occurred",
 				})
 
 				c.Abort()
@@ -38,3 +110,323 @@ func RecoveryMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// DraftMiddleware lets registration be finalized from a draft. When the body
+// carries a draftId it is replaced with the full registration payload built
+// from the draft, so the validation chain runs exactly as for a regular submit
+func DraftMiddleware(drafts service.DraftService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeValidationError,
+				Message: "Invalid request data",
+			})
+			c.Abort()
+			return
+		}
+
+		var finalizeReq domain.FinalizeDraftRequest
+		if err := json.Unmarshal(body, &finalizeReq); err != nil || finalizeReq.DraftID == "" {
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Next()
+			return
+		}
+
+		regReq, err := drafts.BuildRegistrationRequest(c.Request.Context(), &finalizeReq)
+		if err != nil {
+			if errors.Is(err, service.ErrDraftNotFound) {
+				respondError(c, http.StatusNotFound, domain.ErrorResponse{
+					Code:    constants.CodeNotFound,
+					Message: err.Error(),
+				})
+			} else {
+				_ = c.Error(err)
+				respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+					Code:    constants.CodeInternalError,
+					Message: "Failed to load draft",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		payload, err := json.Marshal(regReq)
+		if err != nil {
+			_ = c.Error(err)
+			respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+				Code:    constants.CodeInternalError,
+				Message: "Failed to load draft",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		context.SetDraftID(c, finalizeReq.DraftID)
+
+		c.Next()
+	}
+}
+
+// AuthMiddleware resolves the session cookie into the authenticated user and
+// rejects the request when there is no valid session
+func AuthMiddleware(auth service.AuthService, cookieName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, _ := c.Cookie(cookieName)
+
+		user, err := auth.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, service.ErrUnauthorized) {
+				respondError(c, http.StatusUnauthorized, domain.ErrorResponse{
+					Code:    constants.CodeUnauthorized,
+					Message: err.Error(),
+				})
+			} else {
+				_ = c.Error(err)
+				respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+					Code:    constants.CodeInternalError,
+					Message: "Failed to authenticate",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		context.SetUser(c, user, token)
+		logger := logging.FromContext(c.Request.Context()).With("user_id", user.ID.String())
+		c.Request = c.Request.WithContext(logging.WithContext(c.Request.Context(), logger))
+
+		c.Next()
+	}
+}
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyMiddleware makes retried requests safe. The first request with
+// an Idempotency-Key header is handled and its response stored, a retry with
+// the same key and body gets the stored response replayed and a retry with a
+// different body is rejected. Requests without the header pass through
+func IdempotencyMiddleware(idempotency service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeValidationError,
+				Message: "Idempotency-Key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeValidationError,
+				Message: "Invalid request data",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		route, client := routeKey(c), idempotencyClient(c)
+		record, err := idempotency.Begin(c.Request.Context(), key, route, client, body)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrIdempotencyKeyReused):
+				respondError(c, http.StatusUnprocessableEntity, domain.ErrorResponse{
+					Code:    constants.CodeIdempotencyKeyReused,
+					Message: err.Error(),
+				})
+			case errors.Is(err, service.ErrIdempotencyRequestInProgress):
+				c.Header("Retry-After", "1")
+				respondError(c, http.StatusConflict, domain.ErrorResponse{
+					Code:    constants.CodeRequestInProgress,
+					Message: err.Error(),
+				})
+			default:
+				_ = c.Error(err)
+				respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+					Code:    constants.CodeInternalError,
+					Message: "Failed to process idempotency key",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		if record != nil {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		// The response must be stored even when the client already hung up,
+		// that is exactly the case the retry is going to hit
+		ctx := stdcontext.WithoutCancel(c.Request.Context())
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := idempotency.Release(ctx, key, route, client); err != nil {
+				logging.FromContext(ctx).Error("failed to release idempotency key", "error", err)
+			}
+			return
+		}
+
+		if err := idempotency.Complete(ctx, key, route, client, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			logging.FromContext(ctx).Error("failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+// idempotencyClient identifies who a key belongs to, the session user when
+// authenticated. Anonymous keys are scoped by key and route only, a retry
+// after a network switch comes from another IP. The body fingerprint keeps
+// other requests from replaying the response
+func idempotencyClient(c *gin.Context) string {
+	if user, ok := context.GetUser(c); ok {
+		return "user:" + user.ID.String()
+	}
+	return ""
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// RateLimitMiddleware limits requests per client IP and route. Store errors
+// let the request through, an outage of the limiter must not take the API down
+func RateLimitMiddleware(store ratelimit.Store, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := routeKey(c) + "|" + c.ClientIP()
+
+		result, err := store.Allow(c.Request.Context(), key, limit)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("rate limiter unavailable, allowing request", "error", err)
+			c.Next()
+			return
+		}
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			respondError(c, http.StatusTooManyRequests, domain.ErrorResponse{
+				Code:    constants.CodeRateLimited,
+				Message: "Too many requests, please try again later",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MetricsMiddleware records the duration of every request by route
+func MetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			// Unmatched paths would make the label unbounded
+			route = "unmatched"
+		}
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// RegistrationMetricsMiddleware counts registration outcomes, it has to run
+// before the validation chain to see its failures. Idempotent replays are
+// skipped, they were counted when first handled
+func RegistrationMetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Header().Get(IdempotentReplayedHeader) != "" {
+			return
+		}
+
+		if c.Writer.Status() == http.StatusCreated {
+			m.RegistrationSucceeded()
+			return
+		}
+
+		code := context.GetErrorCode(c)
+		if code == "" {
+			code = strconv.Itoa(c.Writer.Status())
+		}
+		m.RegistrationFailed(code)
+	}
+}
+
+// TracingMiddleware starts a server span per request, continuing the trace
+// from the W3C traceparent header when the caller sent one
+func TracingMiddleware() gin.HandlerFunc {
+	tracer := otel.Tracer("multistep-registration/internal/server")
+
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		spanName := c.Request.Method + " " + route
+		if route == "" {
+			spanName = c.Request.Method
+		}
+
+		ctx, span := tracer.Start(ctx, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				attribute.String("request.id", requestid.FromContext(ctx)),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last().Err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/problem"
+	"multistep-registration/internal/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type idempotencyID struct {
+	key, route, client string
+}
+
+// memoryIdempotency replays every completed response of the same key, route
+// and client
+type memoryIdempotency struct {
+	records map[idempotencyID]*domain.IdempotencyRecord
+}
+
+func (m *memoryIdempotency) Begin(_ context.Context, key, route, client string, _ []byte) (*domain.IdempotencyRecord, error) {
+	return m.records[idempotencyID{key, route, client}], nil
+}
+
+func (m *memoryIdempotency) Complete(_ context.Context, key, route, client string, statusCode int, contentType string, body []byte) error {
+	m.records[idempotencyID{key, route, client}] = &domain.IdempotencyRecord{StatusCode: statusCode, ContentType: contentType, ResponseBody: body}
+	return nil
+}
+
+func (m *memoryIdempotency) Release(_ context.Context, key, route, client string) error {
+	delete(m.records, idempotencyID{key, route, client})
+	return nil
+}
+
+func TestIdempotencyRetryFromAnotherIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	idempotency := &memoryIdempotency{records: map[idempotencyID]*domain.IdempotencyRecord{}}
+
+	handled := 0
+	engine := gin.New()
+	engine.POST("/register", IdempotencyMiddleware(idempotency), func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusCreated, gin.H{"id": "1"})
+	})
+
+	// The client switched from Wi-Fi to cellular before retrying
+	for _, remoteAddr := range []string{"192.0.2.1:4711", "198.51.100.7:4711"} {
+		req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"username":"adalovelace"}`))
+		req.Header.Set(IdempotencyKeyHeader, "4f9c1b7e-3a52-4d1e-9c7a-2b6f0e8d5a13")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("POST /register from %s = %d, want 201", remoteAddr, rec.Code)
+		}
+	}
+
+	if handled != 1 {
+		t.Errorf("handled %d times, want the retry to be replayed", handled)
+	}
+	for id := range idempotency.records {
+		if id.client != "" {
+			t.Errorf("anonymous key stored for client %q, want it scoped by key and route only", id.client)
+		}
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	tests := []struct {
+		name    string
+		inbound string
+		echoed  bool
+	}{
+		{"none", "", false},
+		{"valid", "checkout-7f3a:retry.2_b", true},
+		{"unsafe characters", "id'; DROP TABLE users; --", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	s := newDocumentedServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/unknown", nil)
+			if tt.inbound != "" {
+				req.Header.Set(requestid.Header, tt.inbound)
+			}
+			rec := httptest.NewRecorder()
+			s.RegisterRoutes().ServeHTTP(rec, req)
+
+			id := rec.Header().Get(requestid.Header)
+			if tt.echoed && id != tt.inbound {
+				t.Errorf("%s = %q, want the inbound %q", requestid.Header, id, tt.inbound)
+			}
+			if !tt.echoed {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("%s = %q, want a generated UUID", requestid.Header, id)
+				}
+			}
+
+			// The error body quotes the same ID
+			var details problem.Details
+			if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+				t.Fatal(err)
+			}
+			if details.RequestID != id {
+				t.Errorf("requestId = %q, want %q", details.RequestID, id)
+			}
+		})
+	}
+}
+
+func TestMetricsLabels(t *testing.T) {
+	s := newDocumentedServer(t)
+	s.setServices(Services{Draft: &fakeDrafts{id: "draft-1"}})
+	engine := s.RegisterRoutes().(*gin.Engine)
+	engine.GET("/api/v1/panic", func(*gin.Context) { panic("handler bug") })
+
+	for _, path := range []string{"/api/v1/drafts/draft-1", "/api/v1/drafts/draft-2", "/api/v1/panic", "/api/v1/unknown"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	scraped := rec.Body.String()
+
+	for _, series := range []string{
+		// Routes are labelled by their template, not the draft IDs
+		`registration_http_request_duration_seconds_count{method="GET",route="/api/v1/drafts/:id",status="200"} 1`,
+		`registration_http_request_duration_seconds_count{method="GET",route="/api/v1/drafts/:id",status="404"} 1`,
+		// Panics are counted once recovered
+		`registration_http_request_duration_seconds_count{method="GET",route="/api/v1/panic",status="500"} 1`,
+		`registration_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(scraped, series) {
+			t.Errorf("metrics are missing %s", series)
+		}
+	}
+	if strings.Contains(scraped, "draft-1") {
+		t.Error("metrics are labelled with a raw path")
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"fmt"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/openapi"
+	"multistep-registration/internal/problem"
+	"multistep-registration/internal/validation"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const openAPIPath = apiPrefix + "/openapi.json"
+
+// bodyDoc describes a request or response body in the document
+type bodyDoc func(doc *openapi.Document) *openapi.Schema
+
+func ref(v any) bodyDoc {
+	return func(doc *openapi.Document) *openapi.Schema {
+		return doc.Ref(v)
+	}
+}
+
+func oneOf(values ...any) bodyDoc {
+	return func(doc *openapi.Document) *openapi.Schema {
+		schema := &openapi.Schema{}
+		for _, v := range values {
+			schema.OneOf = append(schema.OneOf, doc.Ref(v))
+		}
+		return schema
+	}
+}
+
+// defineRegistrationRequest reuses the published JSON Schema, it carries the
+// patterns the validation chain enforces on top of the binding tags
+func defineRegistrationRequest(doc *openapi.Document, policy validation.PasswordPolicy) {
+	schema := *validation.RegistrationSchema(policy)
+	schema.Schema = ""
+	doc.Define("RegistrationRequest", &schema)
+}
+
+// definePasswordResetRequest lowers the binding tag limit of the new password
+// to what the password hasher accepts
+func definePasswordResetRequest(doc *openapi.Document, policy validation.PasswordPolicy) {
+	doc.Ref(domain.PasswordResetRequest{})
+	if maxLength := policy.PasswordMaxLength(); maxLength != validation.MaxPasswordLength {
+		schema := doc.Components.Schemas["PasswordResetRequest"]
+		doc.Define("PasswordResetRequest", validation.WithPasswordMaxLength(schema, maxLength))
+	}
+}
+
+// registrationRequest refers to the schema of defineRegistrationRequest
+func registrationRequest(doc *openapi.Document) *openapi.Schema {
+	return doc.NamedRef("RegistrationRequest", domain.RegistrationRequest{})
+}
+
+func queryParam(name, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+// openAPIDocument generates the document from the version registry and the
+// unversioned routes
+func (s *Server) openAPIDocument() *openapi.Document {
+	doc := openapi.NewDocument(openapi.Info{
+		Title:       "Multi-step registration API",
+		Version:     "1.0.0",
+		Description: "Errors are problem details (RFC 9457), clients sending Accept: application/json get the legacy ErrorResponse instead",
+	})
+	doc.Components.SecuritySchemes["session"] = openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        s.sessionCookie.Name,
+		Description: "Session cookie set by the login endpoints",
+	}
+
+	defineRegistrationRequest(doc, s.passwordPolicy)
+	definePasswordResetRequest(doc, s.passwordPolicy)
+
+	for _, version := range apiVersions {
+		for _, route := range version.routes {
+			s.addOperation(doc, route, apiPrefix+"/"+version.name, version.name+"."+route.id, nil)
+		}
+	}
+
+	if legacy, ok := lookupAPIVersion(legacyVersion); ok {
+		for _, route := range legacy.routes {
+			s.addOperation(doc, route, apiPrefix, "legacy."+route.id, &s.legacyDeprecation)
+		}
+	}
+
+	for _, route := range unversionedRoutes {
+		s.addOperation(doc, route, "", route.id, nil)
+	}
+
+	return doc
+}
+
+func (s *Server) addOperation(doc *openapi.Document, route apiRoute, prefix, id string, deprecation *Deprecation) {
+	path := prefix + route.path
+	op := &openapi.Operation{
+		OperationID: id,
+		Summary:     route.summary,
+		Tags:        []string{route.tag},
+		Parameters:  append(openapi.PathParameters(path), route.params...),
+		Responses:   make(map[string]*openapi.Response),
+	}
+
+	if route.request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{"application/json": {Schema: route.request(doc)}},
+		}
+	}
+
+	for status, body := range route.responses {
+		resp := &openapi.Response{Description: http.StatusText(status)}
+		if body != nil {
+			resp.Content = map[string]openapi.MediaType{"application/json": {Schema: body(doc)}}
+		}
+		op.Responses[openapi.StatusKey(status)] = resp
+	}
+
+	errors := slices.Clone(route.errors)
+	if route.auth {
+		op.Security = []map[string][]string{{"session": {}}}
+		errors = append(errors, http.StatusUnauthorized)
+	}
+	if route.limit != nil {
+		errors = append(errors, http.StatusTooManyRequests)
+	}
+	// Routes hashing passwords answer 503 when the hashing queue is full
+	if route.hashing {
+		errors = append(errors, http.StatusServiceUnavailable)
+	}
+	// Any route answers 500 when a handler panics
+	errors = append(errors, http.StatusInternalServerError)
+	for _, status := range errors {
+		op.Responses[openapi.StatusKey(status)] = errorResponse(doc, status)
+	}
+
+	if deprecation != nil {
+		op.Deprecated = true
+		op.Description = fmt.Sprintf("Deprecated alias of %s, removed on %s",
+			apiPrefix+"/"+legacyVersion+route.path, deprecation.SunsetAt.Format(time.DateOnly))
+	}
+
+	doc.AddOperation(route.method, path, op)
+}
+
+func errorResponse(doc *openapi.Document, status int) *openapi.Response {
+	legacy := doc.Ref(domain.ErrorResponse{})
+	if status == http.StatusBadRequest {
+		legacy = &openapi.Schema{AnyOf: []*openapi.Schema{legacy, doc.Ref(problem.LegacyValidationResponse{})}}
+	}
+
+	resp := &openapi.Response{
+		Description: http.StatusText(status),
+		Content: map[string]openapi.MediaType{
+			problem.ContentType: {Schema: doc.NamedRef("ProblemDetails", problem.Details{})},
+			"application/json":  {Schema: legacy},
+		},
+	}
+	switch status {
+	case http.StatusTooManyRequests:
+		resp.Headers = map[string]openapi.Header{
+			"Retry-After": {Description: "Seconds until the limit resets", Schema: &openapi.Schema{Type: "integer"}},
+		}
+	case http.StatusServiceUnavailable:
+		resp.Headers = map[string]openapi.Header{
+			"Retry-After": {Description: "Seconds to wait before retrying", Schema: &openapi.Schema{Type: "integer"}},
+		}
+	}
+	return resp
+}
+
+// OpenAPIDocument serves the document generated from the route tables
+func (s *Server) OpenAPIDocument(c *gin.Context) {
+	body, err := s.openAPIJSON()
+	if err != nil {
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: "Failed to generate the OpenAPI document",
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// undocumentedRoutes lists the routes of the engine missing from the
+// document, routes mounted outside the route tables
+func undocumentedRoutes(routes gin.RoutesInfo, doc *openapi.Document) []string {
+	var missing []string
+	for _, route := range routes {
+		if !doc.HasOperation(route.Method, route.Path) {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
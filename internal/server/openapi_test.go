@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"multistep-registration/internal/config"
+	"multistep-registration/internal/openapi"
+	"multistep-registration/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newDocumentedServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var cfg config.Config
+	cfg.API.LegacyDeprecatedAt = "2026-10-17"
+	cfg.API.LegacySunsetAt = "2027-04-30"
+	cfg.Session.CookieName = "session_id"
+	// Optional routes are mounted so they are checked too
+	cfg.Metrics.Enabled = true
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.CheckRequests = 30
+	cfg.RateLimit.CheckPeriodSeconds = 60
+	cfg.RateLimit.RegisterRequests = 10
+	cfg.RateLimit.RegisterPeriodSeconds = 3600
+	cfg.RateLimit.LoginRequests = 10
+	cfg.RateLimit.LoginPeriodSeconds = 300
+	cfg.RateLimit.MailRequests = 5
+	cfg.RateLimit.MailPeriodSeconds = 3600
+
+	s, err := New(Props{Config: &cfg, Logger: slog.New(slog.DiscardHandler)}, Services{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	s := newDocumentedServer(t)
+	engine := s.RegisterRoutes().(*gin.Engine)
+
+	if missing := undocumentedRoutes(engine.Routes(), s.openAPIDocument()); len(missing) > 0 {
+		t.Errorf("routes missing from the OpenAPI document: %v", missing)
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	s := newDocumentedServer(t)
+
+	rec := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200", openAPIPath, rec.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("document is not JSON: %v", err)
+	}
+
+	tests := []struct {
+		method, path string
+		status       string
+	}{
+		// Rate limits come from the route table
+		{"get", "/api/v1/check-username", "429"},
+		{"post", "/api/v1/register", "429"},
+		{"post", "/api/v1/login", "429"},
+		{"post", "/api/v1/login/mfa", "429"},
+		{"post", "/api/v1/password/forgot", "429"},
+		{"post", "/api/v1/verify-email/resend", "429"},
+		// Authenticated routes answer 401
+		{"get", "/api/v1/me", "401"},
+		// The deprecated alias is documented too
+		{"post", "/api/register", "201"},
+	}
+	for _, tt := range tests {
+		op := doc.Paths[tt.path][tt.method]
+		if op == nil {
+			t.Errorf("%s %s is not documented", tt.method, tt.path)
+			continue
+		}
+		if _, ok := op.Responses[tt.status]; !ok {
+			t.Errorf("%s %s does not document %s", tt.method, tt.path, tt.status)
+		}
+	}
+}
+
+func TestPasswordMaxLengthFollowsPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxLength int
+		want      int
+	}{
+		{"limited by the hasher", 72, 72},
+		{"no limit", 0, validation.MaxPasswordLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDocumentedServer(t)
+			s.passwordPolicy.MaxLength = tt.maxLength
+			doc := s.openAPIDocument()
+
+			for _, name := range []string{"RegistrationRequest", "PasswordResetRequest"} {
+				if got := doc.Components.Schemas[name].Properties["password"].MaxLength; got == nil || *got != tt.want {
+					t.Errorf("%s password maxLength = %v, want %d", name, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// The limit doesn't leak into the cached default schema
+	if got := *validation.RegistrationSchema(validation.DefaultPasswordPolicy).Properties["password"].MaxLength; got != validation.MaxPasswordLength {
+		t.Errorf("default schema password maxLength = %d, want %d", got, validation.MaxPasswordLength)
+	}
+}
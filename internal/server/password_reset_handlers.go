@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/context"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPassword starts the password reset flow. It answers the same way
+// whether or not the account exists
+func (s *Server) ForgotPassword(c *gin.Context) {
+	var req domain.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+			Code:    constants.CodeValidationError,
+			Message: "A valid email is required",
+		})
+		return
+	}
+
+	// The same response whether or not the account exists
+	s.passwordResetService.RequestReset(c.Request.Context(), req.Email)
+
+	c.JSON(http.StatusAccepted, domain.MessageResponse{
+		Message: "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password using a reset token
+func (s *Server) ResetPassword(c *gin.Context) {
+	req := context.MustGetPasswordResetRequest(c)
+
+	if err := s.passwordResetService.ResetPassword(c.Request.Context(), req); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeInvalidToken,
+				Message: err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, password.ErrTooLong) {
+			respondError(c, http.StatusBadRequest, domain.ErrorResponse{
+				Code:    constants.CodeValidationError,
+				Message: "Password is too long",
+			})
+			return
+		}
+		if errors.Is(err, password.ErrBusy) {
+			respondHashingBusy(c)
+			return
+		}
+		_ = c.Error(err)
+		respondError(c, http.StatusInternalServerError, domain.ErrorResponse{
+			Code:    constants.CodeInternalError,
+			Message: "Failed to reset password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.MessageResponse{
+		Message: "Password has been reset, please log in with the new password",
+	})
+}
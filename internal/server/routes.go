@@ -1,6 +1,11 @@
 package server
 
 import (
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/openapi"
+	"multistep-registration/internal/ratelimit"
+	"multistep-registration/internal/requestid"
 	"multistep-registration/internal/validation"
 	"net/http"
 
@@ -9,27 +14,334 @@ import (
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
-	r := gin.Default()
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	r.Use(RequestIDMiddleware(), TracingMiddleware(), LoggingMiddleware(s.logger))
+	if s.metrics != nil {
+		r.Use(MetricsMiddleware(s.metrics))
+	}
+	// Recovery runs inside logging and metrics, so they see the 500 of a panic
+	r.Use(RecoveryMiddleware())
+
+	if err := r.SetTrustedProxies(s.trustedProxies); err != nil {
+		s.logger.Warn("invalid trusted proxies, using the peer address as client IP", "error", err)
+		_ = r.SetTrustedProxies(nil)
+	}
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", IdempotencyKeyHeader, requestid.Header, "traceparent", "tracestate"},
+		ExposeHeaders:    []string{IdempotentReplayedHeader, "Retry-After", requestid.Header, "Deprecation", "Sunset", "Link"},
 		AllowCredentials: true,
 	}))
 
-	apiGroup := r.Group("/api")
-	apiGroup.Use(RecoveryMiddleware(), LoggingMiddleware())
-	{
-		registrationChain := validation.CreateDefaultRegistrationChain()
-		apiGroup.POST("/register", registrationChain.Middleware(), s.Register)
+	s.mountAPIVersions(r)
+	s.mountRoutes(r, unversionedRoutes)
+
+	r.NoRoute(func(c *gin.Context) {
+		respondError(c, http.StatusNotFound, domain.ErrorResponse{
+			Code:    constants.CodeNotFound,
+			Message: "No route matches " + c.Request.URL.Path,
+		})
+	})
+	r.NoMethod(func(c *gin.Context) {
+		respondError(c, http.StatusMethodNotAllowed, domain.ErrorResponse{
+			Code:    constants.CodeMethodNotAllowed,
+			Message: c.Request.Method + " is not allowed on " + c.Request.URL.Path,
+		})
+	})
+
+	return r
+}
+
+// apiRoute is an entry of a route table, it is both mounted on the engine
+// and documented in the OpenAPI document
+type apiRoute struct {
+	id      string
+	method  string
+	path    string
+	summary string
+	tag     string
+	auth    bool
+	hashing bool
+	params  []openapi.Parameter
+	request bodyDoc
+	// responses are the success responses, a nil body means no content
+	responses map[int]bodyDoc
+	errors    []int
 
-		apiGroup.GET("/check-username", s.CheckUsername)
-		apiGroup.GET("/check-email", s.CheckEmail)
+	// observe runs first and sees every outcome of the route, e.g. metrics
+	observe func(s *Server) gin.HandlerFunc
+	// limit is the rate limit of the route, nil when it is not limited
+	limit func(s *Server) ratelimit.Limit
+	// handlers are the middleware and handler of the route, they run after
+	// authentication and rate limiting. No handlers leave the route unmounted
+	handlers func(s *Server) []gin.HandlerFunc
+}
+
+// handle mounts a handler without route specific middleware
+func handle(handler func(*Server, *gin.Context)) func(s *Server) []gin.HandlerFunc {
+	return func(s *Server) []gin.HandlerFunc {
+		return []gin.HandlerFunc{func(c *gin.Context) { handler(s, c) }}
 	}
+}
 
-	r.GET("/health", s.healthHandler)
-	r.GET("/ready", s.readinessHandler)
+// mountRoutes mounts the routes of a table on the group
+func (s *Server) mountRoutes(g gin.IRoutes, routes []apiRoute) {
+	for _, route := range routes {
+		handlers := route.handlers(s)
+		if len(handlers) == 0 {
+			continue
+		}
 
-	return r
+		var chain []gin.HandlerFunc
+		if route.observe != nil {
+			chain = append(chain, route.observe(s))
+		}
+		if route.auth {
+			chain = append(chain, AuthMiddleware(s.authService, s.sessionCookie.Name))
+		}
+		if route.limit != nil {
+			chain = append(chain, s.rateLimit(route.limit(s)))
+		}
+		g.Handle(route.method, route.path, append(chain, handlers...)...)
+	}
+}
+
+// validationOptions configures the validation chains of the API
+func (s *Server) validationOptions() []validation.ChainOption {
+	opts := []validation.ChainOption{
+		validation.WithMode(validation.CollectAll),
+		validation.WithRecorder(s.metrics),
+		validation.WithPasswordPolicy(s.passwordPolicy),
+	}
+	// A nil checker must not become a non-nil interface
+	if s.breaches != nil {
+		opts = append(opts, validation.WithBreachChecker(s.breaches))
+	}
+	return opts
+}
+
+// v1Routes is the route table of the v1 API, it mounts the routes and
+// generates their OpenAPI operations. Paths are relative to the version mount
+var v1Routes = []apiRoute{
+	{
+		id: "register", method: http.MethodPost, path: "/register", tag: "registration", hashing: true,
+		summary: "Register a user, or finalize a draft when the body carries a draftId",
+		params: []openapi.Parameter{{
+			Name:        IdempotencyKeyHeader,
+			In:          "header",
+			Description: "Retries with the same key and body replay the first response. Keys of anonymous requests are shared by every client, so they must be unguessable, e.g. a UUID",
+			Schema:      &openapi.Schema{Type: "string"},
+		}},
+		request: func(doc *openapi.Document) *openapi.Schema {
+			return &openapi.Schema{OneOf: []*openapi.Schema{registrationRequest(doc), doc.Ref(domain.FinalizeDraftRequest{})}}
+		},
+		responses: map[int]bodyDoc{http.StatusCreated: ref(domain.RegistrationResponse{})},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+		observe:   func(s *Server) gin.HandlerFunc { return RegistrationMetricsMiddleware(s.metrics) },
+		limit:     func(s *Server) ratelimit.Limit { return s.registerLimit },
+		handlers: func(s *Server) []gin.HandlerFunc {
+			chain := validation.CreateDefaultRegistrationChain(s.validationOptions()...)
+			return []gin.HandlerFunc{IdempotencyMiddleware(s.idempotencyService), DraftMiddleware(s.draftService), chain.Middleware(), s.Register}
+		},
+	},
+	{
+		id: "validateStep", method: http.MethodPost, path: "/register/validate/:step", tag: "registration",
+		summary: "Validate the fields of one form step with the registration rules",
+		request: func(doc *openapi.Document) *openapi.Schema {
+			registrationRequest(doc)
+			return &openapi.Schema{
+				Type:        "object",
+				Description: "The RegistrationRequest fields of the step. The account setup step checks the password against the names and email, so it must carry the fields of the earlier steps too to get the result of /register",
+			}
+		},
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.StepValidationResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		handlers: func(s *Server) []gin.HandlerFunc {
+			chains := validation.CreateStepValidationChains(s.validationOptions()...)
+			return []gin.HandlerFunc{validation.StepMiddleware(chains), s.ValidateStep}
+		},
+	},
+	{
+		id: "checkUsername", method: http.MethodGet, path: "/check-username", tag: "registration",
+		summary:   "Check whether a username is available",
+		params:    []openapi.Parameter{queryParam("username", "Username to check")},
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.AvailabilityResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		limit:     func(s *Server) ratelimit.Limit { return s.checkLimit },
+		handlers:  handle((*Server).CheckUsername),
+	},
+	{
+		id: "checkEmail", method: http.MethodGet, path: "/check-email", tag: "registration",
+		summary:   "Check whether an email is available",
+		params:    []openapi.Parameter{queryParam("email", "Email to check")},
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.AvailabilityResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		limit:     func(s *Server) ratelimit.Limit { return s.checkLimit },
+		handlers:  handle((*Server).CheckEmail),
+	},
+	{
+		id: "verifyEmail", method: http.MethodPost, path: "/verify-email", tag: "email",
+		summary:   "Verify an email address with the token sent by email",
+		request:   ref(domain.VerifyEmailRequest{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.MessageResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		handlers:  handle((*Server).VerifyEmail),
+	},
+	{
+		id: "resendVerification", method: http.MethodPost, path: "/verify-email/resend", tag: "email",
+		summary:   "Send a new verification email",
+		request:   ref(domain.ResendVerificationRequest{}),
+		responses: map[int]bodyDoc{http.StatusAccepted: ref(domain.MessageResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		limit:     func(s *Server) ratelimit.Limit { return s.mailLimit },
+		handlers:  handle((*Server).ResendVerification),
+	},
+	{
+		id: "login", method: http.MethodPost, path: "/login", tag: "auth", hashing: true,
+		summary:   "Log in with a username or email, sets the session cookie unless MFA is required",
+		request:   ref(domain.LoginRequest{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.AuthSession{})},
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+		limit:     func(s *Server) ratelimit.Limit { return s.loginLimit },
+		handlers:  handle((*Server).Login),
+	},
+	{
+		id: "loginMFA", method: http.MethodPost, path: "/login/mfa", tag: "auth",
+		summary:   "Complete a login with a TOTP or recovery code",
+		request:   ref(domain.MFALoginRequest{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.AuthSession{})},
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized},
+		limit:     func(s *Server) ratelimit.Limit { return s.loginLimit },
+		handlers:  handle((*Server).LoginMFA),
+	},
+	{
+		id: "forgotPassword", method: http.MethodPost, path: "/password/forgot", tag: "auth",
+		summary:   "Send a password reset link",
+		request:   ref(domain.ForgotPasswordRequest{}),
+		responses: map[int]bodyDoc{http.StatusAccepted: ref(domain.MessageResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		limit:     func(s *Server) ratelimit.Limit { return s.mailLimit },
+		handlers:  handle((*Server).ForgotPassword),
+	},
+	{
+		id: "resetPassword", method: http.MethodPost, path: "/password/reset", tag: "auth", hashing: true,
+		summary:   "Set a new password with a reset token",
+		request:   ref(domain.PasswordResetRequest{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.MessageResponse{})},
+		errors:    []int{http.StatusBadRequest},
+		handlers: func(s *Server) []gin.HandlerFunc {
+			chain := validation.CreatePasswordResetChain(s.validationOptions()...)
+			return []gin.HandlerFunc{chain.Middleware(), s.ResetPassword}
+		},
+	},
+	{
+		id: "logout", method: http.MethodPost, path: "/logout", tag: "auth", auth: true,
+		summary:   "Revoke the current session",
+		responses: map[int]bodyDoc{http.StatusNoContent: nil},
+		handlers:  handle((*Server).Logout),
+	},
+	{
+		id: "me", method: http.MethodGet, path: "/me", tag: "auth", auth: true,
+		summary:   "Get the logged in user",
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.UserResponse{})},
+		handlers:  handle((*Server).Me),
+	},
+	{
+		id: "enrollMFA", method: http.MethodPost, path: "/mfa/enroll", tag: "mfa", auth: true,
+		summary:   "Start TOTP enrollment",
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.MFAEnrollResponse{})},
+		errors:    []int{http.StatusConflict},
+		handlers:  handle((*Server).EnrollMFA),
+	},
+	{
+		id: "confirmMFA", method: http.MethodPost, path: "/mfa/confirm", tag: "mfa", auth: true,
+		summary:   "Confirm TOTP enrollment and receive the recovery codes",
+		request:   ref(domain.MFACodeRequest{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.MFARecoveryCodesResponse{})},
+		errors:    []int{http.StatusBadRequest, http.StatusConflict},
+		handlers:  handle((*Server).ConfirmMFA),
+	},
+	{
+		id: "disableMFA", method: http.MethodPost, path: "/mfa/disable", tag: "mfa", auth: true,
+		summary:   "Disable TOTP with a current code",
+		request:   ref(domain.MFACodeRequest{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.MessageResponse{})},
+		errors:    []int{http.StatusBadRequest, http.StatusConflict},
+		handlers:  handle((*Server).DisableMFA),
+	},
+	{
+		id: "registrationSchema", method: http.MethodGet, path: "/schema/registration", tag: "registration",
+		summary: "Get the registration rules as JSON Schema",
+		responses: map[int]bodyDoc{http.StatusOK: func(doc *openapi.Document) *openapi.Schema {
+			return &openapi.Schema{Type: "object", Description: "JSON Schema 2020-12 document"}
+		}},
+		handlers: handle((*Server).RegistrationSchema),
+	},
+	{
+		id: "createDraft", method: http.MethodPost, path: "/drafts", tag: "drafts",
+		summary:   "Create an empty registration draft",
+		responses: map[int]bodyDoc{http.StatusCreated: ref(domain.DraftResponse{})},
+		handlers:  handle((*Server).CreateDraft),
+	},
+	{
+		id: "getDraft", method: http.MethodGet, path: "/drafts/:id", tag: "drafts",
+		summary:   "Get a registration draft",
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.DraftResponse{})},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+		handlers:  handle((*Server).GetDraft),
+	},
+	{
+		id: "saveDraftStep", method: http.MethodPut, path: "/drafts/:id/steps/:step", tag: "drafts",
+		summary:   "Save one step of a registration draft",
+		request:   oneOf(domain.PersonalInfoStep{}, domain.AddressDetailsStep{}, domain.AccountSetupStep{}),
+		responses: map[int]bodyDoc{http.StatusOK: ref(domain.DraftResponse{})},
+		errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+		handlers:  handle((*Server).SaveDraftStep),
+	},
+}
+
+// unversionedRoutes are mounted outside the versions, paths are absolute
+var unversionedRoutes = []apiRoute{
+	{
+		id: "health", method: http.MethodGet, path: "/health", tag: "operations",
+		summary: "Report the health of the API and its database",
+		responses: map[int]bodyDoc{
+			http.StatusOK:                 ref(domain.HealthResponse{}),
+			http.StatusServiceUnavailable: ref(domain.HealthResponse{}),
+		},
+		handlers: handle((*Server).healthHandler),
+	},
+	{
+		id: "ready", method: http.MethodGet, path: "/ready", tag: "operations",
+		summary: "Report whether the API can serve requests",
+		responses: map[int]bodyDoc{
+			http.StatusOK:                 ref(domain.ReadinessResponse{}),
+			http.StatusServiceUnavailable: ref(domain.ReadinessResponse{}),
+		},
+		handlers: handle((*Server).readinessHandler),
+	},
+	{
+		id: "metrics", method: http.MethodGet, path: "/metrics", tag: "operations",
+		summary: "Prometheus metrics, only served when METRICS_ENABLED is set",
+		responses: map[int]bodyDoc{http.StatusOK: func(doc *openapi.Document) *openapi.Schema {
+			return &openapi.Schema{Type: "string", Description: "Prometheus text exposition format"}
+		}},
+		handlers: func(s *Server) []gin.HandlerFunc {
+			if s.metrics == nil {
+				return nil
+			}
+			return []gin.HandlerFunc{gin.WrapH(s.metrics.Handler())}
+		},
+	},
+	{
+		id: "openapi", method: http.MethodGet, path: openAPIPath, tag: "operations",
+		summary: "Get this OpenAPI document",
+		responses: map[int]bodyDoc{http.StatusOK: func(doc *openapi.Document) *openapi.Schema {
+			return &openapi.Schema{Type: "object", Description: "OpenAPI 3.1 document"}
+		}},
+		handlers: handle((*Server).OpenAPIDocument),
+	},
 }
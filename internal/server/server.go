@@ -1,12 +1,23 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"multistep-registration/internal/breach"
 	"multistep-registration/internal/config"
 	"multistep-registration/internal/database"
+	"multistep-registration/internal/mailer"
+	"multistep-registration/internal/metrics"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/ratelimit"
 	"multistep-registration/internal/repository"
 	"multistep-registration/internal/service"
+	"multistep-registration/internal/validation"
 	"net/http"
+	"sync"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -15,32 +26,348 @@ import (
 type Props struct {
 	Config   *config.Config
 	Database *database.Database
+	Logger   *slog.Logger
+	// Breaches is the breached password corpus, nil disables the check. The
+	// caller closes it once the server has shut down
+	Breaches breach.Source
+}
+
+type sessionCookieConfig struct {
+	Name   string
+	Domain string
+	Secure bool
 }
 
 type Server struct {
-	port int
+	logger         *slog.Logger
+	port           int
+	trustedProxies []string
+	sessionCookie  sessionCookieConfig
+	// legacyDeprecation is announced on the unversioned /api alias
+	legacyDeprecation Deprecation
+
+	// rateLimitStore is nil when rate limiting is disabled
+	rateLimitStore ratelimit.Store
+	// metrics is nil when metrics are disabled
+	metrics       *metrics.Metrics
+	checkLimit    ratelimit.Limit
+	registerLimit ratelimit.Limit
+	loginLimit    ratelimit.Limit
+	mailLimit     ratelimit.Limit
+	// breaches is nil when the breached password check is disabled
+	breaches       *breach.Checker
+	passwordPolicy validation.PasswordPolicy
+	// openAPIJSON encodes the OpenAPI document once, on first request
+	openAPIJSON func() ([]byte, error)
+
+	db                       *database.Database
+	userService              service.UserService
+	draftService             service.DraftService
+	idempotencyService       service.IdempotencyService
+	emailVerificationService service.EmailVerificationService
+	authService              service.AuthService
+	passwordResetService     service.PasswordResetService
+	mfaService               service.MFAService
+}
 
-	db          *database.Database
-	userService service.UserService
+// Services are the services behind the handlers
+type Services struct {
+	User              service.UserService
+	Draft             service.DraftService
+	Idempotency       service.IdempotencyService
+	EmailVerification service.EmailVerificationService
+	Auth              service.AuthService
+	PasswordReset     service.PasswordResetService
+	MFA               service.MFAService
 }
 
-func NewServer(props Props) *http.Server {
+// New creates a Server of the given services, e.g. fakes in tests.
+// props.Database may be nil, the health checks then report it not configured
+func New(props Props, services Services) (*Server, error) {
+	s, err := newServer(props)
+	if err != nil {
+		return nil, err
+	}
+	s.setServices(services)
+	return s, nil
+}
+
+func (s *Server) setServices(services Services) {
+	s.userService = services.User
+	s.draftService = services.Draft
+	s.idempotencyService = services.Idempotency
+	s.emailVerificationService = services.EmailVerification
+	s.authService = services.Auth
+	s.passwordResetService = services.PasswordReset
+	s.mfaService = services.MFA
+}
+
+// newServer sets up everything but the services from the config
+func newServer(props Props) (*Server, error) {
+	logger := props.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	NewServer := &Server{
-		port: props.Config.Server.Port,
-		db:   props.Database,
+		logger:         logger,
+		port:           props.Config.Server.Port,
+		trustedProxies: props.Config.Server.TrustedProxies,
+		sessionCookie: sessionCookieConfig{
+			Name:   props.Config.Session.CookieName,
+			Domain: props.Config.Session.CookieDomain,
+			Secure: props.Config.Session.CookieSecure,
+		},
+		checkLimit: ratelimit.Limit{
+			Requests: props.Config.RateLimit.CheckRequests,
+			Period:   time.Duration(props.Config.RateLimit.CheckPeriodSeconds) * time.Second,
+		},
+		registerLimit: ratelimit.Limit{
+			Requests: props.Config.RateLimit.RegisterRequests,
+			Period:   time.Duration(props.Config.RateLimit.RegisterPeriodSeconds) * time.Second,
+		},
+		loginLimit: ratelimit.Limit{
+			Requests: props.Config.RateLimit.LoginRequests,
+			Period:   time.Duration(props.Config.RateLimit.LoginPeriodSeconds) * time.Second,
+		},
+		mailLimit: ratelimit.Limit{
+			Requests: props.Config.RateLimit.MailRequests,
+			Period:   time.Duration(props.Config.RateLimit.MailPeriodSeconds) * time.Second,
+		},
+		passwordPolicy: validation.PasswordPolicy{
+			MinScore:   props.Config.Security.PasswordMinScore,
+			ClassRules: props.Config.Security.PasswordClassRules,
+		},
+		db: props.Database,
+	}
+
+	NewServer.openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+		return json.Marshal(NewServer.openAPIDocument())
+	})
+
+	legacyDeprecation, err := parseDeprecation(props.Config.API.LegacyDeprecatedAt, props.Config.API.LegacySunsetAt)
+	if err != nil {
+		return nil, err
+	}
+	NewServer.legacyDeprecation = legacyDeprecation
+
+	if props.Config.RateLimit.Enabled {
+		if err := NewServer.validateRateLimits(); err != nil {
+			return nil, err
+		}
+		NewServer.rateLimitStore = newRateLimitStore(props.Config)
+	}
+
+	if props.Config.Metrics.Enabled {
+		NewServer.metrics = metrics.New()
+		if props.Database != nil {
+			if err := NewServer.metrics.Register(metrics.NewPoolCollector(props.Database.Pool)); err != nil {
+				return nil, fmt.Errorf("failed to register pool metrics: %w", err)
+			}
+		}
+	}
+
+	if props.Breaches != nil {
+		NewServer.breaches = breach.NewChecker(props.Breaches, props.Config.BreachedPasswords.MinCount)
+	}
+
+	return NewServer, nil
+}
+
+// HTTPServer serves the API. Shutdown also waits for the work requests left
+// running in the background, such as mail being sent
+type HTTPServer struct {
+	*http.Server
+	background *service.Background
+}
+
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	return errors.Join(s.Server.Shutdown(ctx), s.background.Wait(ctx))
+}
+
+func NewServer(props Props) (*HTTPServer, error) {
+	NewServer, err := newServer(props)
+	if err != nil {
+		return nil, err
+	}
+
+	passwords, err := newPasswordPolicy(props.Config)
+	if err != nil {
+		return nil, err
+	}
+	// Validation and the published schemas agree with the hasher
+	NewServer.passwordPolicy.MaxLength = passwords.MaxLength()
+
+	hashPool, err := password.NewPool(props.Config.Security.HashConcurrency, props.Config.Security.HashQueueDepth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hashing config: %w", err)
+	}
+	if err := NewServer.metrics.Register(metrics.NewHashingPoolCollector(hashPool)); err != nil {
+		return nil, fmt.Errorf("failed to register hashing pool metrics: %w", err)
+	}
+
+	var db database.DB = props.Database.Pool
+	if props.Config.Database.TagQueries {
+		db = database.NewRequestTaggedDB(db)
 	}
 
-	userRepo := repository.NewUserRepository(props.Database.Pool)
-	userService := service.NewUserService(userRepo, props.Config.Security.PasswordCost)
+	background := &service.Background{}
+	userRepo := repository.NewUserRepository(db)
+	userService := service.NewUserService(userRepo, passwords, hashPool, NewServer.metrics)
 	NewServer.userService = userService
 
+	draftRepo := repository.NewDraftRepository(db)
+	draftTTL := time.Duration(props.Config.Drafts.TTLHours) * time.Hour
+	NewServer.draftService = service.NewDraftService(draftRepo, draftTTL)
+
+	NewServer.idempotencyService = service.NewIdempotencyService(service.IdempotencyProps{
+		Repo:   repository.NewIdempotencyRepository(db),
+		Clock:  service.RealClock(),
+		TTL:    time.Duration(props.Config.Idempotency.TTLHours) * time.Hour,
+		Secret: props.Config.Security.TokenSecret,
+	})
+
+	mailer := newMailer(props.Config)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	NewServer.emailVerificationService = service.NewEmailVerificationService(service.EmailVerificationProps{
+		UserRepo:        userRepo,
+		TokenRepo:       userTokenRepo,
+		Background:      background,
+		Mailer:          mailer,
+		TokenSecret:     props.Config.Security.TokenSecret,
+		TokenTTL:        time.Duration(props.Config.Mail.VerificationTTLHours) * time.Hour,
+		VerificationURL: props.Config.Mail.VerificationURL,
+	})
+
+	sessionRepo := repository.NewSessionRepository(db)
+	sessionTTL := time.Duration(props.Config.Session.TTLHours) * time.Hour
+	mfaService, err := service.NewMFAService(service.MFAProps{
+		UserRepo:         userRepo,
+		RecoveryCodeRepo: repository.NewRecoveryCodeRepository(db),
+		Clock:            service.RealClock(),
+		Issuer:           props.Config.MFA.Issuer,
+		EncryptionKey:    props.Config.MFA.EncryptionKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	NewServer.mfaService = mfaService
+
+	NewServer.authService = service.NewAuthService(service.AuthProps{
+		UserRepo:    userRepo,
+		SessionRepo: sessionRepo,
+		TokenRepo:   userTokenRepo,
+		MFAService:  mfaService,
+		Passwords:   passwords,
+		HashPool:    hashPool,
+		Metrics:     NewServer.metrics,
+		SessionTTL:  sessionTTL,
+	})
+
+	NewServer.passwordResetService = service.NewPasswordResetService(service.PasswordResetProps{
+		UserRepo:    userRepo,
+		TokenRepo:   userTokenRepo,
+		Transactor:  repository.NewTransactor(db),
+		Background:  background,
+		Mailer:      mailer,
+		Passwords:   passwords,
+		HashPool:    hashPool,
+		Metrics:     NewServer.metrics,
+		TokenSecret: props.Config.Security.TokenSecret,
+		TokenTTL:    time.Duration(props.Config.Mail.PasswordResetTTLMinutes) * time.Minute,
+		ResetURL:    props.Config.Mail.PasswordResetURL,
+	})
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", NewServer.port),
 		Handler:      NewServer.RegisterRoutes(),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
+		ErrorLog:     slog.NewLogLogger(NewServer.logger.Handler(), slog.LevelError),
+	}
+
+	return &HTTPServer{Server: server, background: background}, nil
+}
+
+func parseDeprecation(deprecatedAt, sunsetAt string) (Deprecation, error) {
+	var deprecation Deprecation
+	var err error
+
+	if deprecation.DeprecatedAt, err = time.Parse(time.DateOnly, deprecatedAt); err != nil {
+		return deprecation, fmt.Errorf("invalid API deprecation date: %w", err)
 	}
+	if deprecation.SunsetAt, err = time.Parse(time.DateOnly, sunsetAt); err != nil {
+		return deprecation, fmt.Errorf("invalid API sunset date: %w", err)
+	}
+	return deprecation, nil
+}
 
-	return server
+func newPasswordPolicy(cfg *config.Config) (*password.Policy, error) {
+	policy, err := password.NewPolicyFor(cfg.Security.PasswordAlgorithm, cfg.Security.PasswordCost, password.Argon2Params{
+		MemoryKiB:   uint32(max(cfg.Security.Argon2MemoryKiB, 0)),
+		Iterations:  uint32(max(cfg.Security.Argon2Iterations, 0)),
+		Parallelism: uint8(min(max(cfg.Security.Argon2Parallelism, 0), 255)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hashing config: %w", err)
+	}
+
+	pepper, err := password.LoadPepper(cfg.Security.PepperCurrentKeyID, cfg.Security.PepperKeys, cfg.Security.PepperKeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password pepper config: %w", err)
+	}
+	if pepper != nil {
+		policy.WithPepper(pepper)
+	}
+	return policy, nil
+}
+
+func newMailer(cfg *config.Config) mailer.Mailer {
+	if cfg.Mail.Driver == "smtp" {
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		})
+	}
+
+	if cfg.Mail.OutboxPath != "" {
+		return mailer.NewFileOutbox(cfg.Mail.OutboxPath)
+	}
+	return mailer.NewMemoryOutbox()
+}
+
+// validateRateLimits fails startup on limits that would divide by zero in
+// the stores
+func (s *Server) validateRateLimits() error {
+	limits := []struct {
+		name  string
+		limit ratelimit.Limit
+	}{
+		{"RATE_LIMIT_CHECK", s.checkLimit},
+		{"RATE_LIMIT_REGISTER", s.registerLimit},
+		{"RATE_LIMIT_LOGIN", s.loginLimit},
+		{"RATE_LIMIT_MAIL", s.mailLimit},
+	}
+	for _, l := range limits {
+		if err := l.limit.Validate(); err != nil {
+			return fmt.Errorf("invalid %s_* rate limit: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+func newRateLimitStore(cfg *config.Config) ratelimit.Store {
+	if cfg.RateLimit.Store == "resp" {
+		return ratelimit.NewRESPStore(ratelimit.RESPConfig{
+			Addr:      cfg.RateLimit.RESPAddr,
+			Password:  cfg.RateLimit.RESPPassword,
+			Timeout:   time.Duration(cfg.RateLimit.RESPTimeoutMs) * time.Millisecond,
+			KeyPrefix: cfg.RateLimit.RESPKeyPrefix,
+		})
+	}
+	return ratelimit.NewMemoryStore()
 }
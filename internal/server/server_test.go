@@ -0,0 +1,61 @@
+package server
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"multistep-registration/internal/config"
+)
+
+func TestNewRejectsInvalidRateLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		wantErr   string
+	}{
+		{
+			name:      "zero period",
+			configure: func(cfg *config.Config) { cfg.RateLimit.CheckPeriodSeconds = 0 },
+			wantErr:   "RATE_LIMIT_CHECK_*",
+		},
+		{
+			name:      "negative requests",
+			configure: func(cfg *config.Config) { cfg.RateLimit.RegisterRequests = -1 },
+			wantErr:   "RATE_LIMIT_REGISTER_*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			cfg.API.LegacyDeprecatedAt = "2026-10-17"
+			cfg.API.LegacySunsetAt = "2027-04-30"
+			cfg.RateLimit.Enabled = true
+			cfg.RateLimit.CheckRequests = 30
+			cfg.RateLimit.CheckPeriodSeconds = 60
+			cfg.RateLimit.RegisterRequests = 10
+			cfg.RateLimit.RegisterPeriodSeconds = 3600
+			cfg.RateLimit.LoginRequests = 10
+			cfg.RateLimit.LoginPeriodSeconds = 300
+			cfg.RateLimit.MailRequests = 5
+			cfg.RateLimit.MailPeriodSeconds = 3600
+			tt.configure(&cfg)
+
+			_, err := New(Props{Config: &cfg, Logger: slog.New(slog.DiscardHandler)}, Services{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want it to name %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		var cfg config.Config
+		cfg.API.LegacyDeprecatedAt = "2026-10-17"
+		cfg.API.LegacySunsetAt = "2027-04-30"
+
+		if _, err := New(Props{Config: &cfg, Logger: slog.New(slog.DiscardHandler)}, Services{}); err != nil {
+			t.Errorf("New() error = %v, want limits to be ignored when rate limiting is disabled", err)
+		}
+	})
+}
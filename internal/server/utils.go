@@ -2,6 +2,13 @@ package server
 
 import (
 	"fmt"
+	"multistep-registration/internal/constants"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/problem"
+	"multistep-registration/internal/ratelimit"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
 )
 
 func getAvailabilityMessage(field, value string, available bool) string {
@@ -10,3 +17,31 @@ func getAvailabilityMessage(field, value string, available bool) string {
 	}
 	return fmt.Sprintf("%s '%s' is already taken", field, value)
 }
+
+// rateLimit returns the rate limiting middleware for the limit, or one that
+// lets everything through when rate limiting is disabled
+func (s *Server) rateLimit(limit ratelimit.Limit) gin.HandlerFunc {
+	if s.rateLimitStore == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return RateLimitMiddleware(s.rateLimitStore, limit)
+}
+
+// hashingRetryAfter is the Retry-After of requests rejected by a full hashing
+// queue, a queued hash takes well under a second
+const hashingRetryAfter = "1"
+
+// respondHashingBusy asks the client to retry once the hashing queue drains
+func respondHashingBusy(c *gin.Context) {
+	c.Header("Retry-After", hashingRetryAfter)
+	respondError(c, http.StatusServiceUnavailable, domain.ErrorResponse{
+		Code:    constants.CodeServiceBusy,
+		Message: "The service is busy, please try again later",
+	})
+}
+
+// respondError writes the error as problem details tagged with the request
+// ID, so clients can quote it when reporting a problem
+func respondError(c *gin.Context, status int, resp domain.ErrorResponse) {
+	problem.Respond(c, problem.New(status, resp.Code, resp.Message))
+}
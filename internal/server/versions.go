@@ -0,0 +1,106 @@
+package server
+
+import (
+	"multistep-registration/internal/context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiPrefix = "/api"
+
+	// legacyVersion is the contract served on the unversioned /api alias
+	legacyVersion = "v1"
+)
+
+// apiVersion mounts the routes of one API version under /api/<name>.
+// Versions share the services of the Server, a new version only brings the
+// handlers whose request or response types changed and reuses the others
+type apiVersion struct {
+	name   string
+	routes []apiRoute
+}
+
+// apiVersions is the registry of the mounted API versions
+var apiVersions = []apiVersion{
+	{name: "v1", routes: v1Routes},
+}
+
+// lookupAPIVersion returns the registered version by name
+func lookupAPIVersion(name string) (apiVersion, bool) {
+	for _, version := range apiVersions {
+		if version.name == name {
+			return version, true
+		}
+	}
+	return apiVersion{}, false
+}
+
+// mountAPIVersions mounts every registered version, and the legacy version
+// once more on /api with the deprecation headers
+func (s *Server) mountAPIVersions(r *gin.Engine) {
+	for _, version := range apiVersions {
+		group := r.Group(apiPrefix+"/"+version.name, apiVersionMiddleware(version.name))
+		s.mountRoutes(group, version.routes)
+	}
+
+	legacy, ok := lookupAPIVersion(legacyVersion)
+	if !ok {
+		return
+	}
+	alias := r.Group(apiPrefix,
+		apiVersionMiddleware(legacy.name),
+		DeprecationMiddleware(s.legacyDeprecation, apiPrefix+"/"+legacy.name),
+	)
+	s.mountRoutes(alias, legacy.routes)
+}
+
+func apiVersionMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		context.SetAPIVersion(c, version)
+		c.Next()
+	}
+}
+
+// Deprecation announces when a mount was deprecated and when it goes away
+type Deprecation struct {
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+}
+
+// DeprecationMiddleware sets the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers and links the same route under the successor prefix
+func DeprecationMiddleware(deprecation Deprecation, successorPrefix string) gin.HandlerFunc {
+	deprecatedAt := "@" + strconv.FormatInt(deprecation.DeprecatedAt.Unix(), 10)
+	sunsetAt := deprecation.SunsetAt.UTC().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		successor := successorPrefix + strings.TrimPrefix(c.Request.URL.Path, apiPrefix)
+
+		c.Header("Deprecation", deprecatedAt)
+		c.Header("Sunset", sunsetAt)
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// routeKey identifies the route independently of where its version is
+// mounted, so /api/register and /api/v1/register share rate limits and
+// idempotency keys
+func routeKey(c *gin.Context) string {
+	route := c.FullPath()
+	version, ok := context.GetAPIVersion(c)
+	if !ok {
+		return route
+	}
+
+	versionPrefix := apiPrefix + "/" + version
+	if strings.HasPrefix(route, versionPrefix) {
+		return route
+	}
+	return versionPrefix + strings.TrimPrefix(route, apiPrefix)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLegacyDeprecationHeaders(t *testing.T) {
+	deprecatedAt := "@" + strconv.FormatInt(time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC).Unix(), 10)
+
+	tests := []struct {
+		path            string
+		wantDeprecation string
+		wantSunset      string
+		wantLink        string
+	}{
+		{
+			path:            "/api/schema/registration",
+			wantDeprecation: deprecatedAt,
+			wantSunset:      "Fri, 30 Apr 2027 00:00:00 GMT",
+			wantLink:        `</api/v1/schema/registration>; rel="successor-version"`,
+		},
+		{
+			path:            "/api/drafts/draft-1",
+			wantDeprecation: deprecatedAt,
+			wantSunset:      "Fri, 30 Apr 2027 00:00:00 GMT",
+			wantLink:        `</api/v1/drafts/draft-1>; rel="successor-version"`,
+		},
+		// The versioned mounts are not deprecated
+		{path: "/api/v1/schema/registration"},
+		{path: "/api/v1/drafts/draft-1"},
+	}
+
+	s := newDocumentedServer(t)
+	s.setServices(Services{Draft: &fakeDrafts{id: "draft-1"}})
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.RegisterRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d: %s", tt.path, rec.Code, rec.Body)
+			}
+
+			for header, want := range map[string]string{
+				"Deprecation": tt.wantDeprecation,
+				"Sunset":      tt.wantSunset,
+				"Link":        tt.wantLink,
+			} {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/logging"
+	"multistep-registration/internal/metrics"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/repository"
+	"strings"
+	"time"
+)
+
+// mfaChallengeTTL bounds the time between the password and the second factor steps
+const mfaChallengeTTL = 5 * time.Minute
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid login or password")
+	ErrUnauthorized        = errors.New("authentication required")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa token, please login again")
+)
+
+type AuthService interface {
+	Login(ctx context.Context, req *domain.LoginRequest, meta domain.LoginMetadata) (*domain.AuthSession, error)
+	CompleteMFALogin(ctx context.Context, req *domain.MFALoginRequest, meta domain.LoginMetadata) (*domain.AuthSession, error)
+	Logout(ctx context.Context, token string) error
+	Authenticate(ctx context.Context, token string) (*domain.User, error)
+}
+
+type AuthProps struct {
+	UserRepo    repository.UserRepository
+	SessionRepo repository.SessionRepository
+	TokenRepo   repository.UserTokenRepository
+	MFAService  MFAService
+	Passwords   *password.Policy
+	HashPool    *password.Pool
+	Metrics     *metrics.Metrics
+	SessionTTL  time.Duration
+}
+
+type authService struct {
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	tokenRepo   repository.UserTokenRepository
+	mfa         MFAService
+	passwords   *password.Policy
+	hashPool    *password.Pool
+	metrics     *metrics.Metrics
+	ttl         time.Duration
+	// dummyHash is compared against when the user does not exist, so the
+	// response time doesn't reveal whether the login is registered
+	dummyHash password.Hash
+}
+
+func NewAuthService(props AuthProps) AuthService {
+	dummyHash, _ := props.Passwords.Hash("dummy-password")
+
+	return &authService{
+		userRepo:    props.UserRepo,
+		sessionRepo: props.SessionRepo,
+		tokenRepo:   props.TokenRepo,
+		mfa:         props.MFAService,
+		passwords:   props.Passwords,
+		hashPool:    props.HashPool,
+		metrics:     props.Metrics,
+		ttl:         props.SessionTTL,
+		dummyHash:   dummyHash,
+	}
+}
+
+func (s *authService) Login(ctx context.Context, req *domain.LoginRequest, meta domain.LoginMetadata) (*domain.AuthSession, error) {
+	user, err := s.findUser(ctx, req.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		// A full pool must answer the same for known and unknown users
+		if _, _, err := verifyPassword(ctx, s.metrics, s.hashPool, s.passwords, s.dummyHash, req.Password); errors.Is(err, password.ErrBusy) {
+			return nil, err
+		}
+		logging.FromContext(ctx).Info("login failed", "reason", "unknown user")
+		return nil, ErrInvalidCredentials
+	}
+
+	hash := password.Hash{Encoded: string(user.PasswordHash), PepperKeyID: user.PasswordPepperKeyID}
+	match, rehash, err := verifyPassword(ctx, s.metrics, s.hashPool, s.passwords, hash, req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !match {
+		logging.FromContext(ctx).Info("login failed", "reason", "invalid password", "user_id", user.ID.String())
+		return nil, ErrInvalidCredentials
+	}
+
+	// Passwords are flagged when the pepper key rotates
+	if rehash || user.PasswordRehashRequired {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	if user.MFAEnabledAt != nil {
+		return s.createMFAChallenge(ctx, user)
+	}
+
+	return s.createSession(ctx, user, meta)
+}
+
+// rehashPassword replaces an outdated hash or pepper after a successful login. A
+// failure only postpones the upgrade to the next login, so it is not fatal
+func (s *authService) rehashPassword(ctx context.Context, user *domain.User, pw string) {
+	logger := logging.FromContext(ctx).With("user_id", user.ID.String(), "algorithm", s.passwords.Preferred())
+
+	newHash, err := hashPassword(ctx, s.metrics, s.hashPool, s.passwords, pw)
+	if err != nil {
+		logger.Warn("failed to rehash password", "error", err)
+		return
+	}
+
+	// The update only applies while the old hash is current, a concurrent
+	// password reset wins
+	updated, err := s.userRepo.RehashUserPassword(ctx, user.ID, user.PasswordHash, []byte(newHash.Encoded), newHash.PepperKeyID)
+	if err != nil {
+		logger.Warn("failed to store rehashed password", "error", err)
+		return
+	}
+	if updated {
+		logger.Info("password rehashed", "pepper_key_id", newHash.PepperKeyID)
+	}
+}
+
+// CompleteMFALogin exchanges the MFA token from Login and a second factor for
+// a session. The MFA token is single use, a wrong code requires a new login
+func (s *authService) CompleteMFALogin(ctx context.Context, req *domain.MFALoginRequest, meta domain.LoginMetadata) (*domain.AuthSession, error) {
+	challenge, err := s.tokenRepo.ConsumeUserToken(ctx, hashToken(req.MFAToken), domain.TokenPurposeMFAChallenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume mfa challenge: %w", err)
+	}
+	if challenge == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	if err := s.mfa.VerifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	return s.createSession(ctx, user, meta)
+}
+
+func (s *authService) createMFAChallenge(ctx context.Context, user *domain.User) (*domain.AuthSession, error) {
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &domain.UserToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposeMFAChallenge,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.tokenRepo.CreateUserToken(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+
+	return &domain.AuthSession{
+		MFARequired: true,
+		MFAToken:    token,
+	}, nil
+}
+
+func (s *authService) createSession(ctx context.Context, user *domain.User, meta domain.LoginMetadata) (*domain.AuthSession, error) {
+	if _, err := s.sessionRepo.DeleteExpiredSessions(ctx); err != nil {
+		return nil, fmt.Errorf("failed to purge expired sessions: %w", err)
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.Session{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		UserAgent: truncate(meta.UserAgent, 255),
+		IPAddress: truncate(meta.IPAddress, 45),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return &domain.AuthSession{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		User:      ToUserResponse(user),
+	}, nil
+}
+
+func (s *authService) Logout(ctx context.Context, token string) error {
+	if err := s.sessionRepo.DeleteSessionByTokenHash(ctx, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to logout: %w", err)
+	}
+	return nil
+}
+
+// Authenticate resolves the user owning the session token
+func (s *authService) Authenticate(ctx context.Context, token string) (*domain.User, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+
+	session, err := s.sessionRepo.GetSessionByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrUnauthorized
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUnauthorized
+	}
+
+	if err := s.sessionRepo.TouchSession(ctx, session.ID); err != nil {
+		return nil, fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return user, nil
+}
+
+// findUser looks the user up by email when the login looks like one and by username otherwise
+func (s *authService) findUser(ctx context.Context, login string) (*domain.User, error) {
+	if strings.Contains(login, "@") {
+		user, err := s.userRepo.GetUserByEmail(ctx, login)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user by email: %w", err)
+		}
+		return user, nil
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+	return user, nil
+}
+
+func ToUserResponse(user *domain.User) *domain.UserResponse {
+	return &domain.UserResponse{
+		ID:            user.ID.String(),
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		MFAEnabled:    user.MFAEnabledAt != nil,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+// truncate cuts the value to maxLen characters to fit the column size
+func truncate(value string, maxLen int) string {
+	runes := []rune(value)
+	if len(runes) <= maxLen {
+		return value
+	}
+	return string(runes[:maxLen])
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/repository"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeAuthRepo mirrors the user and session queries: expired sessions are
+// invisible and a rehash only applies while the old hash is current
+type fakeAuthRepo struct {
+	repository.UserRepository
+	repository.SessionRepository
+	users    []*domain.User
+	sessions []*domain.Session
+	touched  []uuid.UUID
+	rehashed []*domain.User
+}
+
+func (r *fakeAuthRepo) GetUserByID(_ context.Context, id uuid.UUID) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAuthRepo) GetUserByEmail(_ context.Context, email string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAuthRepo) GetUserByUsername(_ context.Context, username string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAuthRepo) RehashUserPassword(_ context.Context, id uuid.UUID, oldHash, newHash []byte, pepperKeyID string) (bool, error) {
+	for _, user := range r.users {
+		if user.ID == id && bytes.Equal(user.PasswordHash, oldHash) {
+			rehashed := *user
+			rehashed.PasswordHash, rehashed.PasswordPepperKeyID, rehashed.PasswordRehashRequired = newHash, pepperKeyID, false
+			r.rehashed = append(r.rehashed, &rehashed)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAuthRepo) CreateSession(_ context.Context, session *domain.Session) error {
+	session.ID = uuid.New()
+	r.sessions = append(r.sessions, session)
+	return nil
+}
+
+func (r *fakeAuthRepo) GetSessionByTokenHash(_ context.Context, tokenHash []byte) (*domain.Session, error) {
+	for _, session := range r.sessions {
+		if bytes.Equal(session.TokenHash, tokenHash) && session.ExpiresAt.After(time.Now()) {
+			return session, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeAuthRepo) TouchSession(_ context.Context, id uuid.UUID) error {
+	r.touched = append(r.touched, id)
+	return nil
+}
+
+func (r *fakeAuthRepo) DeleteSessionByTokenHash(_ context.Context, tokenHash []byte) error {
+	for i, session := range r.sessions {
+		if bytes.Equal(session.TokenHash, tokenHash) {
+			r.sessions = append(r.sessions[:i], r.sessions[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) DeleteExpiredSessions(context.Context) (int64, error) {
+	return 0, nil
+}
+
+const adaPassword = "analytical engine punch cards 1843"
+
+// newAuthRepo knows Ada, whose password is hashed by passwords
+func newAuthRepo(t *testing.T, passwords *password.Policy) (*fakeAuthRepo, *domain.User) {
+	t.Helper()
+
+	hash, err := passwords.Hash(adaPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ada := &domain.User{
+		ID:                  uuid.New(),
+		Email:               "ada@example.com",
+		Username:            "adalovelace",
+		PasswordHash:        []byte(hash.Encoded),
+		PasswordPepperKeyID: hash.PepperKeyID,
+	}
+	return &fakeAuthRepo{users: []*domain.User{ada}}, ada
+}
+
+func newTestAuthService(repo *fakeAuthRepo, passwords *password.Policy, pool *password.Pool) AuthService {
+	return NewAuthService(AuthProps{
+		UserRepo:    repo,
+		SessionRepo: repo,
+		Passwords:   passwords,
+		HashPool:    pool,
+		SessionTTL:  time.Hour,
+	})
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		login       string
+		password    string
+		wantErr     error
+		wantSession bool
+	}{
+		{"by email", "ada@example.com", adaPassword, nil, true},
+		{"by username", "adalovelace", adaPassword, nil, true},
+		{"wrong password", "adalovelace", "difference engine", ErrInvalidCredentials, false},
+		{"unknown user", "grace@example.com", adaPassword, ErrInvalidCredentials, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passwords := password.NewPolicy(password.NewBcrypt(bcrypt.MinCost))
+			pool, err := password.NewPool(1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, ada := newAuthRepo(t, passwords)
+			svc := newTestAuthService(repo, passwords, pool)
+
+			session, err := svc.Login(context.Background(), &domain.LoginRequest{Login: tt.login, Password: tt.password}, domain.LoginMetadata{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			// Unknown users are verified against the dummy hash like known ones
+			if completed := pool.Stat().Completed; completed != 1 {
+				t.Errorf("%d passwords verified, want 1", completed)
+			}
+			if !tt.wantSession {
+				if len(repo.sessions) != 0 {
+					t.Errorf("failed login created %d sessions", len(repo.sessions))
+				}
+				return
+			}
+			if len(repo.sessions) != 1 || repo.sessions[0].UserID != ada.ID || !bytes.Equal(repo.sessions[0].TokenHash, hashToken(session.Token)) {
+				t.Errorf("sessions = %+v, want one for Ada's token", repo.sessions)
+			}
+		})
+	}
+}
+
+func TestLoginBusyPoolDoesNotRevealAccounts(t *testing.T) {
+	passwords := password.NewPolicy(password.NewBcrypt(bcrypt.MinCost))
+	pool, err := password.NewPool(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, _ := newAuthRepo(t, passwords)
+	svc := newTestAuthService(repo, passwords, pool)
+	ctx := context.Background()
+
+	// The pool is full while fn runs
+	if err := pool.Do(ctx, func() {
+		for _, login := range []string{"ada@example.com", "grace@example.com"} {
+			if _, err := svc.Login(ctx, &domain.LoginRequest{Login: login, Password: "difference engine"}, domain.LoginMetadata{}); !errors.Is(err, password.ErrBusy) {
+				t.Errorf("Login(%s) on a full pool error = %v, want %v", login, err, password.ErrBusy)
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		cost           int
+		rehashRequired bool
+		wantRehash     bool
+	}{
+		{"current hash", bcrypt.MinCost, false, false},
+		{"outdated cost", bcrypt.MinCost + 1, false, true},
+		{"flagged for rehash", bcrypt.MinCost, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, ada := newAuthRepo(t, password.NewPolicy(password.NewBcrypt(bcrypt.MinCost)))
+			ada.PasswordRehashRequired = tt.rehashRequired
+			passwords := password.NewPolicy(password.NewBcrypt(tt.cost))
+			svc := newTestAuthService(repo, passwords, nil)
+
+			if _, err := svc.Login(context.Background(), &domain.LoginRequest{Login: "adalovelace", Password: adaPassword}, domain.LoginMetadata{}); err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+
+			if !tt.wantRehash {
+				if len(repo.rehashed) != 0 {
+					t.Errorf("rehashed %d passwords, want none", len(repo.rehashed))
+				}
+				return
+			}
+			if len(repo.rehashed) != 1 {
+				t.Fatalf("rehashed %d passwords, want 1", len(repo.rehashed))
+			}
+			rehashed := password.Hash{Encoded: string(repo.rehashed[0].PasswordHash)}
+			if match, rehash, err := passwords.Verify(rehashed, adaPassword); !match || rehash || err != nil {
+				t.Errorf("Verify(rehashed) = %v, %v, %v, want a current hash of the password", match, rehash, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	passwords := password.NewPolicy(password.NewBcrypt(bcrypt.MinCost))
+	repo, ada := newAuthRepo(t, passwords)
+	svc := newTestAuthService(repo, passwords, nil)
+	ctx := context.Background()
+
+	session, err := svc.Login(ctx, &domain.LoginRequest{Login: "adalovelace", Password: adaPassword}, domain.LoginMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := svc.Authenticate(ctx, session.Token)
+	if err != nil || user.ID != ada.ID {
+		t.Fatalf("Authenticate() = %v, %v, want Ada", user, err)
+	}
+	if len(repo.touched) != 1 || repo.touched[0] != repo.sessions[0].ID {
+		t.Errorf("touched sessions %v, want Ada's session %v", repo.touched, repo.sessions[0].ID)
+	}
+
+	repo.sessions[0].ExpiresAt = time.Now().Add(-time.Second)
+	for name, token := range map[string]string{"expired": session.Token, "unknown": "unknown-token", "empty": ""} {
+		if _, err := svc.Authenticate(ctx, token); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Authenticate() with an %s session error = %v, want %v", name, err, ErrUnauthorized)
+		}
+	}
+	if len(repo.touched) != 1 {
+		t.Errorf("touched %d sessions, want only the valid one", len(repo.touched))
+	}
+}
+
+func TestLogoutRevokesOnlyItsSession(t *testing.T) {
+	passwords := password.NewPolicy(password.NewBcrypt(bcrypt.MinCost))
+	repo, _ := newAuthRepo(t, passwords)
+	grace, err := passwords.Hash("flow-matic compiler 1955")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.users = append(repo.users, &domain.User{ID: uuid.New(), Username: "gracehopper", PasswordHash: []byte(grace.Encoded)})
+	svc := newTestAuthService(repo, passwords, nil)
+	ctx := context.Background()
+
+	ada, err := svc.Login(ctx, &domain.LoginRequest{Login: "adalovelace", Password: adaPassword}, domain.LoginMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := svc.Login(ctx, &domain.LoginRequest{Login: "gracehopper", Password: "flow-matic compiler 1955"}, domain.LoginMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Logout(ctx, ada.Token); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, ada.Token); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Authenticate() after logout error = %v, want %v", err, ErrUnauthorized)
+	}
+	if user, err := svc.Authenticate(ctx, other.Token); err != nil || user.Username != "gracehopper" {
+		t.Errorf("Authenticate() of the other user = %v, %v, want their session to survive", user, err)
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"multistep-registration/internal/logging"
+	"sync"
+	"time"
+)
+
+// Background runs the work of a request that outlives its response, such as
+// sending mail, and lets shutdown wait for it
+type Background struct {
+	wg sync.WaitGroup
+}
+
+// Go runs fn with the request scoped logger and trace of ctx but not its
+// cancellation, bounded by timeout. An error is logged with msg
+func (b *Background) Go(ctx context.Context, timeout time.Duration, msg string, fn func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			logging.FromContext(ctx).Error(msg, "error", err)
+		}
+	}()
+}
+
+// Wait blocks until the running work is done or ctx ends
+func (b *Background) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,19 @@
+package service
+
+import "time"
+
+// Clock abstracts time so time dependent flows like TOTP can be tested with a fake one
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// RealClock returns a clock backed by time.Now
+func RealClock() Clock {
+	return realClock{}
+}
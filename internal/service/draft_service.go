@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/repository"
+	"time"
+)
+
+var (
+	ErrDraftNotFound    = errors.New("draft not found or expired")
+	ErrInvalidDraftStep = errors.New("invalid draft step")
+)
+
+type DraftService interface {
+	CreateDraft(ctx context.Context) (*domain.DraftResponse, error)
+	GetDraft(ctx context.Context, token string) (*domain.DraftResponse, error)
+	SaveDraftStep(ctx context.Context, token string, step int, data domain.DraftData) (*domain.DraftResponse, error)
+	DeleteDraft(ctx context.Context, token string) error
+	BuildRegistrationRequest(ctx context.Context, req *domain.FinalizeDraftRequest) (*domain.RegistrationRequest, error)
+}
+
+type draftService struct {
+	repo repository.DraftRepository
+	ttl  time.Duration
+}
+
+func NewDraftService(repo repository.DraftRepository, ttl time.Duration) DraftService {
+	return &draftService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+func (s *draftService) CreateDraft(ctx context.Context) (*domain.DraftResponse, error) {
+	// Expired drafts are already invisible to reads, this only keeps the table small
+	if _, err := s.repo.DeleteExpiredDrafts(ctx); err != nil {
+		return nil, fmt.Errorf("failed to purge expired drafts: %w", err)
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	draft := &domain.Draft{
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	if err := s.repo.CreateDraft(ctx, draft); err != nil {
+		return nil, fmt.Errorf("failed to create draft in database: %w", err)
+	}
+
+	return toDraftResponse(token, draft), nil
+}
+
+func (s *draftService) GetDraft(ctx context.Context, token string) (*domain.DraftResponse, error) {
+	draft, err := s.repo.GetDraftByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	if draft == nil {
+		return nil, ErrDraftNotFound
+	}
+
+	return toDraftResponse(token, draft), nil
+}
+
+// SaveDraftStep replaces the section of the draft that belongs to the given
+// step and extends the draft expiry. Only that section is written, so saves
+// of different steps don't overwrite each other
+func (s *draftService) SaveDraftStep(ctx context.Context, token string, step int, data domain.DraftData) (*domain.DraftResponse, error) {
+	draft := &domain.Draft{
+		TokenHash: hashToken(token),
+	}
+
+	switch step {
+	case domain.StepPersonalInfo:
+		draft.Data.PersonalInfo = data.PersonalInfo
+	case domain.StepAddressDetails:
+		draft.Data.AddressDetails = data.AddressDetails
+	case domain.StepAccountSetup:
+		draft.Data.AccountSetup = data.AccountSetup
+	default:
+		return nil, ErrInvalidDraftStep
+	}
+
+	draft.CurrentStep = step
+	draft.ExpiresAt = time.Now().Add(s.ttl)
+
+	updated, err := s.repo.UpdateDraftStep(ctx, draft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save draft step: %w", err)
+	}
+	if !updated {
+		return nil, ErrDraftNotFound
+	}
+
+	return toDraftResponse(token, draft), nil
+}
+
+func (s *draftService) DeleteDraft(ctx context.Context, token string) error {
+	if err := s.repo.DeleteDraftByTokenHash(ctx, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+	return nil
+}
+
+// BuildRegistrationRequest merges the stored draft steps with the password
+// fields from the finalize request into a full registration request
+func (s *draftService) BuildRegistrationRequest(ctx context.Context, req *domain.FinalizeDraftRequest) (*domain.RegistrationRequest, error) {
+	draft, err := s.repo.GetDraftByTokenHash(ctx, hashToken(req.DraftID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	if draft == nil {
+		return nil, ErrDraftNotFound
+	}
+
+	regReq := &domain.RegistrationRequest{
+		Password:        req.Password,
+		ConfirmPassword: req.ConfirmPassword,
+	}
+
+	if personal := draft.Data.PersonalInfo; personal != nil {
+		regReq.FirstName = personal.FirstName
+		regReq.LastName = personal.LastName
+		regReq.Email = personal.Email
+		regReq.PhoneNumber = personal.PhoneNumber
+	}
+
+	if address := draft.Data.AddressDetails; address != nil {
+		regReq.StreetAddress = address.StreetAddress
+		regReq.City = address.City
+		regReq.State = address.State
+		regReq.Country = address.Country
+	}
+
+	if account := draft.Data.AccountSetup; account != nil {
+		regReq.Username = account.Username
+		regReq.AcceptTerms = account.AcceptTerms
+		regReq.Newsletter = account.Newsletter
+	}
+
+	return regReq, nil
+}
+
+func toDraftResponse(token string, draft *domain.Draft) *domain.DraftResponse {
+	return &domain.DraftResponse{
+		ID:          token,
+		CurrentStep: draft.CurrentStep,
+		Data:        draft.Data,
+		ExpiresAt:   draft.ExpiresAt,
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// fakeDraftRepo mirrors the draft queries: expired drafts are invisible and
+// saving a step merges the set sections into the stored ones
+type fakeDraftRepo struct {
+	mu     sync.Mutex
+	drafts map[string]*domain.Draft
+}
+
+func newFakeDraftRepo() *fakeDraftRepo {
+	return &fakeDraftRepo{drafts: map[string]*domain.Draft{}}
+}
+
+func (r *fakeDraftRepo) CreateDraft(_ context.Context, draft *domain.Draft) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	draft.ID = uuid.New()
+	stored := *draft
+	r.drafts[string(draft.TokenHash)] = &stored
+	return nil
+}
+
+func (r *fakeDraftRepo) get(tokenHash []byte) *domain.Draft {
+	draft := r.drafts[string(tokenHash)]
+	if draft == nil || !draft.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	return draft
+}
+
+func (r *fakeDraftRepo) GetDraftByTokenHash(_ context.Context, tokenHash []byte) (*domain.Draft, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	draft := r.get(tokenHash)
+	if draft == nil {
+		return nil, nil
+	}
+	found := *draft
+	return &found, nil
+}
+
+func (r *fakeDraftRepo) UpdateDraftStep(_ context.Context, draft *domain.Draft) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.get(draft.TokenHash)
+	if stored == nil {
+		return false, nil
+	}
+	if draft.Data.PersonalInfo != nil {
+		stored.Data.PersonalInfo = draft.Data.PersonalInfo
+	}
+	if draft.Data.AddressDetails != nil {
+		stored.Data.AddressDetails = draft.Data.AddressDetails
+	}
+	if draft.Data.AccountSetup != nil {
+		stored.Data.AccountSetup = draft.Data.AccountSetup
+	}
+	stored.CurrentStep = max(stored.CurrentStep, draft.CurrentStep)
+	stored.ExpiresAt = draft.ExpiresAt
+
+	*draft = *stored
+	return true, nil
+}
+
+func (r *fakeDraftRepo) DeleteDraftByTokenHash(_ context.Context, tokenHash []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.drafts, string(tokenHash))
+	return nil
+}
+
+func (r *fakeDraftRepo) DeleteExpiredDrafts(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for hash, draft := range r.drafts {
+		if !draft.ExpiresAt.After(time.Now()) {
+			delete(r.drafts, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+var (
+	personalInfo   = &domain.PersonalInfoStep{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"}
+	addressDetails = &domain.AddressDetailsStep{StreetAddress: "12 St James's Square", City: "London", State: "London", Country: "United Kingdom"}
+	accountSetup   = &domain.AccountSetupStep{Username: "adalovelace", AcceptTerms: true}
+)
+
+func TestSaveDraftStepMergesSteps(t *testing.T) {
+	svc := NewDraftService(newFakeDraftRepo(), time.Hour)
+	ctx := context.Background()
+
+	draft, err := svc.CreateDraft(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		step int
+		data domain.DraftData
+	}{
+		{domain.StepPersonalInfo, domain.DraftData{PersonalInfo: personalInfo}},
+		{domain.StepAddressDetails, domain.DraftData{AddressDetails: addressDetails}},
+		{domain.StepAccountSetup, domain.DraftData{AccountSetup: accountSetup}},
+	}
+
+	// Steps saved at the same time, e.g. from two tabs, are all kept
+	var wg sync.WaitGroup
+	errs := make([]error, len(steps))
+	for i, s := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = svc.SaveDraftStep(ctx, draft.ID, s.step, s.data)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("SaveDraftStep() error = %v", err)
+	}
+
+	// A step only replaces its own section, whatever else the data carries
+	edited := &domain.PersonalInfoStep{FirstName: "Augusta", LastName: "King", Email: "ada@example.com"}
+	resp, err := svc.SaveDraftStep(ctx, draft.ID, domain.StepPersonalInfo, domain.DraftData{
+		PersonalInfo: edited,
+		AccountSetup: &domain.AccountSetupStep{Username: "overwritten"},
+	})
+	if err != nil {
+		t.Fatalf("SaveDraftStep() error = %v", err)
+	}
+
+	if resp.Data.PersonalInfo.FirstName != "Augusta" || resp.Data.AddressDetails == nil || resp.Data.AccountSetup.Username != "adalovelace" {
+		t.Errorf("draft data = %+v, want the edited personal info and the other steps", resp.Data)
+	}
+	// Going back to an earlier step keeps the progress
+	if resp.CurrentStep != domain.StepAccountSetup {
+		t.Errorf("current step = %d, want %d", resp.CurrentStep, domain.StepAccountSetup)
+	}
+}
+
+func TestSaveDraftStepRejectsInvalidStep(t *testing.T) {
+	svc := NewDraftService(newFakeDraftRepo(), time.Hour)
+	ctx := context.Background()
+
+	draft, err := svc.CreateDraft(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SaveDraftStep(ctx, draft.ID, 4, domain.DraftData{}); !errors.Is(err, ErrInvalidDraftStep) {
+		t.Errorf("SaveDraftStep(4) error = %v, want %v", err, ErrInvalidDraftStep)
+	}
+}
+
+func TestDraftNotFound(t *testing.T) {
+	repo := newFakeDraftRepo()
+	svc := NewDraftService(repo, time.Hour)
+	ctx := context.Background()
+
+	expired, err := svc.CreateDraft(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.drafts[string(hashToken(expired.ID))].ExpiresAt = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown", "unknown-draft"},
+		{"expired", expired.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.GetDraft(ctx, tt.token); !errors.Is(err, ErrDraftNotFound) {
+				t.Errorf("GetDraft() error = %v, want %v", err, ErrDraftNotFound)
+			}
+			if _, err := svc.SaveDraftStep(ctx, tt.token, domain.StepPersonalInfo, domain.DraftData{PersonalInfo: personalInfo}); !errors.Is(err, ErrDraftNotFound) {
+				t.Errorf("SaveDraftStep() error = %v, want %v", err, ErrDraftNotFound)
+			}
+			if _, err := svc.BuildRegistrationRequest(ctx, &domain.FinalizeDraftRequest{DraftID: tt.token}); !errors.Is(err, ErrDraftNotFound) {
+				t.Errorf("BuildRegistrationRequest() error = %v, want %v", err, ErrDraftNotFound)
+			}
+		})
+	}
+
+	// Creating a draft purges the expired ones
+	if _, err := svc.CreateDraft(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.drafts[string(hashToken(expired.ID))]; ok {
+		t.Error("expired draft was not purged")
+	}
+}
+
+func TestBuildRegistrationRequest(t *testing.T) {
+	svc := NewDraftService(newFakeDraftRepo(), time.Hour)
+	ctx := context.Background()
+
+	draft, err := svc.CreateDraft(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for step, data := range map[int]domain.DraftData{
+		domain.StepPersonalInfo:   {PersonalInfo: personalInfo},
+		domain.StepAddressDetails: {AddressDetails: addressDetails},
+		domain.StepAccountSetup:   {AccountSetup: accountSetup},
+	} {
+		if _, err := svc.SaveDraftStep(ctx, draft.ID, step, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := svc.BuildRegistrationRequest(ctx, &domain.FinalizeDraftRequest{
+		DraftID:         draft.ID,
+		Password:        "analytical engine punch cards 1843",
+		ConfirmPassword: "analytical engine punch cards 1843",
+	})
+	if err != nil {
+		t.Fatalf("BuildRegistrationRequest() error = %v", err)
+	}
+
+	want := domain.RegistrationRequest{
+		FirstName:       "Ada",
+		LastName:        "Lovelace",
+		Email:           "ada@example.com",
+		StreetAddress:   "12 St James's Square",
+		City:            "London",
+		State:           "London",
+		Country:         "United Kingdom",
+		Username:        "adalovelace",
+		Password:        "analytical engine punch cards 1843",
+		ConfirmPassword: "analytical engine punch cards 1843",
+		AcceptTerms:     true,
+	}
+	if *req != want {
+		t.Errorf("BuildRegistrationRequest() = %+v, want %+v", *req, want)
+	}
+}
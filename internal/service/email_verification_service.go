@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/mailer"
+	"multistep-registration/internal/repository"
+	"time"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+)
+
+// sendVerificationTimeout bounds the background work of sending a verification link
+const sendVerificationTimeout = 30 * time.Second
+
+type EmailVerificationService interface {
+	SendVerification(ctx context.Context, email string)
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+type EmailVerificationProps struct {
+	UserRepo        repository.UserRepository
+	TokenRepo       repository.UserTokenRepository
+	Background      *Background
+	Mailer          mailer.Mailer
+	TokenSecret     string
+	TokenTTL        time.Duration
+	VerificationURL string
+}
+
+type emailVerificationService struct {
+	userRepo        repository.UserRepository
+	tokenRepo       repository.UserTokenRepository
+	background      *Background
+	mailer          mailer.Mailer
+	signer          tokenSigner
+	ttl             time.Duration
+	verificationURL string
+}
+
+func NewEmailVerificationService(props EmailVerificationProps) EmailVerificationService {
+	return &emailVerificationService{
+		userRepo:        props.UserRepo,
+		tokenRepo:       props.TokenRepo,
+		background:      props.Background,
+		mailer:          props.Mailer,
+		signer:          newTokenSigner(props.TokenSecret),
+		ttl:             props.TokenTTL,
+		verificationURL: props.VerificationURL,
+	}
+}
+
+// SendVerification issues a new verification token and mails it. Unknown and
+// already verified addresses are silently ignored. It returns at once and
+// does the work in the background, so neither the response time nor a
+// failure can be used to probe for registered emails
+func (s *emailVerificationService) SendVerification(ctx context.Context, email string) {
+	s.background.Go(ctx, sendVerificationTimeout, "failed to send verification email", func(ctx context.Context) error {
+		return s.sendVerification(ctx, email)
+	})
+}
+
+func (s *emailVerificationService) sendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	// Only the latest verification link stays valid
+	if err := s.tokenRepo.DeleteUnusedUserTokens(ctx, user.ID, domain.TokenPurposeEmailVerification); err != nil {
+		return fmt.Errorf("failed to revoke previous tokens: %w", err)
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	userToken := &domain.UserToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposeEmailVerification,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.tokenRepo.CreateUserToken(ctx, userToken); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.FirstName, buildTokenLink(s.verificationURL, s.signer.sign(token)), s.ttl,
+		),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *emailVerificationService) VerifyEmail(ctx context.Context, signedToken string) error {
+	token, ok := s.signer.verify(signedToken)
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+
+	userToken, err := s.tokenRepo.ConsumeUserToken(ctx, hashToken(token), domain.TokenPurposeEmailVerification)
+	if err != nil {
+		return fmt.Errorf("failed to consume verification token: %w", err)
+	}
+	if userToken == nil {
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, userToken.UserID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/mailer"
+
+	"github.com/google/uuid"
+)
+
+func TestSendVerificationDoesNotRevealAccounts(t *testing.T) {
+	mail := blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+	background := &Background{}
+	svc := NewEmailVerificationService(EmailVerificationProps{
+		UserRepo:        fakeResetUsers{user: &domain.User{ID: uuid.New(), Email: "ada@example.com", FirstName: "Ada"}},
+		TokenRepo:       fakeResetTokens{},
+		Background:      background,
+		Mailer:          mail,
+		TokenSecret:     "test-secret",
+		TokenTTL:        time.Hour,
+		VerificationURL: "http://localhost:5173/verify-email",
+	})
+
+	for _, email := range []string{"ada@example.com", "nobody@example.com"} {
+		returned := make(chan struct{})
+		go func() {
+			svc.SendVerification(context.Background(), email)
+			close(returned)
+		}()
+
+		select {
+		case <-returned:
+		case <-time.After(time.Second):
+			t.Fatalf("SendVerification(%s) waited for the mail to be sent", email)
+		}
+	}
+
+	close(mail.release)
+	if err := background.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	select {
+	case msg := <-mail.sent:
+		if msg.To != "ada@example.com" || !strings.Contains(msg.Body, "http://localhost:5173/verify-email?token=") {
+			t.Errorf("mail = %+v, want a verification link to ada@example.com", msg)
+		}
+	default:
+		t.Fatal("the verification link was not mailed")
+	}
+}
+
+func TestSendVerificationSkipsVerifiedAccounts(t *testing.T) {
+	verifiedAt := time.Now()
+	mail := blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+	close(mail.release)
+	background := &Background{}
+	svc := NewEmailVerificationService(EmailVerificationProps{
+		UserRepo:   fakeResetUsers{user: &domain.User{ID: uuid.New(), Email: "ada@example.com", EmailVerifiedAt: &verifiedAt}},
+		TokenRepo:  fakeResetTokens{},
+		Background: background,
+		Mailer:     mail,
+		TokenTTL:   time.Hour,
+	})
+
+	svc.SendVerification(context.Background(), "ada@example.com")
+	if err := background.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	select {
+	case msg := <-mail.sent:
+		t.Errorf("mailed %+v to a verified account", msg)
+	default:
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/repository"
+	"time"
+)
+
+// idempotencyLockTimeout is how long a key stays locked by a request that
+// never completed, e.g. because the server crashed while handling it
+const idempotencyLockTimeout = time.Minute
+
+var (
+	ErrIdempotencyKeyReused         = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyRequestInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+type IdempotencyService interface {
+	// Begin claims the key of the client for the request. It returns nil
+	// when the request should be handled and the stored response when it
+	// should be replayed
+	Begin(ctx context.Context, key, route, client string, body []byte) (*domain.IdempotencyRecord, error)
+	Complete(ctx context.Context, key, route, client string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, key, route, client string) error
+}
+
+type IdempotencyProps struct {
+	Repo  repository.IdempotencyRepository
+	Clock Clock
+	TTL   time.Duration
+	// Secret keys the request fingerprint, request bodies carry passwords so
+	// a plain hash would be open to offline guessing
+	Secret string
+}
+
+type idempotencyService struct {
+	repo   repository.IdempotencyRepository
+	clock  Clock
+	ttl    time.Duration
+	secret []byte
+}
+
+func NewIdempotencyService(props IdempotencyProps) IdempotencyService {
+	clock := props.Clock
+	if clock == nil {
+		clock = RealClock()
+	}
+
+	return &idempotencyService{
+		repo:   props.Repo,
+		clock:  clock,
+		ttl:    props.TTL,
+		secret: []byte(props.Secret),
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, key, route, client string, body []byte) (*domain.IdempotencyRecord, error) {
+	// Expired keys are taken over by the claim, this only keeps the table small
+	if _, err := s.repo.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+		return nil, fmt.Errorf("failed to purge expired idempotency keys: %w", err)
+	}
+
+	now := s.clock.Now()
+	fingerprint := s.fingerprint(body)
+
+	record := &domain.IdempotencyRecord{
+		Key:         key,
+		Route:       route,
+		Client:      client,
+		RequestHash: fingerprint,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	claimed, err := s.repo.ClaimIdempotencyKey(ctx, record, now.Add(-idempotencyLockTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	existing, err := s.repo.GetIdempotencyKey(ctx, key, route, client)
+	if err != nil {
+		return nil, err
+	}
+	// The key was released between the claim and the lookup, the client can retry
+	if existing == nil {
+		return nil, ErrIdempotencyRequestInProgress
+	}
+
+	if !hmac.Equal(existing.RequestHash, fingerprint) {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.StatusCode == 0 {
+		return nil, ErrIdempotencyRequestInProgress
+	}
+
+	return existing, nil
+}
+
+// Complete stores the response replayed to later requests with the same key
+func (s *idempotencyService) Complete(ctx context.Context, key, route, client string, statusCode int, contentType string, body []byte) error {
+	record := &domain.IdempotencyRecord{
+		Key:          key,
+		Route:        route,
+		Client:       client,
+		StatusCode:   statusCode,
+		ContentType:  contentType,
+		ResponseBody: bytes.Clone(body),
+	}
+	if err := s.repo.CompleteIdempotencyKey(ctx, record); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees the key so the request can be retried, used when it failed
+// with an error the client isn't responsible for
+func (s *idempotencyService) Release(ctx context.Context, key, route, client string) error {
+	return s.repo.DeleteIdempotencyKey(ctx, key, route, client)
+}
+
+func (s *idempotencyService) fingerprint(body []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+)
+
+type idempotencyID struct {
+	key, route, client string
+}
+
+// fakeIdempotencyRepo keeps the keys in memory, expired keys are only removed
+// by DeleteExpiredIdempotencyKeys
+type fakeIdempotencyRepo struct {
+	clock   *fakeClock
+	records map[idempotencyID]*domain.IdempotencyRecord
+	purges  int
+}
+
+func (r *fakeIdempotencyRepo) ClaimIdempotencyKey(_ context.Context, record *domain.IdempotencyRecord, _ time.Time) (bool, error) {
+	id := idempotencyID{record.Key, record.Route, record.Client}
+	if _, ok := r.records[id]; ok {
+		return false, nil
+	}
+	claimed := *record
+	r.records[id] = &claimed
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepo) GetIdempotencyKey(_ context.Context, key, route, client string) (*domain.IdempotencyRecord, error) {
+	return r.records[idempotencyID{key, route, client}], nil
+}
+
+func (r *fakeIdempotencyRepo) CompleteIdempotencyKey(_ context.Context, record *domain.IdempotencyRecord) error {
+	if stored, ok := r.records[idempotencyID{record.Key, record.Route, record.Client}]; ok {
+		stored.StatusCode, stored.ContentType, stored.ResponseBody = record.StatusCode, record.ContentType, record.ResponseBody
+	}
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) DeleteIdempotencyKey(_ context.Context, key, route, client string) error {
+	delete(r.records, idempotencyID{key, route, client})
+	return nil
+}
+
+func (r *fakeIdempotencyRepo) DeleteExpiredIdempotencyKeys(context.Context) (int64, error) {
+	r.purges++
+	var deleted int64
+	for id, record := range r.records {
+		if !record.ExpiresAt.After(r.clock.Now()) {
+			delete(r.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestIdempotencyKeysAreScopedToTheClient(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_800_000_000, 0)}
+	repo := &fakeIdempotencyRepo{clock: clock, records: map[idempotencyID]*domain.IdempotencyRecord{}}
+	svc := NewIdempotencyService(IdempotencyProps{Repo: repo, Clock: clock, TTL: time.Hour, Secret: "test-secret"})
+	ctx := context.Background()
+	body := []byte(`{"username":"adalovelace"}`)
+
+	if record, err := svc.Begin(ctx, "key-1", "POST /register", "user:ada", body); err != nil || record != nil {
+		t.Fatalf("Begin() = %v, %v, want the request to be handled", record, err)
+	}
+	if err := svc.Complete(ctx, "key-1", "POST /register", "user:ada", http.StatusCreated, "application/json", []byte(`{"id":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another user with the same key neither gets the response nor is blocked
+	if record, err := svc.Begin(ctx, "key-1", "POST /register", "user:grace", []byte(`{"username":"other"}`)); err != nil || record != nil {
+		t.Errorf("Begin() of another client = %v, %v, want the request to be handled", record, err)
+	}
+
+	record, err := svc.Begin(ctx, "key-1", "POST /register", "user:ada", body)
+	if err != nil || record == nil || record.StatusCode != http.StatusCreated {
+		t.Fatalf("Begin() of a retry = %v, %v, want the stored response", record, err)
+	}
+
+	if _, err := svc.Begin(ctx, "key-1", "POST /register", "user:ada", []byte(`{}`)); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Begin() with another body error = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+}
+
+func TestIdempotencyBeginPurgesExpiredKeys(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_800_000_000, 0)}
+	repo := &fakeIdempotencyRepo{clock: clock, records: map[idempotencyID]*domain.IdempotencyRecord{}}
+	svc := NewIdempotencyService(IdempotencyProps{Repo: repo, Clock: clock, TTL: time.Hour, Secret: "test-secret"})
+	ctx := context.Background()
+
+	for _, key := range []string{"key-1", "key-2"} {
+		if _, err := svc.Begin(ctx, key, "POST /register", "user:ada", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+	if _, err := svc.Begin(ctx, "key-3", "POST /register", "user:ada", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.purges != 3 {
+		t.Errorf("purged %d times, want on every Begin", repo.purges)
+	}
+	if len(repo.records) != 1 {
+		t.Errorf("%d keys left, want only the new one", len(repo.records))
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/repository"
+	"multistep-registration/internal/totp"
+	"strings"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew accepts codes from one step before and after the current one
+	totpSkew = 1
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrMFANotEnrolled    = errors.New("mfa enrollment has not been started")
+	ErrMFANotEnabled     = errors.New("mfa is not enabled")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAService interface {
+	Enroll(ctx context.Context, user *domain.User) (*domain.MFAEnrollResponse, error)
+	Confirm(ctx context.Context, user *domain.User, code string) (*domain.MFARecoveryCodesResponse, error)
+	Disable(ctx context.Context, user *domain.User, code string) error
+	VerifySecondFactor(ctx context.Context, user *domain.User, code string) error
+}
+
+type MFAProps struct {
+	UserRepo         repository.UserRepository
+	RecoveryCodeRepo repository.RecoveryCodeRepository
+	Clock            Clock
+	Issuer           string
+	// EncryptionKey is the 32 bytes AES key the TOTP secrets are encrypted with
+	EncryptionKey []byte
+}
+
+type mfaService struct {
+	userRepo         repository.UserRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	clock            Clock
+	issuer           string
+	cipher           *secretCipher
+}
+
+func NewMFAService(props MFAProps) (MFAService, error) {
+	cipher, err := newSecretCipher(props.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mfa secret cipher: %w", err)
+	}
+
+	clock := props.Clock
+	if clock == nil {
+		clock = RealClock()
+	}
+
+	return &mfaService{
+		userRepo:         props.UserRepo,
+		recoveryCodeRepo: props.RecoveryCodeRepo,
+		clock:            clock,
+		issuer:           props.Issuer,
+		cipher:           cipher,
+	}, nil
+}
+
+// Enroll stores a new pending TOTP secret, MFA is enforced only once it is confirmed
+func (s *mfaService) Enroll(ctx context.Context, user *domain.User) (*domain.MFAEnrollResponse, error) {
+	if user.MFAEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.cipher.encrypt([]byte(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt mfa secret: %w", err)
+	}
+
+	if err := s.userRepo.SetUserMFASecret(ctx, user.ID, encrypted); err != nil {
+		return nil, fmt.Errorf("failed to store mfa secret: %w", err)
+	}
+
+	return &domain.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, s.issuer, user.Email),
+	}, nil
+}
+
+// Confirm enables MFA once the user proves the authenticator app is set up
+// and returns the recovery codes, they are never shown again. The codes are
+// stored first so an account never has MFA enabled without them
+func (s *mfaService) Confirm(ctx context.Context, user *domain.User, code string) (*domain.MFARecoveryCodesResponse, error) {
+	if user.MFAEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == nil {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if err := s.verifyTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = recoveryCode
+		hashes[i] = hashToken(normalizeRecoveryCode(recoveryCode))
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	if err := s.userRepo.EnableUserMFA(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	return &domain.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *mfaService) Disable(ctx context.Context, user *domain.User, code string) error {
+	if user.MFAEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if err := s.VerifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DisableUserMFA(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+
+	if err := s.recoveryCodeRepo.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+// VerifySecondFactor accepts either a TOTP code or an unused recovery code
+func (s *mfaService) VerifySecondFactor(ctx context.Context, user *domain.User, code string) error {
+	if user.MFAEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(ctx, user, code)
+	}
+
+	consumed, err := s.recoveryCodeRepo.ConsumeRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("failed to verify recovery code: %w", err)
+	}
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// verifyTOTP checks the code and records its step, so the same code can't be replayed
+func (s *mfaService) verifyTOTP(ctx context.Context, user *domain.User, code string) error {
+	secret, err := s.cipher.decrypt(user.MFASecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(string(secret), code, s.clock.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	updated, err := s.userRepo.UpdateUserMFALastUsedStep(ctx, user.ID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record mfa step: %w", err)
+	}
+	if !updated {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// generateRecoveryCode returns a code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/repository"
+	"multistep-registration/internal/totp"
+
+	"github.com/google/uuid"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeMFAStore keeps the MFA columns of one user and the recovery codes,
+// calls records the order of the writes
+type fakeMFAStore struct {
+	repository.UserRepository
+
+	secret        []byte
+	enabled       bool
+	lastUsedStep  int64
+	recoveryCodes [][]byte
+	replaceErr    error
+	calls         []string
+}
+
+func (f *fakeMFAStore) SetUserMFASecret(_ context.Context, _ uuid.UUID, secret []byte) error {
+	f.secret = secret
+	return nil
+}
+
+func (f *fakeMFAStore) EnableUserMFA(context.Context, uuid.UUID) error {
+	f.calls = append(f.calls, "EnableUserMFA")
+	f.enabled = true
+	return nil
+}
+
+func (f *fakeMFAStore) UpdateUserMFALastUsedStep(_ context.Context, _ uuid.UUID, step int64) (bool, error) {
+	// Mirrors WHERE mfa_last_used_step < $2
+	if step <= f.lastUsedStep {
+		return false, nil
+	}
+	f.lastUsedStep = step
+	return true, nil
+}
+
+func (f *fakeMFAStore) ReplaceRecoveryCodes(_ context.Context, _ uuid.UUID, hashes [][]byte) error {
+	f.calls = append(f.calls, "ReplaceRecoveryCodes")
+	if f.replaceErr != nil {
+		return f.replaceErr
+	}
+	f.recoveryCodes = hashes
+	return nil
+}
+
+func (f *fakeMFAStore) ConsumeRecoveryCode(_ context.Context, _ uuid.UUID, hash []byte) (bool, error) {
+	for i, stored := range f.recoveryCodes {
+		if bytes.Equal(stored, hash) {
+			f.recoveryCodes = append(f.recoveryCodes[:i], f.recoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeMFAStore) DeleteRecoveryCodes(context.Context, uuid.UUID) error {
+	f.recoveryCodes = nil
+	return nil
+}
+
+// newEnrolledMFA returns a service and a user who started enrollment, with
+// the plain TOTP secret to compute codes
+func newEnrolledMFA(t *testing.T) (MFAService, *fakeMFAStore, *fakeClock, *domain.User, string) {
+	t.Helper()
+
+	store := &fakeMFAStore{}
+	// Mid step, so the clock can move within it
+	clock := &fakeClock{now: time.Unix(1_800_000_015, 0)}
+	svc, err := NewMFAService(MFAProps{
+		UserRepo:         store,
+		RecoveryCodeRepo: store,
+		Clock:            clock,
+		Issuer:           "Test",
+		EncryptionKey:    bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &domain.User{ID: uuid.New(), Email: "ada@example.com"}
+	enrollment, err := svc.Enroll(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.MFASecret = store.secret
+
+	return svc, store, clock, user, enrollment.Secret
+}
+
+func currentCode(t *testing.T, secret string, clock *fakeClock) string {
+	t.Helper()
+	code, err := totp.Code(secret, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// confirmMFA confirms enrollment and marks the user as enabled, as reloading
+// it from the database would
+func confirmMFA(t *testing.T, svc MFAService, clock *fakeClock, user *domain.User, secret string) []string {
+	t.Helper()
+
+	resp, err := svc.Confirm(context.Background(), user, currentCode(t, secret, clock))
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	now := clock.Now()
+	user.MFAEnabledAt = &now
+	return resp.RecoveryCodes
+}
+
+func TestConfirmMFA(t *testing.T) {
+	svc, store, clock, user, secret := newEnrolledMFA(t)
+
+	if _, err := svc.Confirm(context.Background(), user, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("Confirm() with a wrong code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if store.enabled {
+		t.Fatal("MFA was enabled with a wrong code")
+	}
+
+	codes := confirmMFA(t, svc, clock, user, secret)
+	if len(codes) != recoveryCodeCount || len(store.recoveryCodes) != recoveryCodeCount {
+		t.Errorf("recovery codes = %d returned, %d stored, want %d", len(codes), len(store.recoveryCodes), recoveryCodeCount)
+	}
+	if want := []string{"ReplaceRecoveryCodes", "EnableUserMFA"}; strings.Join(store.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v", store.calls, want)
+	}
+
+	if _, err := svc.Confirm(context.Background(), user, currentCode(t, secret, clock)); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("second Confirm() error = %v, want %v", err, ErrMFAAlreadyEnabled)
+	}
+}
+
+func TestConfirmMFAKeepsMFADisabledWithoutRecoveryCodes(t *testing.T) {
+	svc, store, clock, user, secret := newEnrolledMFA(t)
+	store.replaceErr = errors.New("connection reset")
+
+	if _, err := svc.Confirm(context.Background(), user, currentCode(t, secret, clock)); !errors.Is(err, store.replaceErr) {
+		t.Fatalf("Confirm() error = %v, want %v", err, store.replaceErr)
+	}
+	if store.enabled {
+		t.Error("MFA was enabled although the recovery codes were not stored")
+	}
+}
+
+func TestVerifySecondFactorRejectsReplayedCodes(t *testing.T) {
+	svc, _, clock, user, secret := newEnrolledMFA(t)
+	confirmMFA(t, svc, clock, user, secret)
+	ctx := context.Background()
+
+	// The confirmation code was used in this step
+	if err := svc.VerifySecondFactor(ctx, user, currentCode(t, secret, clock)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed confirmation code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	previous := currentCode(t, secret, clock)
+	clock.now = clock.now.Add(totp.Period)
+	code := currentCode(t, secret, clock)
+
+	if err := svc.VerifySecondFactor(ctx, user, code); err != nil {
+		t.Fatalf("VerifySecondFactor() error = %v", err)
+	}
+	if err := svc.VerifySecondFactor(ctx, user, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replayed code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	// Within the skew, but older than the last used step
+	if err := svc.VerifySecondFactor(ctx, user, previous); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("code of an earlier step error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	svc, store, clock, user, secret := newEnrolledMFA(t)
+	codes := confirmMFA(t, svc, clock, user, secret)
+	ctx := context.Background()
+
+	if err := svc.VerifySecondFactor(ctx, user, codes[0]); err != nil {
+		t.Fatalf("VerifySecondFactor() error = %v", err)
+	}
+	if err := svc.VerifySecondFactor(ctx, user, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("reused recovery code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	// Codes are accepted however they are typed
+	typed := strings.ToUpper(strings.ReplaceAll(codes[1], "-", " "))
+	if err := svc.VerifySecondFactor(ctx, user, typed); err != nil {
+		t.Errorf("VerifySecondFactor(%q) error = %v", typed, err)
+	}
+
+	if len(store.recoveryCodes) != recoveryCodeCount-2 {
+		t.Errorf("%d recovery codes left, want %d", len(store.recoveryCodes), recoveryCodeCount-2)
+	}
+}
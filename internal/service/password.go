@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"multistep-registration/internal/metrics"
+	"multistep-registration/internal/password"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hashPassword runs the password policy on the hashing pool and records its
+// duration, the hashing parameters trade brute force resistance directly
+// against request latency. It returns password.ErrBusy when the pool is full
+func hashPassword(ctx context.Context, m *metrics.Metrics, pool *password.Pool, passwords *password.Policy, pw string) (hash password.Hash, err error) {
+	algorithm := passwords.Preferred()
+	ctx, span := tracer.Start(ctx, "password.hash", trace.WithAttributes(attribute.String("password.algorithm", algorithm)))
+	defer func() { endSpan(span, err) }()
+
+	poolErr := runHashing(ctx, pool, func() {
+		start := time.Now()
+		hash, err = passwords.Hash(pw)
+		m.ObservePasswordHash(algorithm, "hash", time.Since(start))
+	})
+	if poolErr != nil {
+		return password.Hash{}, poolErr
+	}
+	return hash, err
+}
+
+// verifyPassword reports whether the password matches and whether the hash
+// should be replaced because its algorithm, parameters or pepper key are
+// outdated. It returns password.ErrBusy when the pool is full
+func verifyPassword(ctx context.Context, m *metrics.Metrics, pool *password.Pool, passwords *password.Policy, hash password.Hash, pw string) (match bool, rehash bool, err error) {
+	algorithm := password.Algorithm(hash.Encoded)
+	ctx, span := tracer.Start(ctx, "password.verify", trace.WithAttributes(attribute.String("password.algorithm", algorithm)))
+	defer func() { endSpan(span, err) }()
+
+	poolErr := runHashing(ctx, pool, func() {
+		start := time.Now()
+		match, rehash, err = passwords.Verify(hash, pw)
+		m.ObservePasswordHash(algorithm, "verify", time.Since(start))
+	})
+	if poolErr != nil {
+		return false, false, poolErr
+	}
+	return match, rehash, err
+}
+
+// runHashing runs fn on the pool, without a pool it runs right away
+func runHashing(ctx context.Context, pool *password.Pool, fn func()) error {
+	if pool == nil {
+		fn()
+		return nil
+	}
+
+	// The span covers the wait for a slot, it ends when fn starts or when
+	// the pool gives up without running it
+	_, span := tracer.Start(ctx, "password.queue")
+	err := pool.Do(ctx, func() {
+		span.End()
+		fn()
+	})
+	if err != nil {
+		endSpan(span, err)
+	}
+	return err
+}
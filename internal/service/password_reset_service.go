@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/mailer"
+	"multistep-registration/internal/metrics"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/repository"
+	"time"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+)
+
+// requestResetTimeout bounds the background work of a reset request
+const requestResetTimeout = 30 * time.Second
+
+type PasswordResetService interface {
+	RequestReset(ctx context.Context, email string)
+	ResetPassword(ctx context.Context, req *domain.PasswordResetRequest) error
+}
+
+type PasswordResetProps struct {
+	UserRepo    repository.UserRepository
+	TokenRepo   repository.UserTokenRepository
+	Transactor  repository.Transactor
+	Background  *Background
+	Mailer      mailer.Mailer
+	Passwords   *password.Policy
+	HashPool    *password.Pool
+	Metrics     *metrics.Metrics
+	TokenSecret string
+	TokenTTL    time.Duration
+	ResetURL    string
+}
+
+type passwordResetService struct {
+	userRepo   repository.UserRepository
+	tokenRepo  repository.UserTokenRepository
+	transactor repository.Transactor
+	background *Background
+	mailer     mailer.Mailer
+	passwords  *password.Policy
+	hashPool   *password.Pool
+	metrics    *metrics.Metrics
+	signer     tokenSigner
+	ttl        time.Duration
+	resetURL   string
+}
+
+func NewPasswordResetService(props PasswordResetProps) PasswordResetService {
+	return &passwordResetService{
+		userRepo:   props.UserRepo,
+		tokenRepo:  props.TokenRepo,
+		transactor: props.Transactor,
+		background: props.Background,
+		mailer:     props.Mailer,
+		passwords:  props.Passwords,
+		hashPool:   props.HashPool,
+		metrics:    props.Metrics,
+		signer:     newTokenSigner(props.TokenSecret),
+		ttl:        props.TokenTTL,
+		resetURL:   props.ResetURL,
+	}
+}
+
+// RequestReset mails a reset link when the email belongs to a user and does
+// nothing otherwise. It returns at once and does the work in the background,
+// so neither the response time nor a failure reveals which case happened
+func (s *passwordResetService) RequestReset(ctx context.Context, email string) {
+	s.background.Go(ctx, requestResetTimeout, "failed to request password reset", func(ctx context.Context) error {
+		return s.requestReset(ctx, email)
+	})
+}
+
+func (s *passwordResetService) requestReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	// Only the latest reset link stays valid
+	if err := s.tokenRepo.DeleteUnusedUserTokens(ctx, user.ID, domain.TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to revoke previous tokens: %w", err)
+	}
+
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	userToken := &domain.UserToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposePasswordReset,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.tokenRepo.CreateUserToken(ctx, userToken); err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password:\n\n%s\n\nThe link expires in %s. If you didn't ask for a reset, you can ignore this email.\n",
+			user.FirstName, buildTokenLink(s.resetURL, s.signer.sign(token)), s.ttl,
+		),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets the new password and signs the user out everywhere. The
+// token is only used up when both succeed
+func (s *passwordResetService) ResetPassword(ctx context.Context, req *domain.PasswordResetRequest) error {
+	token, ok := s.signer.verify(req.Token)
+	if !ok {
+		return ErrInvalidResetToken
+	}
+
+	// Hashing first keeps the token usable when the password is rejected
+	passwordHash, err := hashPassword(ctx, s.metrics, s.hashPool, s.passwords, req.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.transactor.InTx(ctx, func(repos repository.TxRepositories) error {
+		userToken, err := repos.Tokens.ConsumeUserToken(ctx, hashToken(token), domain.TokenPurposePasswordReset)
+		if err != nil {
+			return fmt.Errorf("failed to consume reset token: %w", err)
+		}
+		if userToken == nil {
+			return ErrInvalidResetToken
+		}
+
+		if err := repos.Users.UpdateUserPassword(ctx, userToken.UserID, []byte(passwordHash.Encoded), passwordHash.PepperKeyID); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		if err := repos.Sessions.DeleteUserSessions(ctx, userToken.UserID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/mailer"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/repository"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeResetUsers struct {
+	repository.UserRepository
+	user *domain.User
+}
+
+func (f fakeResetUsers) GetUserByEmail(_ context.Context, email string) (*domain.User, error) {
+	if email != f.user.Email {
+		return nil, nil
+	}
+	return f.user, nil
+}
+
+type fakeResetTokens struct {
+	repository.UserTokenRepository
+}
+
+func (fakeResetTokens) DeleteUnusedUserTokens(context.Context, uuid.UUID, string) error {
+	return nil
+}
+
+func (fakeResetTokens) CreateUserToken(context.Context, *domain.UserToken) error {
+	return nil
+}
+
+// blockingMailer holds every message until released, then fails like an
+// unreachable SMTP server
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func (m blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	m.sent <- msg
+	return errors.New("connection refused")
+}
+
+func TestRequestResetDoesNotRevealAccounts(t *testing.T) {
+	mail := blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+	background := &Background{}
+	svc := NewPasswordResetService(PasswordResetProps{
+		UserRepo:    fakeResetUsers{user: &domain.User{ID: uuid.New(), Email: "ada@example.com", FirstName: "Ada"}},
+		TokenRepo:   fakeResetTokens{},
+		Background:  background,
+		Mailer:      mail,
+		TokenSecret: "test-secret",
+		TokenTTL:    time.Hour,
+		ResetURL:    "http://localhost:5173/reset-password",
+	})
+
+	for _, email := range []string{"ada@example.com", "nobody@example.com"} {
+		returned := make(chan struct{})
+		// A canceled request context must not abort the reset either
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			svc.RequestReset(ctx, email)
+			close(returned)
+		}()
+
+		select {
+		case <-returned:
+		case <-time.After(time.Second):
+			t.Fatalf("RequestReset(%s) waited for the mail to be sent", email)
+		}
+		cancel()
+	}
+
+	// Shutdown waits for the pending mail
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := background.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() with a mail pending = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(mail.release)
+	select {
+	case msg := <-mail.sent:
+		if msg.To != "ada@example.com" || !strings.Contains(msg.Body, "http://localhost:5173/reset-password?token=") {
+			t.Errorf("mail = %+v, want a reset link to ada@example.com", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the reset link was not mailed")
+	}
+
+	if err := background.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+}
+
+// resetState is what a reset writes to the database
+type resetState struct {
+	tokenUsed       bool
+	passwordHash    []byte
+	sessionsRevoked bool
+}
+
+// fakeResetTransactor commits the writes of a transaction only when it succeeds
+type fakeResetTransactor struct {
+	userID    uuid.UUID
+	tokenHash []byte
+	updateErr error
+	committed resetState
+}
+
+func (f *fakeResetTransactor) InTx(_ context.Context, fn func(repository.TxRepositories) error) error {
+	tx := &fakeResetTx{transactor: f, state: f.committed}
+	if err := fn(repository.TxRepositories{Users: tx, Tokens: tx, Sessions: tx}); err != nil {
+		return err
+	}
+	f.committed = tx.state
+	return nil
+}
+
+type fakeResetTx struct {
+	repository.UserRepository
+	repository.UserTokenRepository
+	repository.SessionRepository
+
+	transactor *fakeResetTransactor
+	state      resetState
+}
+
+func (tx *fakeResetTx) ConsumeUserToken(_ context.Context, tokenHash []byte, _ string) (*domain.UserToken, error) {
+	if tx.state.tokenUsed || !bytes.Equal(tokenHash, tx.transactor.tokenHash) {
+		return nil, nil
+	}
+	tx.state.tokenUsed = true
+	return &domain.UserToken{UserID: tx.transactor.userID}, nil
+}
+
+func (tx *fakeResetTx) UpdateUserPassword(_ context.Context, _ uuid.UUID, passwordHash []byte, _ string) error {
+	if tx.transactor.updateErr != nil {
+		return tx.transactor.updateErr
+	}
+	tx.state.passwordHash = passwordHash
+	return nil
+}
+
+func (tx *fakeResetTx) DeleteUserSessions(context.Context, uuid.UUID) error {
+	tx.state.sessionsRevoked = true
+	return nil
+}
+
+func TestResetPasswordKeepsTheTokenWhenTheUpdateFails(t *testing.T) {
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := password.NewPool(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactor := &fakeResetTransactor{userID: uuid.New(), tokenHash: tokenHash, updateErr: errors.New("connection reset")}
+	svc := NewPasswordResetService(PasswordResetProps{
+		Transactor:  transactor,
+		Passwords:   password.NewPolicy(password.NewBcrypt(bcrypt.MinCost)),
+		HashPool:    pool,
+		TokenSecret: "test-secret",
+	})
+	req := &domain.PasswordResetRequest{Token: newTokenSigner("test-secret").sign(token), Password: "correct horse battery staple"}
+	ctx := context.Background()
+
+	if err := svc.ResetPassword(ctx, req); !errors.Is(err, transactor.updateErr) {
+		t.Fatalf("ResetPassword() error = %v, want %v", err, transactor.updateErr)
+	}
+	if state := transactor.committed; state.tokenUsed || state.passwordHash != nil || state.sessionsRevoked {
+		t.Fatalf("failed reset committed %+v, want nothing", state)
+	}
+
+	// The token still works once the database is back
+	transactor.updateErr = nil
+	if err := svc.ResetPassword(ctx, req); err != nil {
+		t.Fatalf("ResetPassword() retry error = %v", err)
+	}
+	if state := transactor.committed; !state.tokenUsed || state.passwordHash == nil || !state.sessionsRevoked {
+		t.Errorf("committed %+v, want the token used, the password updated and the sessions revoked", state)
+	}
+
+	if err := svc.ResetPassword(ctx, req); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("ResetPassword() with a used token error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"multistep-registration/internal/password"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunHashingQueueSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	pool, err := password.NewPool(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	var ran bool
+	if err := runHashing(ctx, pool, func() {
+		ran = true
+		// The pool is full while fn runs
+		if err := runHashing(ctx, pool, func() {}); !errors.Is(err, password.ErrBusy) {
+			t.Errorf("runHashing() on a full pool error = %v, want %v", err, password.ErrBusy)
+		}
+	}); err != nil || !ran {
+		t.Fatalf("runHashing() = %v, ran %v", err, ran)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(spans))
+	}
+	// The first span ends once fn starts, before the rejected one
+	if queued := spans[0]; queued.Name() != "password.queue" || queued.Status().Code == codes.Error {
+		t.Errorf("queued span = %s %v, want an ok password.queue span", queued.Name(), queued.Status())
+	}
+	if rejected := spans[1]; rejected.Status().Code != codes.Error {
+		t.Errorf("rejected span status = %v, want an error", rejected.Status())
+	}
+}
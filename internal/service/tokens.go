@@ -0,0 +1,83 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const tokenBytes = 32
+
+// generateToken returns an opaque URL-safe token for the client and its hash,
+// only the hash is ever persisted
+func generateToken() (string, []byte, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// tokenSigner appends an HMAC signature to tokens, so forged tokens are
+// rejected before any database lookup
+type tokenSigner struct {
+	secret []byte
+}
+
+func newTokenSigner(secret string) tokenSigner {
+	return tokenSigner{secret: []byte(secret)}
+}
+
+func (s tokenSigner) sign(token string) string {
+	return token + "." + base64.RawURLEncoding.EncodeToString(s.mac(token))
+}
+
+// verify returns the unsigned token when the signature is valid
+func (s tokenSigner) verify(signed string) (string, bool) {
+	token, signature, found := strings.Cut(signed, ".")
+	if !found {
+		return "", false
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal(decoded, s.mac(token)) {
+		return "", false
+	}
+
+	return token, true
+}
+
+func (s tokenSigner) mac(token string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(token))
+	return h.Sum(nil)
+}
+
+// buildTokenLink appends the token as a query param to the frontend page URL
+func buildTokenLink(pageURL, token string) string {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL + "?token=" + url.QueryEscape(token)
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String()
+}
@@ -5,9 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"multistep-registration/internal/domain"
+	"multistep-registration/internal/metrics"
+	"multistep-registration/internal/password"
 	"multistep-registration/internal/repository"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -22,18 +22,25 @@ type UserService interface {
 }
 
 type userService struct {
-	repo repository.UserRepository
-	cost int
+	repo      repository.UserRepository
+	passwords *password.Policy
+	hashPool  *password.Pool
+	metrics   *metrics.Metrics
 }
 
-func NewUserService(repo repository.UserRepository, cost int) UserService {
+func NewUserService(repo repository.UserRepository, passwords *password.Policy, hashPool *password.Pool, metrics *metrics.Metrics) UserService {
 	return &userService{
-		repo: repo,
-		cost: cost,
+		repo:      repo,
+		passwords: passwords,
+		hashPool:  hashPool,
+		metrics:   metrics,
 	}
 }
 
-func (s *userService) Register(ctx context.Context, req *domain.RegistrationRequest) (*domain.RegistrationResponse, error) {
+func (s *userService) Register(ctx context.Context, req *domain.RegistrationRequest) (_ *domain.RegistrationResponse, err error) {
+	ctx, span := tracer.Start(ctx, "userService.Register")
+	defer func() { endSpan(span, err) }()
+
 	emailExists, err := s.repo.CheckEmailExists(ctx, req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check email: %w", err)
@@ -50,27 +57,36 @@ func (s *userService) Register(ctx context.Context, req *domain.RegistrationRequ
 		return nil, ErrUsernameAlreadyTaken
 	}
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), s.cost)
+	passwordHash, err := hashPassword(ctx, s.metrics, s.hashPool, s.passwords, req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	user := &domain.User{
-		FirstName:     req.FirstName,
-		LastName:      req.LastName,
-		Email:         req.Email,
-		PhoneNumber:   req.PhoneNumber,
-		StreetAddress: req.StreetAddress,
-		City:          req.City,
-		State:         req.State,
-		Country:       req.Country,
-		Username:      req.Username,
-		PasswordHash:  passwordHash,
-		AcceptTerms:   req.AcceptTerms,
-		Newsletter:    req.Newsletter,
+		FirstName:           req.FirstName,
+		LastName:            req.LastName,
+		Email:               req.Email,
+		PhoneNumber:         req.PhoneNumber,
+		StreetAddress:       req.StreetAddress,
+		City:                req.City,
+		State:               req.State,
+		Country:             req.Country,
+		Username:            req.Username,
+		PasswordHash:        []byte(passwordHash.Encoded),
+		PasswordPepperKeyID: passwordHash.PepperKeyID,
+		AcceptTerms:         req.AcceptTerms,
+		Newsletter:          req.Newsletter,
 	}
 
+	// The checks above can race with a concurrent registration, in that case
+	// the unique constraints reject the insert
 	if err := s.repo.CreateUser(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateEmail):
+			return nil, ErrEmailAlreadyRegistered
+		case errors.Is(err, repository.ErrDuplicateUsername):
+			return nil, ErrUsernameAlreadyTaken
+		}
 		return nil, fmt.Errorf("failed to create user in database: %w", err)
 	}
 
@@ -83,7 +99,10 @@ func (s *userService) Register(ctx context.Context, req *domain.RegistrationRequ
 	}, nil
 }
 
-func (s *userService) CheckUsernameAvailability(ctx context.Context, username string) (bool, error) {
+func (s *userService) CheckUsernameAvailability(ctx context.Context, username string) (_ bool, err error) {
+	ctx, span := tracer.Start(ctx, "userService.CheckUsernameAvailability")
+	defer func() { endSpan(span, err) }()
+
 	exists, err := s.repo.CheckUsernameExists(ctx, username)
 	if err != nil {
 		return false, fmt.Errorf("failed to check username: %w", err)
@@ -91,7 +110,10 @@ func (s *userService) CheckUsernameAvailability(ctx context.Context, username st
 	return !exists, nil
 }
 
-func (s *userService) CheckEmailAvailability(ctx context.Context, email string) (bool, error) {
+func (s *userService) CheckEmailAvailability(ctx context.Context, email string) (_ bool, err error) {
+	ctx, span := tracer.Start(ctx, "userService.CheckEmailAvailability")
+	defer func() { endSpan(span, err) }()
+
 	exists, err := s.repo.CheckEmailExists(ctx, email)
 	if err != nil {
 		return false, fmt.Errorf("failed to check email: %w", err)
@@ -0,0 +1,219 @@
+package strength
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"unicode"
+)
+
+// The lists are ranked by frequency, one lowercase word per line
+//
+//go:embed data/*.txt
+var dataFS embed.FS
+
+const dictionaryUserInputs = "user_inputs"
+
+type rankedDictionary struct {
+	name  string
+	ranks map[string]int
+	// maxLength bounds the substrings worth looking up
+	maxLength int
+}
+
+var dictionaries = sync.OnceValue(func() []rankedDictionary {
+	names := []string{"passwords", "english", "female_names", "male_names", "surnames"}
+
+	dicts := make([]rankedDictionary, 0, len(names))
+	for _, name := range names {
+		file, err := dataFS.Open("data/" + name + ".txt")
+		if err != nil {
+			panic(fmt.Sprintf("missing embedded dictionary %s: %v", name, err))
+		}
+
+		var words []string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			words = append(words, scanner.Text())
+		}
+		file.Close()
+
+		dicts = append(dicts, newRankedDictionary(name, words))
+	}
+	return dicts
+})
+
+func newRankedDictionary(name string, words []string) rankedDictionary {
+	dict := rankedDictionary{name: name, ranks: make(map[string]int, len(words))}
+	for i, word := range words {
+		if _, ok := dict.ranks[word]; ok {
+			continue
+		}
+		dict.ranks[word] = i + 1
+		dict.maxLength = max(dict.maxLength, len([]rune(word)))
+	}
+	return dict
+}
+
+// l33tTable lists the characters commonly substituted for a letter
+var l33tTable = map[rune][]rune{
+	'a': {'4', '@'},
+	'b': {'8'},
+	'c': {'(', '{', '[', '<'},
+	'e': {'3'},
+	'g': {'6', '9'},
+	'i': {'1', '!', '|'},
+	'l': {'1', '|', '7'},
+	'o': {'0'},
+	's': {'$', '5'},
+	't': {'+', '7'},
+	'x': {'%'},
+	'z': {'2'},
+}
+
+// l33tLetters maps a substituted character to the letters it may stand for
+var l33tLetters = func() map[rune][]rune {
+	letters := map[rune][]rune{}
+	for _, letter := range slices.Sorted(maps.Keys(l33tTable)) {
+		for _, sub := range l33tTable[letter] {
+			letters[sub] = append(letters[sub], letter)
+		}
+	}
+	return letters
+}()
+
+// maxL33tSubs bounds the substitution tables tried on a password, ambiguous
+// characters like 1 for i or l multiply them
+const maxL33tSubs = 64
+
+// dictionaryMatches finds every substring of the password that is a word of
+// a dictionary, case insensitively
+func (m *matcher) dictionaryMatches(password []rune) []*match {
+	lower := make([]rune, len(password))
+	for i, r := range password {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var matches []*match
+	for _, dict := range m.dictionaries() {
+		for i := range lower {
+			for j := i; j < len(lower) && j-i < dict.maxLength; j++ {
+				rank, ok := dict.ranks[string(lower[i:j+1])]
+				if !ok {
+					continue
+				}
+				matches = append(matches, &match{
+					pattern:    patternDictionary,
+					i:          i,
+					j:          j,
+					token:      string(password[i : j+1]),
+					dictionary: dict.name,
+					rank:       rank,
+				})
+			}
+		}
+	}
+	return matches
+}
+
+// reverseDictionaryMatches finds the dictionary words written backwards
+func (m *matcher) reverseDictionaryMatches(password []rune) []*match {
+	reversed := slices.Clone(password)
+	slices.Reverse(reversed)
+
+	matches := m.dictionaryMatches(reversed)
+	n := len(password)
+	for _, dm := range matches {
+		dm.i, dm.j = n-1-dm.j, n-1-dm.i
+		dm.token = string(password[dm.i : dm.j+1])
+		dm.reversed = true
+	}
+	return matches
+}
+
+// l33tMatches finds the dictionary words written with substitutions like
+// p@ssw0rd, trying every table of substitutions the password may use
+func (m *matcher) l33tMatches(password []rune) []*match {
+	var matches []*match
+	for _, sub := range l33tSubs(password) {
+		translated := make([]rune, len(password))
+		for i, r := range password {
+			if letter, ok := sub[r]; ok {
+				r = letter
+			}
+			translated[i] = r
+		}
+
+		for _, dm := range m.dictionaryMatches(translated) {
+			token := password[dm.i : dm.j+1]
+			// Single characters are matched better by brute force
+			if len(token) == 1 {
+				continue
+			}
+
+			used := map[rune]rune{}
+			for _, r := range token {
+				if letter, ok := sub[r]; ok {
+					used[r] = letter
+				}
+			}
+			if len(used) == 0 {
+				continue
+			}
+
+			dm.token = string(token)
+			dm.l33t = true
+			dm.sub = used
+			matches = append(matches, dm)
+		}
+	}
+	return matches
+}
+
+// l33tSubs returns the substitution tables of the password, one per
+// combination of letters its ambiguous characters may stand for
+func l33tSubs(password []rune) []map[rune]rune {
+	var chars []rune
+	for _, r := range password {
+		if _, ok := l33tLetters[r]; ok && !slices.Contains(chars, r) {
+			chars = append(chars, r)
+		}
+	}
+	if len(chars) == 0 {
+		return nil
+	}
+
+	subs := []map[rune]rune{{}}
+	for _, char := range chars {
+		var next []map[rune]rune
+		for _, sub := range subs {
+			for _, letter := range l33tLetters[char] {
+				if len(next) == maxL33tSubs {
+					break
+				}
+				extended := maps.Clone(sub)
+				extended[char] = letter
+				next = append(next, extended)
+			}
+		}
+		subs = next
+	}
+	return subs
+}
+
+// dictionaries returns the embedded dictionaries and the user inputs
+func (m *matcher) dictionaries() []rankedDictionary {
+	dicts := dictionaries()
+	if len(m.userInputs) == 0 {
+		return dicts
+	}
+
+	inputs := rankedDictionary{name: dictionaryUserInputs, ranks: m.userInputs}
+	for input := range m.userInputs {
+		inputs.maxLength = max(inputs.maxLength, len([]rune(input)))
+	}
+	return append(slices.Clip(dicts), inputs)
+}
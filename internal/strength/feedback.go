@@ -0,0 +1,112 @@
+package strength
+
+import (
+	"strings"
+	"unicode"
+)
+
+const suggestAnotherWord = "Add another word or two. Uncommon words are better."
+
+var defaultFeedback = Feedback{
+	Suggestions: []string{
+		"Use a few words, avoid common phrases.",
+		"No need for symbols, digits, or uppercase letters.",
+	},
+}
+
+// feedback explains a weak score from the longest match of the sequence,
+// the part of the password that weighs most on it
+func feedback(score int, sequence []*match) Feedback {
+	if len(sequence) == 0 {
+		return defaultFeedback
+	}
+	if score > ScoreSomewhatSecure {
+		return Feedback{}
+	}
+
+	longest := sequence[0]
+	for _, m := range sequence[1:] {
+		if len(m.token) > len(longest.token) {
+			longest = m
+		}
+	}
+
+	fb := matchFeedback(longest, len(sequence) == 1)
+	fb.Suggestions = append([]string{suggestAnotherWord}, fb.Suggestions...)
+	return fb
+}
+
+func matchFeedback(m *match, soleMatch bool) Feedback {
+	switch m.pattern {
+	case patternDictionary:
+		return dictionaryFeedback(m, soleMatch)
+	case patternSpatial:
+		warning := "Short keyboard patterns are easy to guess."
+		if m.turns == 1 {
+			warning = "Straight rows of keys are easy to guess."
+		}
+		return Feedback{
+			Warning:     warning,
+			Suggestions: []string{"Use a longer keyboard pattern with more turns."},
+		}
+	case patternRepeat:
+		warning := `Repeats like "abcabcabc" are only slightly harder to guess than "abc".`
+		if len([]rune(m.baseToken)) == 1 {
+			warning = `Repeats like "aaa" are easy to guess.`
+		}
+		return Feedback{
+			Warning:     warning,
+			Suggestions: []string{"Avoid repeated words and characters."},
+		}
+	case patternSequence:
+		return Feedback{
+			Warning:     "Sequences like abc or 6543 are easy to guess.",
+			Suggestions: []string{"Avoid sequences."},
+		}
+	}
+	return Feedback{}
+}
+
+func dictionaryFeedback(m *match, soleMatch bool) Feedback {
+	var fb Feedback
+	switch m.dictionary {
+	case "passwords":
+		switch {
+		case soleMatch && !m.l33t && !m.reversed && m.rank <= 10:
+			fb.Warning = "This is a top-10 common password."
+		case soleMatch && !m.l33t && !m.reversed && m.rank <= 100:
+			fb.Warning = "This is a top-100 common password."
+		case soleMatch && !m.l33t && !m.reversed:
+			fb.Warning = "This is a very common password."
+		case m.guesses <= 1e4:
+			fb.Warning = "This is similar to a commonly used password."
+		}
+	case "english":
+		if soleMatch {
+			fb.Warning = "A word by itself is easy to guess."
+		}
+	case "surnames", "male_names", "female_names":
+		fb.Warning = "Common names and surnames are easy to guess."
+		if soleMatch {
+			fb.Warning = "Names and surnames by themselves are easy to guess."
+		}
+	case dictionaryUserInputs:
+		fb.Warning = "Avoid your username, email address or name in the password."
+	}
+
+	runes := []rune(m.token)
+	lower := strings.ToLower(m.token)
+	switch {
+	case unicode.IsUpper(runes[0]) && strings.ToLower(string(runes[1:])) == string(runes[1:]):
+		fb.Suggestions = append(fb.Suggestions, "Capitalization doesn't help very much.")
+	case strings.ToUpper(m.token) == m.token && lower != m.token:
+		fb.Suggestions = append(fb.Suggestions, "All-uppercase is almost as easy to guess as all-lowercase.")
+	}
+	if m.reversed && len(runes) >= 4 {
+		fb.Suggestions = append(fb.Suggestions, "Reversed words aren't much harder to guess.")
+	}
+	if m.l33t {
+		fb.Suggestions = append(fb.Suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much.")
+	}
+	return fb
+}
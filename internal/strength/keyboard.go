@@ -0,0 +1,193 @@
+package strength
+
+import (
+	"math"
+	"strings"
+)
+
+// adjacencyGraph maps every key character to its neighbors, indexed by
+// direction so a change of direction counts as a turn of the walk
+type adjacencyGraph struct {
+	name string
+	// keys holds the neighbor keys of a character, empty at the edges
+	keys map[rune][]string
+	// keyOf is the key a character is on
+	keyOf map[rune]string
+	// shiftable layouts have the shifted character second on each key
+	shiftable bool
+
+	startingPositions float64
+	averageDegree     float64
+}
+
+// Layouts are drawn one key per token, slanted layouts shift each row right
+// by one character as on a physical keyboard
+var (
+	qwerty = newAdjacencyGraph("qwerty", true, true, []string{
+		"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+",
+		"    qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|",
+		"     aA sS dD fF gG hH jJ kK lL ;: '\"",
+		"      zZ xX cC vV bB nN mM ,< .> /?",
+	})
+	dvorak = newAdjacencyGraph("dvorak", true, true, []string{
+		"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) [{ ]}",
+		"    '\" ,< .> pP yY fF gG cC rR lL /? =+ \\|",
+		"     aA oO eE uU iI dD hH tT nN sS -_",
+		"      ;: qQ jJ kK xX bB mM wW vV zZ",
+	})
+	keypad = newAdjacencyGraph("keypad", false, false, []string{
+		"  / * -",
+		"7 8 9 +",
+		"4 5 6",
+		"1 2 3",
+		"  0 .",
+	})
+
+	adjacencyGraphs = []*adjacencyGraph{qwerty, dvorak, keypad}
+)
+
+func newAdjacencyGraph(name string, slanted, shiftable bool, rows []string) *adjacencyGraph {
+	type position struct{ x, y int }
+
+	tokenSize := len(strings.Fields(rows[0])[0])
+	// A key takes its characters and the space after them
+	xUnit := tokenSize + 1
+
+	positions := map[position]string{}
+	for y, row := range rows {
+		slant := 0
+		if slanted {
+			slant = y
+		}
+		offset := 0
+		for _, token := range strings.Fields(row) {
+			index := offset + strings.Index(row[offset:], token)
+			positions[position{(index - slant) / xUnit, y}] = token
+			offset = index + len(token)
+		}
+	}
+
+	neighbors := func(p position) []position {
+		x, y := p.x, p.y
+		if slanted {
+			return []position{{x - 1, y}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y + 1}}
+		}
+		return []position{{x - 1, y}, {x - 1, y - 1}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}, {x - 1, y + 1}}
+	}
+
+	graph := &adjacencyGraph{name: name, keys: map[rune][]string{}, keyOf: map[rune]string{}, shiftable: shiftable}
+	var degrees int
+	for p, token := range positions {
+		var adjacent []string
+		for _, n := range neighbors(p) {
+			adjacent = append(adjacent, positions[n])
+			if positions[n] != "" {
+				degrees += len(token)
+			}
+		}
+		for _, r := range token {
+			graph.keys[r] = adjacent
+			graph.keyOf[r] = token
+		}
+	}
+
+	graph.startingPositions = float64(len(graph.keys))
+	graph.averageDegree = float64(degrees) / float64(len(graph.keys))
+	return graph
+}
+
+// spatialMatches finds walks of at least 3 adjacent keys, e.g. qwerty or 7895
+func spatialMatches(password []rune) []*match {
+	var matches []*match
+	for _, graph := range adjacencyGraphs {
+		matches = append(matches, graph.walks(password)...)
+	}
+	return matches
+}
+
+func (g *adjacencyGraph) walks(password []rune) []*match {
+	var matches []*match
+	for i := 0; i < len(password)-1; {
+		j := i + 1
+		lastDirection, turns, shiftedCount := -1, 0, 0
+		if g.shiftable && g.isShifted(password[i]) {
+			shiftedCount = 1
+		}
+
+		for {
+			found := false
+			if j < len(password) {
+				for direction, key := range g.keys[password[j-1]] {
+					index := strings.IndexRune(key, password[j])
+					if index < 0 {
+						continue
+					}
+					found = true
+					if index == 1 {
+						shiftedCount++
+					}
+					if lastDirection != direction {
+						turns++
+						lastDirection = direction
+					}
+					break
+				}
+			}
+
+			if found {
+				j++
+				continue
+			}
+			if j-i > 2 {
+				matches = append(matches, &match{
+					pattern:      patternSpatial,
+					i:            i,
+					j:            j - 1,
+					token:        string(password[i:j]),
+					graph:        g,
+					turns:        turns,
+					shiftedCount: shiftedCount,
+				})
+			}
+			i = j
+			break
+		}
+	}
+	return matches
+}
+
+// isShifted reports a character typed with shift, the second of its key
+func (g *adjacencyGraph) isShifted(r rune) bool {
+	key := []rune(g.keyOf[r])
+	return len(key) == 2 && key[1] == r
+}
+
+// spatialGuesses counts the walks of the same length with at most as many
+// turns, from any starting key, and the ways to shift its keys
+func spatialGuesses(m *match) float64 {
+	s := m.graph.startingPositions
+	d := m.graph.averageDegree
+	length := len([]rune(m.token))
+
+	var guesses float64
+	for i := 2; i <= length; i++ {
+		possibleTurns := min(m.turns, i-1)
+		for j := 1; j <= possibleTurns; j++ {
+			guesses += nCk(i-1, j-1) * s * math.Pow(d, float64(j))
+		}
+	}
+
+	if m.shiftedCount > 0 {
+		shifted, unshifted := m.shiftedCount, length-m.shiftedCount
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			var variations float64
+			for i := 1; i <= min(shifted, unshifted); i++ {
+				variations += nCk(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+	return guesses
+}
@@ -0,0 +1,126 @@
+package strength
+
+import (
+	"slices"
+)
+
+// maxSequenceDelta bounds the step between characters of a sequence, e.g.
+// 2468 or aceg, larger steps are rarely typed on purpose
+const maxSequenceDelta = 5
+
+// matcher finds every recognized pattern of a password, matches may overlap
+type matcher struct {
+	// userInputs ranks the inputs of the user as a dictionary
+	userInputs map[string]int
+}
+
+func (m *matcher) omnimatch(password []rune) []*match {
+	var matches []*match
+	matches = append(matches, m.dictionaryMatches(password)...)
+	matches = append(matches, m.reverseDictionaryMatches(password)...)
+	matches = append(matches, m.l33tMatches(password)...)
+	matches = append(matches, spatialMatches(password)...)
+	matches = append(matches, m.repeatMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+
+	slices.SortFunc(matches, func(a, b *match) int {
+		if a.i != b.i {
+			return a.i - b.i
+		}
+		return a.j - b.j
+	})
+	return matches
+}
+
+// repeatMatches finds repeated runs like aaa or abcabc. At each position the
+// longest run wins, with the shortest base repeated to build it, and the
+// base is estimated on its own so a repeated word costs more than aaa
+func (m *matcher) repeatMatches(password []rune) []*match {
+	var matches []*match
+	for i := 0; i < len(password); {
+		baseLength, count := 0, 0
+		for b := 1; b <= (len(password)-i)/2; b++ {
+			c := repeatCount(password[i:], b)
+			if c >= 2 && b*c > baseLength*count {
+				baseLength, count = b, c
+			}
+		}
+		if count == 0 {
+			i++
+			continue
+		}
+
+		base := password[i : i+baseLength]
+		_, baseGuesses := mostGuessableSequence(base, m.omnimatch(base))
+		j := i + baseLength*count - 1
+		matches = append(matches, &match{
+			pattern:     patternRepeat,
+			i:           i,
+			j:           j,
+			token:       string(password[i : j+1]),
+			baseToken:   string(base),
+			baseGuesses: baseGuesses,
+			repeatCount: count,
+		})
+		i = j + 1
+	}
+	return matches
+}
+
+// repeatCount counts the consecutive repetitions of the first baseLength
+// runes at the start of the password
+func repeatCount(password []rune, baseLength int) int {
+	base := password[:baseLength]
+	count := 1
+	for k := baseLength; k+baseLength <= len(password); k += baseLength {
+		if !slices.Equal(password[k:k+baseLength], base) {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// sequenceMatches finds runs of characters with a constant step, e.g. abcd,
+// 9753 or zyx. Runs of 2 characters only count with a step of 1
+func sequenceMatches(password []rune) []*match {
+	if len(password) < 2 {
+		return nil
+	}
+
+	var matches []*match
+	update := func(i, j, delta int) {
+		if j-i < 2 && abs(delta) != 1 {
+			return
+		}
+		if delta == 0 || abs(delta) > maxSequenceDelta {
+			return
+		}
+		matches = append(matches, &match{
+			pattern:   patternSequence,
+			i:         i,
+			j:         j,
+			token:     string(password[i : j+1]),
+			ascending: delta > 0,
+		})
+	}
+
+	i, lastDelta := 0, int(password[1]-password[0])
+	for k := 2; k < len(password); k++ {
+		delta := int(password[k] - password[k-1])
+		if delta == lastDelta {
+			continue
+		}
+		update(i, k-1, lastDelta)
+		i, lastDelta = k-1, delta
+	}
+	update(i, len(password)-1, lastDelta)
+	return matches
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
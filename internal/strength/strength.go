@@ -0,0 +1,398 @@
+// Package strength estimates password strength in the manner of zxcvbn: the
+// password is split into the sequence of recognized patterns (dictionary
+// words, keyboard walks, repeats, sequences) and brute forced gaps that needs
+// the fewest guesses, and that number of guesses is mapped to a score
+package strength
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Score thresholds, a password scoring less than 3 falls to an offline
+// attack on a slow hash in a reasonable time
+const (
+	ScoreTooGuessable   = 0
+	ScoreVeryGuessable  = 1
+	ScoreSomewhatSecure = 2
+	ScoreSafelySecure   = 3
+	ScoreVerySecure     = 4
+)
+
+const (
+	// maxLength bounds the estimated part of the password, matching is
+	// quadratic in the length and longer passwords are rejected anyway
+	maxLength = 128
+	// minUserInputLength skips user inputs that would match everywhere
+	minUserInputLength = 3
+
+	bruteforceCardinality           = 10
+	minGuessesBeforeGrowingSequence = 10000
+	minSubmatchGuessesSingleChar    = 10
+	minSubmatchGuessesMultiChar     = 50
+)
+
+// Result is the estimate of a password
+type Result struct {
+	// Score goes from 0, guessable in under 10^3 guesses, to 4, more than 10^10
+	Score    int
+	Guesses  float64
+	Feedback Feedback
+}
+
+// Feedback explains a weak score, it is empty for strong passwords
+type Feedback struct {
+	Warning     string
+	Suggestions []string
+}
+
+// match is a part of the password recognized by a matcher, i and j are the
+// rune indexes of its first and last character
+type match struct {
+	pattern string
+	i, j    int
+	token   string
+	// guesses is computed once by estimateGuesses
+	guesses float64
+
+	dictionary string
+	rank       int
+	reversed   bool
+	l33t       bool
+	// sub maps the substituted characters of the token to their letter
+	sub map[rune]rune
+
+	graph        *adjacencyGraph
+	turns        int
+	shiftedCount int
+
+	baseToken   string
+	baseGuesses float64
+	repeatCount int
+
+	ascending bool
+}
+
+const (
+	patternDictionary = "dictionary"
+	patternSpatial    = "spatial"
+	patternRepeat     = "repeat"
+	patternSequence   = "sequence"
+	patternBruteforce = "bruteforce"
+)
+
+// Estimate scores the password. userInputs such as the username, the email
+// or names of the user are matched as the most likely dictionary words, so a
+// password built on them scores low
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	if len(runes) > maxLength {
+		runes = runes[:maxLength]
+	}
+
+	m := &matcher{userInputs: rankUserInputs(userInputs)}
+	sequence, guesses := mostGuessableSequence(runes, m.omnimatch(runes))
+	score := guessesToScore(guesses)
+
+	return Result{
+		Score:    score,
+		Guesses:  guesses,
+		Feedback: feedback(score, sequence),
+	}
+}
+
+func rankUserInputs(inputs []string) map[string]int {
+	ranks := make(map[string]int, len(inputs))
+	for _, input := range inputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if len([]rune(input)) < minUserInputLength {
+			continue
+		}
+		if _, ok := ranks[input]; !ok {
+			ranks[input] = len(ranks) + 1
+		}
+	}
+	return ranks
+}
+
+func guessesToScore(guesses float64) int {
+	// The margin keeps exactly 10^n guesses, e.g. of a brute forced
+	// password, in the lower score
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return ScoreTooGuessable
+	case guesses < 1e6+delta:
+		return ScoreVeryGuessable
+	case guesses < 1e8+delta:
+		return ScoreSomewhatSecure
+	case guesses < 1e10+delta:
+		return ScoreSafelySecure
+	default:
+		return ScoreVerySecure
+	}
+}
+
+// mostGuessableSequence finds the non overlapping sequence of matches and
+// brute forced gaps covering the password with the fewest guesses. A
+// sequence of l matches costs l! times the product of their guesses, the
+// attacker doesn't know the order of the patterns, plus a growth term that
+// favors fewer, longer matches
+func mostGuessableSequence(password []rune, matches []*match) ([]*match, float64) {
+	n := len(password)
+	if n == 0 {
+		return nil, 1
+	}
+
+	matchesByJ := make([][]*match, n)
+	for _, m := range matches {
+		matchesByJ[m.j] = append(matchesByJ[m.j], m)
+	}
+	for _, ms := range matchesByJ {
+		slices.SortFunc(ms, func(a, b *match) int { return a.i - b.i })
+	}
+
+	// optimal*[k][l] describe the best sequence of l matches ending at k
+	optimalM := make([]map[int]*match, n)
+	optimalPi := make([]map[int]float64, n)
+	optimalG := make([]map[int]float64, n)
+	for k := range n {
+		optimalM[k] = map[int]*match{}
+		optimalPi[k] = map[int]float64{}
+		optimalG[k] = map[int]float64{}
+	}
+
+	update := func(m *match, l int) {
+		k := m.j
+		pi := estimateGuesses(m, n)
+		if l > 1 {
+			pi *= optimalPi[m.i-1][l-1]
+		}
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+
+		for competingL, competingG := range optimalG[k] {
+			if competingL <= l && competingG <= g {
+				return
+			}
+		}
+		optimalM[k][l] = m
+		optimalPi[k][l] = pi
+		optimalG[k][l] = g
+	}
+
+	bruteforceUpdate := func(k int) {
+		update(bruteforceMatch(password, 0, k), 1)
+		for i := 1; i <= k; i++ {
+			m := bruteforceMatch(password, i, k)
+			for _, l := range sortedKeys(optimalM[i-1]) {
+				// Adjacent brute force matches are never better than one
+				// spanning both
+				if optimalM[i-1][l].pattern == patternBruteforce {
+					continue
+				}
+				update(m, l+1)
+			}
+		}
+	}
+
+	for k := range n {
+		for _, m := range matchesByJ[k] {
+			if m.i > 0 {
+				for _, l := range sortedKeys(optimalM[m.i-1]) {
+					update(m, l+1)
+				}
+			} else {
+				update(m, 1)
+			}
+		}
+		bruteforceUpdate(k)
+	}
+
+	k := n - 1
+	bestL, bestG := 0, math.Inf(1)
+	for _, l := range sortedKeys(optimalG[k]) {
+		if g := optimalG[k][l]; g < bestG {
+			bestL, bestG = l, g
+		}
+	}
+
+	sequence := make([]*match, 0, bestL)
+	for l := bestL; k >= 0; l-- {
+		m := optimalM[k][l]
+		sequence = append(sequence, m)
+		k = m.i - 1
+	}
+	slices.Reverse(sequence)
+
+	return sequence, bestG
+}
+
+func bruteforceMatch(password []rune, i, j int) *match {
+	return &match{pattern: patternBruteforce, i: i, j: j, token: string(password[i : j+1])}
+}
+
+// estimateGuesses computes the guesses of the match once. Matches shorter
+// than the password need a minimum of guesses, otherwise a single character
+// dictionary word would be cheaper than brute forcing it
+func estimateGuesses(m *match, passwordLength int) float64 {
+	if m.guesses != 0 {
+		return m.guesses
+	}
+
+	tokenLength := len([]rune(m.token))
+	minGuesses := 1.0
+	if tokenLength < passwordLength {
+		minGuesses = minSubmatchGuessesMultiChar
+		if tokenLength == 1 {
+			minGuesses = minSubmatchGuessesSingleChar
+		}
+	}
+
+	var guesses float64
+	switch m.pattern {
+	case patternBruteforce:
+		guesses = bruteforceGuesses(tokenLength)
+	case patternDictionary:
+		guesses = dictionaryGuesses(m)
+	case patternSpatial:
+		guesses = spatialGuesses(m)
+	case patternRepeat:
+		guesses = m.baseGuesses * float64(m.repeatCount)
+	case patternSequence:
+		guesses = sequenceGuesses(m)
+	}
+
+	m.guesses = max(guesses, minGuesses)
+	return m.guesses
+}
+
+func bruteforceGuesses(length int) float64 {
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+	if math.IsInf(guesses, 1) {
+		guesses = math.MaxFloat64
+	}
+	// Brute forced submatches must cost more than any other match of the
+	// same length, so recognized patterns are preferred
+	if length == 1 {
+		return max(guesses, minSubmatchGuessesSingleChar+1)
+	}
+	return max(guesses, minSubmatchGuessesMultiChar+1)
+}
+
+func dictionaryGuesses(m *match) float64 {
+	guesses := float64(m.rank) * uppercaseVariations(m.token) * l33tVariations(m)
+	if m.reversed {
+		guesses *= 2
+	}
+	return guesses
+}
+
+// uppercaseVariations counts the capitalizations an attacker tries before
+// this one, a capitalized first or last letter or all caps are the usual ones
+func uppercaseVariations(token string) float64 {
+	runes := []rune(token)
+	var upper, lower int
+	for _, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+	startUpper := unicode.IsUpper(runes[0]) && upper == 1
+	endUpper := unicode.IsUpper(runes[len(runes)-1]) && upper == 1
+	if startUpper || endUpper || lower == 0 {
+		return 2
+	}
+
+	var variations float64
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += nCk(upper+lower, i)
+	}
+	return variations
+}
+
+// l33tVariations counts the ways to substitute the letters of the token the
+// way it is, with or without each substitution for every occurrence
+func l33tVariations(m *match) float64 {
+	if !m.l33t {
+		return 1
+	}
+
+	lower := []rune(strings.ToLower(m.token))
+	variations := 1.0
+	for subbed, letter := range m.sub {
+		var s, u int
+		for _, r := range lower {
+			switch r {
+			case subbed:
+				s++
+			case letter:
+				u++
+			}
+		}
+
+		if s == 0 || u == 0 {
+			variations *= 2
+			continue
+		}
+		var possibilities float64
+		for i := 1; i <= min(u, s); i++ {
+			possibilities += nCk(u+s, i)
+		}
+		variations *= possibilities
+	}
+	return variations
+}
+
+func sequenceGuesses(m *match) float64 {
+	var base float64
+	switch first := []rune(m.token)[0]; {
+	// Obvious starting points
+	case strings.ContainsRune("aAzZ019", first):
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+	if !m.ascending {
+		base *= 2
+	}
+	return base * float64(len([]rune(m.token)))
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+func nCk(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r = r * float64(n-k+d) / float64(d)
+	}
+	return r
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package strength
+
+import "testing"
+
+func TestEstimateScore(t *testing.T) {
+	tests := []struct {
+		password string
+		minScore int
+		maxScore int
+	}{
+		{"correct horse battery staple", ScoreSafelySecure, ScoreVerySecure},
+		{"Password123!", ScoreTooGuessable, ScoreSomewhatSecure},
+		{"password", ScoreTooGuessable, ScoreTooGuessable},
+		{"qwertyuiop", ScoreTooGuessable, ScoreVeryGuessable},
+		{"abcdefgh", ScoreTooGuessable, ScoreVeryGuessable},
+		{"aaaaaaaaaaaa", ScoreTooGuessable, ScoreVeryGuessable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := Estimate(tt.password).Score; got < tt.minScore || got > tt.maxScore {
+				t.Errorf("Estimate(%q).Score = %d, want %d to %d", tt.password, got, tt.minScore, tt.maxScore)
+			}
+		})
+	}
+}
+
+func TestEstimatePenalizesUserInputs(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+	}{
+		{"username", "kwazimodoflux1987", []string{"kwazimodoflux"}},
+		{"username in another case", "KwazimodoFlux1987", []string{"kwazimodoflux"}},
+		{"email local part", "velvetgrotto1987", []string{"velvetgrotto"}},
+		{"words of the email local part", "grotto19871987", []string{"velvet.grotto", "velvet", "grotto"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			without := Estimate(tt.password)
+			if without.Score < ScoreSafelySecure {
+				t.Fatalf("Estimate(%q).Score = %d without user inputs, want at least %d", tt.password, without.Score, ScoreSafelySecure)
+			}
+			with := Estimate(tt.password, tt.userInputs...)
+			if with.Score >= ScoreSafelySecure || with.Guesses >= without.Guesses {
+				t.Errorf("Estimate(%q, %q) = score %d, %g guesses, want under %d and %g guesses", tt.password, tt.userInputs, with.Score, with.Guesses, ScoreSafelySecure, without.Guesses)
+			}
+		})
+	}
+}
+
+func TestEstimateIgnoresShortUserInputs(t *testing.T) {
+	password := "correct horse battery staple"
+	if got, want := Estimate(password, "co", "", " "), Estimate(password); got.Guesses != want.Guesses {
+		t.Errorf("Estimate() with short user inputs = %g guesses, want %g", got.Guesses, want.Guesses)
+	}
+}
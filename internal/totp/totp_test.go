@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 appendix B test vectors
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8 digit codes, the 6 digit codes are their last 6 digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		got, err := Code(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d) error = %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		at := time.Unix(tt.unix, 0)
+		step, ok := Validate(rfc6238Secret, tt.code, at, 0)
+		if !ok || step != Step(at) {
+			t.Errorf("Validate(%d) = %d, %v, want %d, true", tt.unix, step, ok, Step(at))
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	// 1111111111 is in step 37037037, 1111111109 in the step before
+	at := time.Unix(1111111111, 0)
+	previous := Step(at) - 1
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "same step", at: time.Unix(1111111109, 0), skew: 0, wantStep: previous, wantOK: true},
+		{name: "next step without skew", at: at, skew: 0},
+		{name: "next step with skew", at: at, skew: 1, wantStep: previous, wantOK: true},
+		{name: "two steps later with skew", at: at.Add(Period), skew: 1},
+		{name: "step before with skew", at: time.Unix(1111111109, 0).Add(-Period), skew: 1, wantStep: previous, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfc6238Secret, "081804", tt.at, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfc6238Secret, "287083"},
+		{"short code", rfc6238Secret, "28708"},
+		{"8 digit code", rfc6238Secret, "94287082"},
+		{"invalid secret", "not base32!", "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, at, 1); ok {
+				t.Errorf("Validate(%q, %q) = true, want false", tt.secret, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package validation
+
+import (
+	"maps"
+	"multistep-registration/internal/domain"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema 2020-12 needed to describe the API types
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Const       any                `json:"const,omitempty"`
+	AnyOf       []*Schema          `json:"anyOf,omitempty"`
+	OneOf       []*Schema          `json:"oneOf,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+
+	// Matches names the property this one must be equal to, JSON Schema has
+	// no keyword for it so it is published as an annotation
+	Matches string `json:"x-matches,omitempty"`
+}
+
+// fieldPatterns are the patterns the chain validators enforce on top of binding rules
+var fieldPatterns = map[string]string{
+	"email":       EmailPattern,
+	"username":    UsernamePattern,
+	"phoneNumber": PhonePattern,
+}
+
+// passwordClassPattern is the pattern of the password class rules, the
+// strength estimate has no pattern and is enforced by the server only
+var passwordClassPattern = `^(?=.*[A-Z])(?=.*[a-z])(?=.*[0-9])(?=.*[` +
+	strings.ReplaceAll(regexp.QuoteMeta(PasswordSpecialChars), "-", `\-`) + `]).*$`
+
+var (
+	registrationSchema = sync.OnceValue(func() *Schema {
+		return newRegistrationSchema(false)
+	})
+	registrationSchemaWithClassRules = sync.OnceValue(func() *Schema {
+		return newRegistrationSchema(true)
+	})
+)
+
+func newRegistrationSchema(classRules bool) *Schema {
+	schema := buildObjectSchema(reflect.TypeOf(domain.RegistrationRequest{}))
+	schema.Schema = JSONSchemaDialect
+	schema.Title = "RegistrationRequest"
+	schema.Description = "Registration payload accepted by POST /api/v1/register"
+	if classRules {
+		schema.Properties["password"].Pattern = passwordClassPattern
+	}
+	return schema
+}
+
+// RegistrationSchema describes domain.RegistrationRequest from its binding
+// tags and the patterns used by the validators of the registration chain. The
+// schema has no $id, the route publishing it sets one
+func RegistrationSchema(policy PasswordPolicy) *Schema {
+	schema := registrationSchema()
+	if policy.ClassRules {
+		schema = registrationSchemaWithClassRules()
+	}
+	if maxLength := policy.PasswordMaxLength(); maxLength != MaxPasswordLength {
+		schema = WithPasswordMaxLength(schema, maxLength)
+	}
+	return schema
+}
+
+// WithPasswordMaxLength copies a request schema with a lower limit on its
+// password fields, the cached schemas are left untouched
+func WithPasswordMaxLength(schema *Schema, maxLength int) *Schema {
+	copied := *schema
+	copied.Properties = maps.Clone(schema.Properties)
+	for _, name := range []string{"password", "confirmPassword"} {
+		if property, ok := copied.Properties[name]; ok {
+			limited := *property
+			limited.MaxLength = &maxLength
+			copied.Properties[name] = &limited
+		}
+	}
+	return &copied
+}
+
+func buildObjectSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema, t.NumField()),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property, required, optional := FieldSchema(field)
+		if pattern, ok := fieldPatterns[name]; ok {
+			property.Pattern = pattern
+		}
+
+		// Optional fields accept an empty value in place of a valid one
+		if optional {
+			property = &Schema{
+				Type:  property.Type,
+				AnyOf: []*Schema{{Const: ""}, property},
+			}
+			property.AnyOf[1].Type = ""
+		}
+
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+// FieldSchema translates the binding tag of a field into schema keywords.
+// Optional reports an omitempty rule, the field then also accepts its zero value
+func FieldSchema(field reflect.StructField) (schema *Schema, required bool, optional bool) {
+	schema = &Schema{Type: jsonType(field.Type)}
+
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+
+		switch tag {
+		case "required":
+			required = true
+		case "omitempty":
+			optional = true
+		case "min":
+			if n, err := strconv.Atoi(param); err == nil {
+				schema.MinLength = &n
+			}
+		case "max":
+			if n, err := strconv.Atoi(param); err == nil {
+				schema.MaxLength = &n
+			}
+		case "email":
+			schema.Format = "email"
+		case "alphanum":
+			schema.Pattern = "^[a-zA-Z0-9]+$"
+		case "eq":
+			if b, err := strconv.ParseBool(param); err == nil && schema.Type == "boolean" {
+				schema.Const = b
+			}
+		case "eqfield":
+			schema.Matches = jsonFieldName(param)
+		}
+	}
+
+	return schema, required, optional
+}
+
+func jsonType(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "integer"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multistep-registration/internal/domain"
+)
+
+func TestRegistrationSchemaMatchesBindingTags(t *testing.T) {
+	schema := RegistrationSchema(DefaultPasswordPolicy)
+	// The validators enforce these patterns on top of the binding tags
+	patterns := map[string]string{
+		"email":       emailRegex.String(),
+		"username":    usernameRegex.String(),
+		"phoneNumber": phoneRegex.String(),
+	}
+
+	requestType := reflect.TypeOf(domain.RegistrationRequest{})
+	for i := 0; i < requestType.NumField(); i++ {
+		field := requestType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		t.Run(name, func(t *testing.T) {
+			property, ok := schema.Properties[name]
+			if !ok {
+				t.Fatal("missing from the schema")
+			}
+			// Optional fields wrap their rules in anyOf with the empty value
+			if len(property.AnyOf) == 2 {
+				property = property.AnyOf[1]
+			}
+
+			var wantMin, wantMax *int
+			var wantRequired bool
+			for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+				tag, param, _ := strings.Cut(rule, "=")
+				switch tag {
+				case "required":
+					wantRequired = true
+				case "min", "max":
+					n, err := strconv.Atoi(param)
+					if err != nil {
+						t.Fatalf("binding %s: %v", rule, err)
+					}
+					if tag == "min" {
+						wantMin = &n
+					} else {
+						wantMax = &n
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(property.MinLength, wantMin) {
+				t.Errorf("minLength = %v, want %v", deref(property.MinLength), deref(wantMin))
+			}
+			if !reflect.DeepEqual(property.MaxLength, wantMax) {
+				t.Errorf("maxLength = %v, want %v", deref(property.MaxLength), deref(wantMax))
+			}
+			if required := slices.Contains(schema.Required, name); required != wantRequired {
+				t.Errorf("required = %v, want %v", required, wantRequired)
+			}
+			if pattern, ok := patterns[name]; ok && property.Pattern != pattern {
+				t.Errorf("pattern = %q, want the validator's %q", property.Pattern, pattern)
+			}
+		})
+	}
+}
+
+func deref(n *int) any {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
@@ -2,15 +2,82 @@ package validation
 
 import (
 	"multistep-registration/internal/constants"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/problem"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Validator func(c *gin.Context) []Error
 
+var tracer = otel.Tracer("multistep-registration/internal/validation")
+
+// Mode defines how the chain reacts to a failing validator
+type Mode int
+
+const (
+	// FailFast stops the chain at the first validator that returns errors
+	FailFast Mode = iota
+	// CollectAll runs every validator and returns all errors together. The
+	// first validator binds the request, when it fails the chain still stops
+	CollectAll
+)
+
+type ChainOption func(*Chain)
+
+// WithMode sets the chain mode, chains are FailFast by default
+func WithMode(mode Mode) ChainOption {
+	return func(vc *Chain) {
+		vc.mode = mode
+	}
+}
+
+// WithRecorder reports every validation error to the recorder
+func WithRecorder(recorder FailureRecorder) ChainOption {
+	return func(vc *Chain) {
+		vc.recorder = recorder
+	}
+}
+
+// WithBreachChecker rejects passwords of known breaches in the chains that
+// validate a new password
+func WithBreachChecker(checker BreachChecker) ChainOption {
+	return func(vc *Chain) {
+		vc.breaches = checker
+	}
+}
+
+// WithPasswordPolicy sets the rules of new passwords, chains use
+// DefaultPasswordPolicy otherwise
+func WithPasswordPolicy(policy PasswordPolicy) ChainOption {
+	return func(vc *Chain) {
+		vc.passwords = policy
+	}
+}
+
+// FailureRecorder is notified of validation errors, e.g. to count them in metrics
+type FailureRecorder interface {
+	ValidationFailed(field, validator string)
+}
+
 type Chain struct {
-	validators []Validator
+	validators []namedValidator
+	mode       Mode
+	recorder   FailureRecorder
+	breaches   BreachChecker
+	passwords  PasswordPolicy
+}
+
+type namedValidator struct {
+	name     string
+	validate Validator
 }
 
 type Error struct {
@@ -18,19 +85,30 @@ type Error struct {
 	Message string `json:"message"`
 }
 
-func NewValidationChain() *Chain {
-	return &Chain{
-		validators: []Validator{},
+func NewValidationChain(opts ...ChainOption) *Chain {
+	chain := &Chain{
+		validators: []namedValidator{},
+		mode:       FailFast,
+		passwords:  DefaultPasswordPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(chain)
 	}
+
+	return chain
 }
 
-func CreateDefaultRegistrationChain() *Chain {
-	chain := NewValidationChain()
+func CreateDefaultRegistrationChain(opts ...ChainOption) *Chain {
+	chain := NewValidationChain(opts...)
 
 	// RequiredFieldsValidator is setting request in context, the order matters
 	chain.Add(RequiredFieldsValidator())
 	chain.Add(EmailFormatValidator())
-	chain.Add(PasswordStrengthValidator())
+	chain.Add(PasswordStrengthValidator(chain.passwords))
+	if chain.breaches != nil {
+		chain.Add(BreachedPasswordValidator(chain.breaches))
+	}
 	chain.Add(PasswordMatchValidator())
 	chain.Add(UsernameFormatValidator())
 	chain.Add(TermsAcceptanceValidator())
@@ -39,18 +117,98 @@ func CreateDefaultRegistrationChain() *Chain {
 	return chain
 }
 
+func CreatePasswordResetChain(opts ...ChainOption) *Chain {
+	chain := NewValidationChain(opts...)
+
+	// PasswordResetFieldsValidator is setting request in context, the order matters
+	chain.Add(PasswordResetFieldsValidator())
+	chain.Add(ResetPasswordStrengthValidator(chain.passwords))
+	if chain.breaches != nil {
+		chain.Add(ResetBreachedPasswordValidator(chain.breaches))
+	}
+
+	return chain
+}
+
+// CreateStepValidationChains builds a chain per form step from the same
+// validators as the default registration chain. Fields of other steps are
+// bound too, the account setup step needs the names and email the password
+// is checked against
+func CreateStepValidationChains(opts ...ChainOption) map[int]*Chain {
+	personalInfo := NewValidationChain(opts...)
+	personalInfo.Add(StepFieldsValidator("FirstName", "LastName", "Email", "PhoneNumber"))
+	personalInfo.Add(EmailFormatValidator())
+	personalInfo.Add(PhoneNumberValidator())
+
+	addressDetails := NewValidationChain(opts...)
+	addressDetails.Add(StepFieldsValidator("StreetAddress", "City", "State", "Country"))
+
+	accountSetup := NewValidationChain(opts...)
+	accountSetup.Add(StepFieldsValidator("Username", "Password", "ConfirmPassword", "AcceptTerms", "Newsletter"))
+	accountSetup.Add(PasswordStrengthValidator(accountSetup.passwords))
+	if accountSetup.breaches != nil {
+		accountSetup.Add(BreachedPasswordValidator(accountSetup.breaches))
+	}
+	accountSetup.Add(PasswordMatchValidator())
+	accountSetup.Add(UsernameFormatValidator())
+	accountSetup.Add(TermsAcceptanceValidator())
+
+	return map[int]*Chain{
+		domain.StepPersonalInfo:   personalInfo,
+		domain.StepAddressDetails: addressDetails,
+		domain.StepAccountSetup:   accountSetup,
+	}
+}
+
+// StepMiddleware picks the chain by the :step route param and validates the request with it
+func StepMiddleware(chains map[int]*Chain) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		step, err := strconv.Atoi(c.Param("step"))
+		chain, ok := chains[step]
+		if err != nil || !ok {
+			abortWithErrors(c, []Error{{
+				Field:   "step",
+				Message: "Unknown registration step",
+			}})
+			return
+		}
+
+		chain.Middleware()(c)
+	}
+}
+
 func (vc *Chain) Add(validator Validator) {
-	vc.validators = append(vc.validators, validator)
+	vc.validators = append(vc.validators, namedValidator{
+		name:     validatorName(validator),
+		validate: validator,
+	})
 }
 
+// Validate runs the validators in order and returns their errors, all of them
+// or those of the first failing validator depending on the mode
 func (vc *Chain) Validate(c *gin.Context) []Error {
 	var allErrors []Error
 
-	for _, validator := range vc.validators {
-		errors := validator(c)
-		if len(errors) > 0 {
-			allErrors = append(allErrors, errors...)
-			// If first validator fails there is no reason to run next one
+	for i, validator := range vc.validators {
+		_, span := tracer.Start(c.Request.Context(), "validation."+validator.name)
+		errors := validator.validate(c)
+		span.SetAttributes(attribute.Int("validation.errors", len(errors)))
+		span.End()
+
+		if len(errors) == 0 {
+			continue
+		}
+
+		if vc.recorder != nil {
+			for _, err := range errors {
+				vc.recorder.ValidationFailed(err.Field, validator.name)
+			}
+		}
+
+		allErrors = append(allErrors, errors...)
+		// The first validator binds the request the others rely on, so its
+		// failure always stops the chain
+		if vc.mode == FailFast || i == 0 {
 			break
 		}
 	}
@@ -63,14 +221,37 @@ func (vc *Chain) Middleware() gin.HandlerFunc {
 		errors := vc.Validate(c)
 
 		if len(errors) > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":   constants.CodeValidationError,
-				"errors": errors,
-			})
-			c.Abort()
+			abortWithErrors(c, errors)
 			return
 		}
 
 		c.Next()
 	}
 }
+
+func abortWithErrors(c *gin.Context, errors []Error) {
+	fieldErrors := make([]problem.FieldError, len(errors))
+	for i, err := range errors {
+		fieldErrors[i] = problem.FieldError{Field: err.Field, Message: err.Message}
+	}
+
+	problem.Abort(c, problem.New(http.StatusBadRequest, constants.CodeValidationError,
+		"One or more fields are invalid").WithErrors(fieldErrors))
+}
+
+// validatorName derives the name from the function that built the validator,
+// e.g. EmailFormatValidator for the closure it returns
+func validatorName(validator Validator) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(validator).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+
+	name := fn.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return name
+	}
+	return parts[1]
+}
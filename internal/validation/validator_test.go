@@ -0,0 +1,214 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"multistep-registration/internal/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	personalInfoBody = `"firstName":"Ada","lastName":"Lovelace","email":"zephyrinequartz@example.com"`
+	addressBody      = `"streetAddress":"12 St James's Square","city":"London","state":"London","country":"United Kingdom"`
+	// The password is strong on its own but built on the email local part
+	accountBody = `"username":"adalovelace","password":"zephyrinequartz1987","confirmPassword":"zephyrinequartz1987","acceptTerms":true`
+)
+
+// serveValidation posts body through the handlers and returns the status and
+// the fields of the validation errors
+func serveValidation(t *testing.T, path, route string, body string, handlers ...gin.HandlerFunc) (int, []string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.POST(route, append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusOK {
+		return rec.Code, nil
+	}
+	var details problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+		t.Fatalf("response is not a problem: %s", rec.Body)
+	}
+	fields := make([]string, len(details.Errors))
+	for i, err := range details.Errors {
+		fields[i] = err.Field
+	}
+	return rec.Code, fields
+}
+
+func TestStepMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		step       string
+		body       string
+		wantStatus int
+		wantFields []string
+	}{
+		{
+			name:       "unknown step",
+			step:       "4",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"step"},
+		},
+		{
+			name:       "step is not a number",
+			step:       "last",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"step"},
+		},
+		{
+			name:       "only the fields of the step",
+			step:       "1",
+			body:       `{` + personalInfoBody + `}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid fields of the step",
+			step:       "1",
+			body:       `{"firstName":"Ada","lastName":"Lovelace","email":"ada"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"email"},
+		},
+		{
+			name:       "missing fields of the step",
+			step:       "2",
+			body:       `{"streetAddress":"12 St James's Square","country":"United Kingdom"}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"city", "state"},
+		},
+		{
+			name:       "not JSON",
+			step:       "2",
+			body:       `{"city":`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"body"},
+		},
+		{
+			name:       "password checked against the earlier steps",
+			step:       "3",
+			body:       `{` + personalInfoBody + `,` + addressBody + `,` + accountBody + `}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []string{"password"},
+		},
+	}
+
+	chains := CreateStepValidationChains()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, fields := serveValidation(t, "/validate/"+tt.step, "/validate/:step", tt.body, StepMiddleware(chains))
+			if status != tt.wantStatus || !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("POST /validate/%s = %d %v, want %d %v", tt.step, status, fields, tt.wantStatus, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestStepValidationMatchesRegistration(t *testing.T) {
+	body := `{` + personalInfoBody + `,` + addressBody + `,` + accountBody + `}`
+
+	_, stepFields := serveValidation(t, "/validate/3", "/validate/:step", body, StepMiddleware(CreateStepValidationChains()))
+	_, submitFields := serveValidation(t, "/register", "/register", body, CreateDefaultRegistrationChain().Middleware())
+
+	if !slices.Contains(stepFields, "password") || !slices.Equal(stepFields, submitFields) {
+		t.Errorf("step 3 errors = %v, /register errors = %v, want the same", stepFields, submitFields)
+	}
+}
+
+// fieldValidator fails with one error per field
+func fieldValidator(fields ...string) Validator {
+	return func(*gin.Context) []Error {
+		errs := make([]Error, len(fields))
+		for i, field := range fields {
+			errs[i] = Error{Field: field, Message: "invalid"}
+		}
+		return errs
+	}
+}
+
+func TestChainModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       Mode
+		validators []Validator
+		wantFields []string
+	}{
+		{
+			name:       "fail fast returns the first failing validator",
+			mode:       FailFast,
+			validators: []Validator{fieldValidator(), fieldValidator("email"), fieldValidator("password", "confirmPassword")},
+			wantFields: []string{"email"},
+		},
+		{
+			name:       "collect all returns every failing validator",
+			mode:       CollectAll,
+			validators: []Validator{fieldValidator(), fieldValidator("email"), fieldValidator(), fieldValidator("password", "confirmPassword")},
+			wantFields: []string{"email", "password", "confirmPassword"},
+		},
+		{
+			name:       "fail fast stops at a binding failure",
+			mode:       FailFast,
+			validators: []Validator{fieldValidator("body"), fieldValidator("email")},
+			wantFields: []string{"body"},
+		},
+		{
+			name:       "collect all stops at a binding failure",
+			mode:       CollectAll,
+			validators: []Validator{fieldValidator("body"), fieldValidator("email")},
+			wantFields: []string{"body"},
+		},
+		{
+			name:       "collect all passes",
+			mode:       CollectAll,
+			validators: []Validator{fieldValidator(), fieldValidator()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewValidationChain(WithMode(tt.mode))
+			for _, validator := range tt.validators {
+				chain.Add(validator)
+			}
+
+			_, fields := serveValidation(t, "/register", "/register", `{}`, chain.Middleware())
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("errors = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestRegistrationChainModes(t *testing.T) {
+	// Both the password and the phone number pass binding but fail later validators
+	body := `{"firstName":"Ada","lastName":"Lovelace","email":"ada@example.com","phoneNumber":"call me at home",` + addressBody +
+		`,"username":"adalovelace","password":"password123","confirmPassword":"password123","acceptTerms":true}`
+
+	tests := []struct {
+		mode       Mode
+		wantFields []string
+	}{
+		{FailFast, []string{"password"}},
+		{CollectAll, []string{"password", "phoneNumber"}},
+	}
+
+	for _, tt := range tests {
+		_, fields := serveValidation(t, "/register", "/register", body, CreateDefaultRegistrationChain(WithMode(tt.mode)).Middleware())
+		if !slices.Equal(fields, tt.wantFields) {
+			t.Errorf("mode %d errors = %v, want %v", tt.mode, fields, tt.wantFields)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package validation
 
 import (
+	stdcontext "context"
 	"fmt"
 	"multistep-registration/internal/context"
 	"multistep-registration/internal/domain"
+	"multistep-registration/internal/logging"
+	"multistep-registration/internal/strength"
 	"regexp"
 	"strings"
 
@@ -11,28 +14,77 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+// Patterns shared by the validators and the published JSON Schema
+const (
+	EmailPattern    = `^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`
+	UsernamePattern = `^[a-zA-Z0-9]{6,30}$`
+	PhonePattern    = `^[\+]?[(]?[0-9]{3}[)]?[-\s\.]?[0-9]{3}[-\s\.]?[0-9]{4,6}$`
+
+	PasswordSpecialChars = "!@#$%^&*()_+-=[]{}|;:,.<>?"
+
+	// MaxPasswordLength matches the max binding of the password fields,
+	// PasswordPolicy.MaxLength lowers it to what the password hasher accepts
+	MaxPasswordLength = 128
+)
+
+var (
+	emailRegex    = regexp.MustCompile(EmailPattern)
+	usernameRegex = regexp.MustCompile(UsernamePattern)
+	phoneRegex    = regexp.MustCompile(PhonePattern)
+)
+
 // RequiredFieldsValidator validates that required fields are present
 func RequiredFieldsValidator() Validator {
 	return func(c *gin.Context) []Error {
-		var req domain.RegistrationRequest
+		return bindRegistrationRequest(c, nil)
+	}
+}
 
-		if err := c.ShouldBindJSON(&req); err != nil {
-			var errors []Error
-			if validationErrors, ok := err.(validator.ValidationErrors); ok {
-				for _, fieldErr := range validationErrors {
-					errors = append(errors, Error{
-						Field:   strings.ToLower(fieldErr.Field()),
-						Message: getValidationMessage(fieldErr),
-					})
-				}
+// StepFieldsValidator validates binding rules of the given step fields only,
+// fields of the other steps may be missing from the request
+func StepFieldsValidator(fields ...string) Validator {
+	allowed := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		allowed[field] = true
+	}
+
+	return func(c *gin.Context) []Error {
+		return bindRegistrationRequest(c, allowed)
+	}
+}
+
+// bindRegistrationRequest binds the request body and sets it in context.
+// When fields is not nil binding errors of other fields are ignored
+func bindRegistrationRequest(c *gin.Context, fields map[string]bool) []Error {
+	var req domain.RegistrationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationErrors, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return []Error{{
+				Field:   "body",
+				Message: "Invalid request body",
+			}}
+		}
+
+		var errors []Error
+		for _, fieldErr := range validationErrors {
+			if fields != nil && !fields[fieldErr.StructField()] {
+				continue
 			}
+			errors = append(errors, Error{
+				Field:   jsonFieldName(fieldErr.StructField()),
+				Message: getValidationMessage(fieldErr),
+			})
+		}
+		if len(errors) > 0 {
 			return errors
 		}
+	}
 
-		context.SetRegistrationRequest(c, &req)
+	context.SetRegistrationRequest(c, &req)
 
-		return nil
-	}
+	return nil
 }
 
 // EmailFormatValidator validates email format
@@ -40,9 +92,7 @@ func EmailFormatValidator() Validator {
 	return func(c *gin.Context) []Error {
 		req := context.MustGetRegistrationRequest(c)
 
-		emailRegex := `^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`
-
-		if match, _ := regexp.MatchString(emailRegex, req.Email); !match {
+		if !emailRegex.MatchString(req.Email) {
 			return []Error{{
 				Field:   "email",
 				Message: "Invalid email format",
@@ -53,70 +103,172 @@ func EmailFormatValidator() Validator {
 	}
 }
 
-// PasswordStrengthValidator validates password strength
-func PasswordStrengthValidator() Validator {
+// PasswordPolicy sets the rules of new passwords
+type PasswordPolicy struct {
+	// MinScore is the lowest accepted strength score, from 0 to 4
+	MinScore int
+	// ClassRules also requires an uppercase and a lowercase letter, a
+	// number and a special character
+	ClassRules bool
+	// MaxLength is the longest password in bytes the password hasher
+	// accepts, 0 when it has no limit
+	MaxLength int
+}
+
+// PasswordMaxLength is the longest accepted password, MaxPasswordLength or
+// the lower limit of the password hasher
+func (p PasswordPolicy) PasswordMaxLength() int {
+	if p.MaxLength > 0 && p.MaxLength < MaxPasswordLength {
+		return p.MaxLength
+	}
+	return MaxPasswordLength
+}
+
+// DefaultPasswordPolicy rejects passwords an offline attack guesses in a
+// reasonable time, whatever characters they use
+var DefaultPasswordPolicy = PasswordPolicy{MinScore: strength.ScoreSafelySecure}
+
+// PasswordStrengthValidator validates password strength, the username, email
+// and names of the request count as easily guessed words
+func PasswordStrengthValidator(policy PasswordPolicy) Validator {
 	return func(c *gin.Context) []Error {
 		req := context.MustGetRegistrationRequest(c)
 
-		if len(req.Password) < 8 {
-			return []Error{{
-				Field:   "password",
-				Message: "Password must be at least 8 characters long",
-			}}
-		}
-		if len(req.Password) > 50 {
-			return []Error{{
-				Field:   "password",
-				Message: "Password must be 50 characters long at max",
-			}}
-		}
+		return checkPasswordStrength(req.Password, policy, registrationUserInputs(req)...)
+	}
+}
 
-		hasUpper := false
-		hasLower := false
-		hasDigit := false
-		hasSpecial := false
-
-		for _, char := range req.Password {
-			switch {
-			case 'A' <= char && char <= 'Z':
-				hasUpper = true
-			case 'a' <= char && char <= 'z':
-				hasLower = true
-			case '0' <= char && char <= '9':
-				hasDigit = true
-			case strings.ContainsRune("!@#$%^&*()_+-=[]{}|;:,.<>?", char):
-				hasSpecial = true
-			}
-		}
+// registrationUserInputs returns the personal details of the request an
+// attacker targeting the user would try, the full name is one word and the
+// email local part is also split in its words, e.g. john.smith+news
+func registrationUserInputs(req *domain.RegistrationRequest) []string {
+	inputs := []string{req.Username, req.FirstName, req.LastName, req.FirstName + req.LastName}
 
-		var errors []Error
-		if !hasUpper {
-			errors = append(errors, Error{
-				Field:   "password",
-				Message: "Password must contain at least one uppercase letter",
-			})
+	local, _, _ := strings.Cut(req.Email, "@")
+	inputs = append(inputs, local)
+	inputs = append(inputs, strings.FieldsFunc(local, func(r rune) bool {
+		return strings.ContainsRune("._-+", r)
+	})...)
+
+	return inputs
+}
+
+// checkPasswordStrength holds the password rules shared by registration and password reset
+func checkPasswordStrength(password string, policy PasswordPolicy, userInputs ...string) []Error {
+	if len(password) < 8 {
+		return []Error{{
+			Field:   "password",
+			Message: "Password must be at least 8 characters long",
+		}}
+	}
+	if maxLength := policy.PasswordMaxLength(); len(password) > maxLength {
+		return []Error{{
+			Field:   "password",
+			Message: fmt.Sprintf("Password must be %d characters long at max", maxLength),
+		}}
+	}
+
+	var errors []Error
+	if policy.ClassRules {
+		errors = checkPasswordClasses(password)
+	}
+
+	result := strength.Estimate(password, userInputs...)
+	if result.Score < policy.MinScore {
+		message := result.Feedback.Warning
+		if message == "" {
+			message = "Password is too weak."
 		}
-		if !hasLower {
-			errors = append(errors, Error{
-				Field:   "password",
-				Message: "Password must contain at least one lowercase letter",
-			})
+		if len(result.Feedback.Suggestions) > 0 {
+			message += " " + strings.Join(result.Feedback.Suggestions, " ")
 		}
-		if !hasDigit {
-			errors = append(errors, Error{
-				Field:   "password",
-				Message: "Password must contain at least one number",
-			})
-		}
-		if !hasSpecial {
-			errors = append(errors, Error{
-				Field:   "password",
-				Message: "Password must contain at least one special character",
-			})
+		errors = append(errors, Error{
+			Field:   "password",
+			Message: message,
+		})
+	}
+
+	return errors
+}
+
+// checkPasswordClasses requires every class of characters in the password
+func checkPasswordClasses(password string) []Error {
+	hasUpper := false
+	hasLower := false
+	hasDigit := false
+	hasSpecial := false
+
+	for _, char := range password {
+		switch {
+		case 'A' <= char && char <= 'Z':
+			hasUpper = true
+		case 'a' <= char && char <= 'z':
+			hasLower = true
+		case '0' <= char && char <= '9':
+			hasDigit = true
+		case strings.ContainsRune(PasswordSpecialChars, char):
+			hasSpecial = true
 		}
+	}
 
-		return errors
+	var errors []Error
+	if !hasUpper {
+		errors = append(errors, Error{
+			Field:   "password",
+			Message: "Password must contain at least one uppercase letter",
+		})
+	}
+	if !hasLower {
+		errors = append(errors, Error{
+			Field:   "password",
+			Message: "Password must contain at least one lowercase letter",
+		})
+	}
+	if !hasDigit {
+		errors = append(errors, Error{
+			Field:   "password",
+			Message: "Password must contain at least one number",
+		})
 	}
+	if !hasSpecial {
+		errors = append(errors, Error{
+			Field:   "password",
+			Message: "Password must contain at least one special character",
+		})
+	}
+
+	return errors
+}
+
+// BreachChecker reports whether a password appeared in a data breach
+type BreachChecker interface {
+	Breached(ctx stdcontext.Context, password string) (bool, error)
+}
+
+// BreachedPasswordValidator rejects passwords of known breaches
+func BreachedPasswordValidator(checker BreachChecker) Validator {
+	return func(c *gin.Context) []Error {
+		req := context.MustGetRegistrationRequest(c)
+
+		return checkBreached(c, checker, req.Password)
+	}
+}
+
+// checkBreached lets the password through when the check fails, an
+// unreadable corpus must not block registrations
+func checkBreached(c *gin.Context, checker BreachChecker, password string) []Error {
+	breached, err := checker.Breached(c.Request.Context(), password)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("breached password check failed", "error", err)
+		return nil
+	}
+	if breached {
+		return []Error{{
+			Field:   "password",
+			Message: "This password has appeared in a data breach, please choose another one",
+		}}
+	}
+	return nil
 }
 
 // PasswordMatchValidator validates password confirmation
@@ -140,12 +292,10 @@ func UsernameFormatValidator() Validator {
 	return func(c *gin.Context) []Error {
 		req := context.MustGetRegistrationRequest(c)
 
-		usernameRegex := `^[a-zA-Z0-9]{6,50}$`
-
-		if match, _ := regexp.MatchString(usernameRegex, req.Username); !match {
+		if !usernameRegex.MatchString(req.Username) {
 			return []Error{{
 				Field:   "username",
-				Message: "Username must be 6-50 characters and contain only letters and numbers",
+				Message: "Username must be 6-30 characters and contain only letters and numbers",
 			}}
 		}
 
@@ -175,8 +325,7 @@ func PhoneNumberValidator() Validator {
 		req := context.MustGetRegistrationRequest(c)
 
 		if req.PhoneNumber != nil && *req.PhoneNumber != "" {
-			phoneRegex := `^[\+]?[(]?[0-9]{3}[)]?[-\s\.]?[0-9]{3}[-\s\.]?[0-9]{4,6}$`
-			if match, _ := regexp.MatchString(phoneRegex, *req.PhoneNumber); !match {
+			if !phoneRegex.MatchString(*req.PhoneNumber) {
 				return []Error{{
 					Field:   "phoneNumber",
 					Message: "Invalid phone number format",
@@ -188,6 +337,64 @@ func PhoneNumberValidator() Validator {
 	}
 }
 
+// PasswordResetFieldsValidator binds the password reset request, it must be
+// the first validator of the password reset chain
+func PasswordResetFieldsValidator() Validator {
+	return func(c *gin.Context) []Error {
+		var req domain.PasswordResetRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			validationErrors, ok := err.(validator.ValidationErrors)
+			if !ok {
+				return []Error{{
+					Field:   "body",
+					Message: "Invalid request body",
+				}}
+			}
+
+			var errors []Error
+			for _, fieldErr := range validationErrors {
+				errors = append(errors, Error{
+					Field:   jsonFieldName(fieldErr.StructField()),
+					Message: getValidationMessage(fieldErr),
+				})
+			}
+			return errors
+		}
+
+		context.SetPasswordResetRequest(c, &req)
+
+		return nil
+	}
+}
+
+// ResetPasswordStrengthValidator applies the registration password rules to
+// the new password, the request carries no personal details to check against
+func ResetPasswordStrengthValidator(policy PasswordPolicy) Validator {
+	return func(c *gin.Context) []Error {
+		req := context.MustGetPasswordResetRequest(c)
+
+		return checkPasswordStrength(req.Password, policy)
+	}
+}
+
+// ResetBreachedPasswordValidator rejects new passwords of known breaches
+func ResetBreachedPasswordValidator(checker BreachChecker) Validator {
+	return func(c *gin.Context) []Error {
+		req := context.MustGetPasswordResetRequest(c)
+
+		return checkBreached(c, checker, req.Password)
+	}
+}
+
+// jsonFieldName converts struct field name to its JSON name, e.g. FirstName to firstName
+func jsonFieldName(structField string) string {
+	if structField == "" {
+		return structField
+	}
+	return strings.ToLower(structField[:1]) + structField[1:]
+}
+
 func getValidationMessage(fieldErr validator.FieldError) string {
 	switch fieldErr.Tag() {
 	case "required":
@@ -0,0 +1,275 @@
+// Package client is a typed Go client of the registration API
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiPath = "/api/v1"
+
+	idempotencyKeyHeader = "Idempotency-Key"
+	requestIDHeader      = "X-Request-ID"
+
+	// maxErrorBody bounds how much of an error response is read
+	maxErrorBody = 1 << 20
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New creates a client of the API served at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base url %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+		userAgent:  "multistep-registration-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// RequestOption sets optional headers of a single call
+type RequestOption func(h http.Header)
+
+// IdempotencyKey replaces the key Register generates, to share it with
+// retries done outside of the client
+func IdempotencyKey(key string) RequestOption {
+	return func(h http.Header) {
+		h.Set(idempotencyKeyHeader, key)
+	}
+}
+
+// RequestID propagates the request ID of the caller
+func RequestID(id string) RequestOption {
+	return func(h http.Header) {
+		h.Set(requestIDHeader, id)
+	}
+}
+
+// Register creates a user. Every call carries an Idempotency-Key, so retries
+// replay the first response instead of registering twice
+func (c *Client) Register(ctx context.Context, req RegistrationRequest, opts ...RequestOption) (*RegistrationResponse, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]RequestOption{IdempotencyKey(key)}, opts...)
+
+	var resp RegistrationResponse
+	if err := c.do(ctx, http.MethodPost, apiPath+"/register", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) CheckUsername(ctx context.Context, username string, opts ...RequestOption) (*AvailabilityResponse, error) {
+	var resp AvailabilityResponse
+	query := url.Values{"username": {username}}
+	if err := c.do(ctx, http.MethodGet, apiPath+"/check-username", query, nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) CheckEmail(ctx context.Context, email string, opts ...RequestOption) (*AvailabilityResponse, error) {
+	var resp AvailabilityResponse
+	query := url.Values{"email": {email}}
+	if err := c.do(ctx, http.MethodGet, apiPath+"/check-email", query, nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Health returns the health report. An unhealthy API answers 503 with the
+// report, it is returned together with the *APIError
+func (c *Client) Health(ctx context.Context, opts ...RequestOption) (*HealthResponse, error) {
+	var resp HealthResponse
+	err := c.do(ctx, http.MethodGet, "/health", nil, nil, &resp, opts)
+
+	var apiErr *APIError
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable && resp.Status != "") {
+		return nil, err
+	}
+	return &resp, err
+}
+
+// do sends the request and decodes a 2xx body into out. Every call of the
+// client is idempotent, GETs by nature and Register through its key, so
+// retryable failures are retried
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any, opts []RequestOption) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	attempts := max(c.retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		retryable, retryAfter, err := c.attempt(ctx, method, u.String(), body, out, opts)
+		if err == nil || !retryable || attempt >= attempts {
+			return err
+		}
+
+		delay, ok := c.retry.backoff(attempt, retryAfter)
+		if !ok {
+			return err
+		}
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, target string, body []byte, out any, opts []RequestOption) (retryable bool, retryAfter time.Duration, err error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/problem+json, application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req.Header)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Cancellation is the caller's decision, anything else may be transient
+		return ctx.Err() == nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil || resp.StatusCode == http.StatusNoContent {
+			return false, 0, nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return false, 0, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return false, 0, nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr := decodeError(resp, data)
+	if resp.StatusCode == http.StatusServiceUnavailable && out != nil {
+		// Some endpoints, e.g. /health, report details along with a 503
+		_ = json.Unmarshal(data, out)
+	}
+
+	var base *APIError
+	errors.As(apiErr, &base)
+	// A request in progress under the same idempotency key finishes shortly
+	retryable = retryableStatus(resp.StatusCode) || base.Code == CodeRequestInProgress
+	return retryable, base.RetryAfter, apiErr
+}
+
+// errorBody accepts both problem details and the legacy ErrorResponse
+type errorBody struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Title     string       `json:"title"`
+	Detail    string       `json:"detail"`
+	RequestID string       `json:"requestId"`
+	Errors    []FieldError `json:"errors"`
+}
+
+func decodeError(resp *http.Response, data []byte) error {
+	var body errorBody
+	_ = json.Unmarshal(data, &body)
+
+	apiErr := APIError{
+		StatusCode: resp.StatusCode,
+		Code:       body.Code,
+		Message:    body.Detail,
+		RequestID:  body.RequestID,
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = body.Message
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = body.Title
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get(requestIDHeader)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	if len(body.Errors) > 0 {
+		return &ValidationError{APIError: apiErr, Fields: body.Errors}
+	}
+	return &apiErr
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
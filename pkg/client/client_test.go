@@ -0,0 +1,340 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"multistep-registration/internal/config"
+	"multistep-registration/internal/domain"
+	"multistep-registration/internal/password"
+	"multistep-registration/internal/server"
+	"multistep-registration/internal/service"
+	"multistep-registration/pkg/client"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUsers registers users in memory, the first busy calls of Register
+// fail as if the hashing queue were full
+type fakeUsers struct {
+	mu        sync.Mutex
+	busy      int
+	calls     int
+	usernames map[string]bool
+}
+
+func (f *fakeUsers) Register(_ context.Context, req *domain.RegistrationRequest) (*domain.RegistrationResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.busy > 0 {
+		f.busy--
+		return nil, password.ErrBusy
+	}
+	if f.usernames[req.Username] {
+		return nil, service.ErrUsernameAlreadyTaken
+	}
+	f.usernames[req.Username] = true
+
+	return &domain.RegistrationResponse{
+		ID:        "0b7f5ad4-5c2b-4c52-9f3e-6f0e2b0f4a11",
+		Username:  req.Username,
+		Email:     req.Email,
+		CreatedAt: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+		Message:   "User registered successfully",
+	}, nil
+}
+
+func (f *fakeUsers) CheckUsernameAvailability(_ context.Context, username string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.usernames[username], nil
+}
+
+func (f *fakeUsers) CheckEmailAvailability(context.Context, string) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeUsers) registerCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// fakeIdempotency handles every request, replays are covered by the server
+type fakeIdempotency struct{}
+
+func (fakeIdempotency) Begin(context.Context, string, string, string, []byte) (*domain.IdempotencyRecord, error) {
+	return nil, nil
+}
+
+func (fakeIdempotency) Complete(context.Context, string, string, string, int, string, []byte) error {
+	return nil
+}
+
+func (fakeIdempotency) Release(context.Context, string, string, string) error {
+	return nil
+}
+
+type fakeVerification struct {
+	service.EmailVerificationService
+}
+
+func (fakeVerification) SendVerification(context.Context, string) {}
+
+func newTestServer(t *testing.T, users *fakeUsers, configure func(*config.Config)) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var cfg config.Config
+	cfg.API.LegacyDeprecatedAt = "2026-10-17"
+	cfg.API.LegacySunsetAt = "2027-04-30"
+	cfg.Session.CookieName = "session_id"
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	srv, err := server.New(server.Props{
+		Config: &cfg,
+		Logger: slog.New(slog.DiscardHandler),
+	}, server.Services{
+		User:              users,
+		Idempotency:       fakeIdempotency{},
+		EmailVerification: fakeVerification{},
+	})
+	if err != nil {
+		t.Fatalf("server.New() error = %v", err)
+	}
+
+	ts := httptest.NewServer(srv.RegisterRoutes())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newClient(t *testing.T, ts *httptest.Server, policy client.RetryPolicy) *client.Client {
+	t.Helper()
+	c, err := client.New(ts.URL, client.WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	return c
+}
+
+var noRetry = client.RetryPolicy{MaxAttempts: 1}
+
+func validRequest(username string) client.RegistrationRequest {
+	return client.RegistrationRequest{
+		FirstName:       "Ada",
+		LastName:        "Lovelace",
+		Email:           "ada@example.com",
+		StreetAddress:   "12 St James's Square",
+		City:            "London",
+		State:           "London",
+		Country:         "United Kingdom",
+		Username:        username,
+		Password:        "analytical engine punch cards 1843",
+		ConfirmPassword: "analytical engine punch cards 1843",
+		AcceptTerms:     true,
+	}
+}
+
+func TestRegisterDecodesResponse(t *testing.T) {
+	ts := newTestServer(t, &fakeUsers{usernames: map[string]bool{}}, nil)
+	c := newClient(t, ts, noRetry)
+
+	resp, err := c.Register(context.Background(), validRequest("adalovelace"))
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if resp.Username != "adalovelace" || resp.Email != "ada@example.com" || resp.ID == "" {
+		t.Errorf("Register() = %+v, want the registered user", resp)
+	}
+	if want := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC); !resp.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", resp.CreatedAt, want)
+	}
+
+	availability, err := c.CheckUsername(context.Background(), "adalovelace")
+	if err != nil {
+		t.Fatalf("CheckUsername() error = %v", err)
+	}
+	if availability.Available || availability.Message == "" {
+		t.Errorf("CheckUsername() = %+v, want taken with a message", availability)
+	}
+
+	health, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if health.Status != "healthy" || health.Services["database"] == nil {
+		t.Errorf("Health() = %+v, want healthy with a database entry", health)
+	}
+}
+
+func TestRegisterValidationError(t *testing.T) {
+	ts := newTestServer(t, &fakeUsers{usernames: map[string]bool{}}, nil)
+	c := newClient(t, ts, noRetry)
+
+	req := validRequest("adalovelace")
+	req.Email = "not-an-email"
+	req.ConfirmPassword = "something else"
+	_, err := c.Register(context.Background(), req)
+
+	var validationErr *client.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Register() error = %v, want a *ValidationError", err)
+	}
+	fields := map[string]bool{}
+	for _, field := range validationErr.Fields {
+		fields[field.Field] = true
+	}
+	if !fields["email"] || !fields["confirmPassword"] {
+		t.Errorf("Fields = %+v, want email and confirmPassword", validationErr.Fields)
+	}
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("errors.As(*APIError) = %+v, want status 400", apiErr)
+	}
+	if !client.IsCode(err, client.CodeValidationError) {
+		t.Errorf("IsCode(%v, %s) = false", err, client.CodeValidationError)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	ts := newTestServer(t, &fakeUsers{usernames: map[string]bool{"adalovelace": true}}, nil)
+	c := newClient(t, ts, noRetry)
+
+	_, err := c.Register(context.Background(), validRequest("adalovelace"))
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Fatalf("Register() error = %v, want a 409 *APIError", err)
+	}
+	var validationErr *client.ValidationError
+	if errors.As(err, &validationErr) {
+		t.Errorf("Register() error = %v, a conflict is not a *ValidationError", err)
+	}
+	if !client.IsCode(err, client.CodeDuplicateError) || client.IsCode(err, client.CodeValidationError) {
+		t.Errorf("IsCode(%v) matches the wrong code", err)
+	}
+	if apiErr.RequestID == "" {
+		t.Error("RequestID is empty, want the X-Request-ID of the response")
+	}
+}
+
+func TestRetryOnServiceBusy(t *testing.T) {
+	users := &fakeUsers{busy: 1, usernames: map[string]bool{}}
+	ts := newTestServer(t, users, nil)
+	c := newClient(t, ts, client.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 2 * time.Second})
+
+	start := time.Now()
+	resp, err := c.Register(context.Background(), validRequest("adalovelace"))
+	if err != nil {
+		t.Fatalf("Register() error = %v, want success after a retry", err)
+	}
+	if resp.Username != "adalovelace" {
+		t.Errorf("Register() = %+v", resp)
+	}
+	if calls := users.registerCalls(); calls != 2 {
+		t.Errorf("Register calls = %d, want 2", calls)
+	}
+	// The 503 carries Retry-After: 1, it wins over the shorter backoff
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s Retry-After honored", elapsed)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	users := &fakeUsers{busy: 10, usernames: map[string]bool{}}
+	ts := newTestServer(t, users, nil)
+	c := newClient(t, ts, client.RetryPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 2 * time.Second})
+
+	_, err := c.Register(context.Background(), validRequest("adalovelace"))
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Register() error = %v, want a 503 *APIError", err)
+	}
+	if !client.IsCode(err, client.CodeServiceBusy) || apiErr.RetryAfter != time.Second {
+		t.Errorf("APIError = %+v, want SERVICE_BUSY with RetryAfter 1s", apiErr)
+	}
+	if calls := users.registerCalls(); calls != 2 {
+		t.Errorf("Register calls = %d, want 2", calls)
+	}
+}
+
+func TestRetryOnRateLimit(t *testing.T) {
+	ts := newTestServer(t, &fakeUsers{usernames: map[string]bool{}}, func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Store = "memory"
+		cfg.RateLimit.CheckRequests = 1
+		cfg.RateLimit.CheckPeriodSeconds = 1
+		cfg.RateLimit.RegisterRequests = 10
+		cfg.RateLimit.RegisterPeriodSeconds = 60
+		cfg.RateLimit.LoginRequests = 10
+		cfg.RateLimit.LoginPeriodSeconds = 60
+		cfg.RateLimit.MailRequests = 10
+		cfg.RateLimit.MailPeriodSeconds = 60
+	})
+
+	// Retry-After is longer than MaxBackoff, the 429 is returned right away
+	impatient := newClient(t, ts, client.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond})
+	if _, err := impatient.CheckEmail(context.Background(), "ada@example.com"); err != nil {
+		t.Fatalf("CheckEmail() error = %v", err)
+	}
+	start := time.Now()
+	_, err := impatient.CheckEmail(context.Background(), "ada@example.com")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("CheckEmail() error = %v, want a 429 *APIError", err)
+	}
+	if !client.IsCode(err, client.CodeRateLimited) || apiErr.RetryAfter <= 0 {
+		t.Errorf("APIError = %+v, want RATE_LIMITED with a RetryAfter", apiErr)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("CheckEmail() took %v, want no retry", elapsed)
+	}
+
+	// A patient client waits for the window and succeeds
+	patient := newClient(t, ts, client.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 2 * time.Second})
+	if _, err := patient.CheckEmail(context.Background(), "ada@example.com"); err != nil {
+		t.Errorf("CheckEmail() error = %v, want success after waiting Retry-After", err)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	users := &fakeUsers{busy: 10, usernames: map[string]bool{}}
+	ts := newTestServer(t, users, nil)
+	c := newClient(t, ts, client.RetryPolicy{MaxAttempts: 5, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 5 * time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Register(ctx, validRequest("adalovelace")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Register() with a canceled context error = %v, want context.Canceled", err)
+	}
+	if calls := users.registerCalls(); calls != 0 {
+		t.Errorf("Register calls = %d, want none", calls)
+	}
+
+	// Cancellation interrupts the wait before a retry
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Register(ctx, validRequest("adalovelace"))
+	if !client.IsCode(err, client.CodeServiceBusy) {
+		t.Errorf("Register() error = %v, want the last SERVICE_BUSY error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("Register() returned after %v, want the 1s retry wait interrupted", elapsed)
+	}
+	if calls := users.registerCalls(); calls != 1 {
+		t.Errorf("Register calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIError is an error response of the API
+type APIError struct {
+	StatusCode int
+	// Code is one of the Code* constants
+	Code      string
+	Message   string
+	RequestID string
+	// RetryAfter is set from the Retry-After header of 409 and 429 responses
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("registration api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is a rejected request body, Fields lists every invalid
+// field. errors.As also matches it as an *APIError
+type ValidationError struct {
+	APIError
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field.Field + ": " + field.Message
+	}
+	return e.APIError.Error() + ": " + strings.Join(fields, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return &e.APIError
+}
+
+// IsCode reports whether err is an API error with the code
+func IsCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy retries idempotent requests with exponential backoff and full
+// jitter. Requests are retried on network errors, 429, 502, 503 and 504
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the retry following attempt, counted from 1.
+// The server's Retry-After wins when it is longer, ok is false when it
+// exceeds MaxBackoff and the error should be returned instead
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) (delay time.Duration, ok bool) {
+	if retryAfter > p.MaxBackoff {
+		return 0, false
+	}
+
+	delay = p.InitialBackoff << (attempt - 1)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	delay = rand.N(delay + 1)
+
+	return max(delay, retryAfter), true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
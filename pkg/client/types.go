@@ -0,0 +1,57 @@
+package client
+
+import "time"
+
+// The request and response types mirror the JSON of the API. They are
+// defined here so the public package doesn't expose the server internals
+
+type RegistrationRequest struct {
+	FirstName   string  `json:"firstName"`
+	LastName    string  `json:"lastName"`
+	Email       string  `json:"email"`
+	PhoneNumber *string `json:"phoneNumber,omitempty"`
+
+	StreetAddress string `json:"streetAddress"`
+	City          string `json:"city"`
+	State         string `json:"state"`
+	Country       string `json:"country"`
+
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+
+	AcceptTerms bool `json:"acceptTerms"`
+	Newsletter  bool `json:"newsletter"`
+}
+
+type RegistrationResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+	Message   string    `json:"message"`
+}
+
+type AvailabilityResponse struct {
+	Available bool   `json:"available"`
+	Message   string `json:"message"`
+}
+
+type HealthResponse struct {
+	Status    string         `json:"status"`
+	Timestamp time.Time      `json:"timestamp"`
+	Services  map[string]any `json:"services"`
+	Version   string         `json:"version,omitempty"`
+}
+
+// Error codes reported in APIError.Code
+const (
+	CodeValidationError      = "VALIDATION_ERROR"
+	CodeDuplicateError       = "DUPLICATE_ERROR"
+	CodeInternalError        = "INTERNAL_ERROR"
+	CodeNotFound             = "NOT_FOUND"
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestInProgress    = "REQUEST_IN_PROGRESS"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeServiceBusy          = "SERVICE_BUSY"
+)